@@ -0,0 +1,95 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// AbortIncompleteOptions selects the in-progress multipart uploads
+// AbortIncompleteUploads aborts.
+type AbortIncompleteOptions struct {
+	Bucket string
+	// Prefix limits the uploads to keys starting with it.
+	Prefix string
+	// OlderThan skips uploads initiated less than this long ago, which may
+	// still be running.
+	OlderThan time.Duration
+	// DryRun lists the uploads that would be aborted without aborting them.
+	DryRun bool
+	ClientOptions
+}
+
+// IncompleteUpload is an in-progress multipart upload.
+type IncompleteUpload struct {
+	Key       string
+	UploadID  string
+	Initiated time.Time
+}
+
+// IncompleteUploadsAPIClient is the S3 client methods
+// AbortIncompleteUploads needs.
+type IncompleteUploadsAPIClient interface {
+	s3.ListMultipartUploadsAPIClient
+	AbortMultipartUpload(context.Context, *s3.AbortMultipartUploadInput, ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+}
+
+// AbortIncompleteUploads lists the multipart uploads in progress under
+// opts.Prefix and aborts the ones initiated more than opts.OlderThan ago,
+// deleting their parts. It returns the uploads that were aborted, or that
+// would be with DryRun.
+func AbortIncompleteUploads(ctx context.Context, opts *AbortIncompleteOptions) ([]*IncompleteUpload, error) {
+	client, err := NewS3Client(ctx, opts.ClientOptions)
+	if err != nil {
+		return nil, err
+	}
+	return abortIncompleteUploads(ctx, client, opts, time.Now())
+}
+
+func abortIncompleteUploads(ctx context.Context, client IncompleteUploadsAPIClient, opts *AbortIncompleteOptions, now time.Time) ([]*IncompleteUpload, error) {
+	if opts.OlderThan < 0 {
+		return nil, fmt.Errorf("the minimum age must not be negative")
+	}
+
+	input := &s3.ListMultipartUploadsInput{Bucket: &opts.Bucket}
+	if opts.Prefix != "" {
+		input.Prefix = &opts.Prefix
+	}
+
+	aborted := []*IncompleteUpload{}
+	paginator := s3.NewListMultipartUploadsPaginator(client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return aborted, err
+		}
+		for _, u := range page.Uploads {
+			upload := &IncompleteUpload{
+				Key:       aws.ToString(u.Key),
+				UploadID:  aws.ToString(u.UploadId),
+				Initiated: aws.ToTime(u.Initiated),
+			}
+			if now.Sub(upload.Initiated) < opts.OlderThan {
+				continue
+			}
+			if !opts.DryRun {
+				_, err := client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+					Bucket:   &opts.Bucket,
+					Key:      u.Key,
+					UploadId: u.UploadId,
+				})
+				if err != nil {
+					return aborted, fmt.Errorf("unable to abort upload %s of s3://%s/%s: %w", upload.UploadID, opts.Bucket, upload.Key, err)
+				}
+			}
+			aborted = append(aborted, upload)
+		}
+	}
+	return aborted, nil
+}
@@ -0,0 +1,151 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zeebo/blake3"
+)
+
+// Algorithm is a checksum algorithm that can be selected by name.
+type Algorithm struct {
+	Name    string
+	HashFun func() hash.Hash
+	// S3Compatible is false for algorithms Amazon S3 doesn't support. Their
+	// values are only useful locally and can't be compared with anything S3
+	// reports.
+	S3Compatible bool
+	// FullObject algorithms are computed over the whole object in a single
+	// pass instead of being combined from the parts, and S3 reports them
+	// without the -N part count suffix.
+	FullObject bool
+}
+
+// algorithmsMu guards algorithms, which RegisterAlgorithm can change while
+// files are being checksummed.
+var algorithmsMu sync.RWMutex
+
+var algorithms = map[string]*Algorithm{
+	"sha256":    {Name: "sha256", HashFun: sha256.New, S3Compatible: true},
+	"sha1":      {Name: "sha1", HashFun: sha1.New, S3Compatible: true},
+	"crc32c":    {Name: "crc32c", HashFun: newCRC32C, S3Compatible: true},
+	"crc64nvme": {Name: "crc64nvme", HashFun: newCRC64NVME, S3Compatible: true, FullObject: true},
+	"blake3":    {Name: "blake3", HashFun: func() hash.Hash { return blake3.New() }},
+}
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// newCRC32C returns a CRC32C hash. Its Sum is the big-endian CRC, the byte
+// order S3 base64 encodes.
+func newCRC32C() hash.Hash {
+	return crc32.New(crc32cTable)
+}
+
+// crc64nvmeTable is built from the bit-reversed CRC-64/NVME polynomial, the
+// form hash/crc64 expects.
+var crc64nvmeTable = crc64.MakeTable(0x9a6c9329ac4bc9b5)
+
+// newCRC64NVME returns a CRC-64/NVME hash with a big-endian Sum.
+func newCRC64NVME() hash.Hash {
+	return crc64.New(crc64nvmeTable)
+}
+
+// builtinAlgorithms are the ones algorithms starts with, they can't be
+// replaced by RegisterAlgorithm.
+var builtinAlgorithms = func() map[string]bool {
+	builtin := make(map[string]bool, len(algorithms))
+	for name := range algorithms {
+		builtin[name] = true
+	}
+	return builtin
+}()
+
+// RegisterAlgorithm makes a custom hash available under name, to the
+// library and to the CLI's --algorithm flag. With fullObject set the
+// checksum is computed over the whole file in one pass, like CRC64NVME;
+// otherwise it is combined from the part checksums. S3 doesn't know custom
+// algorithms, so their values are only meaningful locally. Registering a
+// name that already exists replaces the previous algorithm, but built-in
+// algorithms can't be replaced.
+func RegisterAlgorithm(name string, ctor func() hash.Hash, fullObject bool) error {
+	key := strings.ToLower(name)
+	if key == "" {
+		return fmt.Errorf("algorithm name can't be empty")
+	}
+	if ctor == nil {
+		return fmt.Errorf("algorithm %s has no hash constructor", name)
+	}
+	if builtinAlgorithms[key] {
+		return fmt.Errorf("algorithm %s is built in and can't be replaced", name)
+	}
+	algorithmsMu.Lock()
+	defer algorithmsMu.Unlock()
+	algorithms[key] = &Algorithm{Name: key, HashFun: ctor, FullObject: fullObject}
+	return nil
+}
+
+// DefaultAlgorithm is used when no algorithm is selected.
+const DefaultAlgorithm = "sha256"
+
+// LookupAlgorithm returns the algorithm registered under name.
+func LookupAlgorithm(name string) (*Algorithm, error) {
+	algorithmsMu.RLock()
+	a, ok := algorithms[strings.ToLower(name)]
+	algorithmsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown checksum algorithm %q, expected one of: %s", name, strings.Join(AlgorithmNames(), ", "))
+	}
+	return a, nil
+}
+
+// AlgorithmNames returns the sorted names of all registered algorithms.
+func AlgorithmNames() []string {
+	algorithmsMu.RLock()
+	names := make([]string, 0, len(algorithms))
+	for k := range algorithms {
+		names = append(names, k)
+	}
+	algorithmsMu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// isS3Compatible reports whether checksums of the named algorithm can be
+// compared with the ones S3 computes. Unknown names are assumed to be.
+func isS3Compatible(name string) bool {
+	if name == "" {
+		return true
+	}
+	algorithmsMu.RLock()
+	defer algorithmsMu.RUnlock()
+	a, ok := algorithms[strings.ToLower(name)]
+	return !ok || a.S3Compatible
+}
+
+// Checksum types, as S3 reports them in x-amz-checksum-type. A COMPOSITE
+// checksum of a multipart object is combined from its part checksums and
+// carries a -N part count, a FULL_OBJECT checksum is computed over all the
+// bytes of the object.
+const (
+	ChecksumTypeComposite  = "COMPOSITE"
+	ChecksumTypeFullObject = "FULL_OBJECT"
+)
+
+// isFullObjectAlgorithm reports whether the named algorithm is computed
+// over the whole object rather than combined from its parts.
+func isFullObjectAlgorithm(name string) bool {
+	algorithmsMu.RLock()
+	defer algorithmsMu.RUnlock()
+	a, ok := algorithms[strings.ToLower(name)]
+	return ok && a.FullObject
+}
@@ -0,0 +1,98 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"sync"
+	"testing"
+)
+
+// TestCRCByteOrder checks the CRC digests against the standard check
+// values of "123456789". S3 base64 encodes the big-endian CRC, a digest in
+// the other byte order looks plausible but never matches.
+func TestCRCByteOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		hashFun func() hash.Hash
+		hex     string
+		base64  string
+	}{
+		{"crc32c", newCRC32C, "e3069283", "4waSgw=="},
+		{"crc64nvme", newCRC64NVME, "ae8b14860a799888", "rosUhgp5mIg="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := tt.hashFun()
+			h.Write([]byte("123456789"))
+			sum := ByteSlice(h.Sum(nil))
+			if got := hex.EncodeToString(sum); got != tt.hex {
+				t.Errorf("Sum = %s, want %s", got, tt.hex)
+			}
+			if got := sum.Encode(EncodingBase64); got != tt.base64 {
+				t.Errorf("base64 = %s, want %s", got, tt.base64)
+			}
+			if got := sum.String(); got != tt.base64 {
+				t.Errorf("String() = %s, want %s", got, tt.base64)
+			}
+		})
+	}
+}
+
+func newTestCRC32() hash.Hash { return crc32.NewIEEE() }
+
+// TestRegisterAlgorithmConcurrent registers algorithms while others are
+// looked up, for the race detector.
+func TestRegisterAlgorithmConcurrent(t *testing.T) {
+	t.Cleanup(func() {
+		algorithmsMu.Lock()
+		defer algorithmsMu.Unlock()
+		for name := range algorithms {
+			if !builtinAlgorithms[name] {
+				delete(algorithms, name)
+			}
+		}
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			if err := RegisterAlgorithm(fmt.Sprintf("test-crc32-%d", i), newTestCRC32, false); err != nil {
+				t.Error(err)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			if _, err := LookupAlgorithm("sha256"); err != nil {
+				t.Error(err)
+			}
+			AlgorithmNames()
+			isS3Compatible("crc32c")
+			isFullObjectAlgorithm("crc64nvme")
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < 8; i++ {
+		if _, err := LookupAlgorithm(fmt.Sprintf("test-crc32-%d", i)); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestRegisterAlgorithmBuiltin(t *testing.T) {
+	if len(builtinAlgorithms) != 5 {
+		t.Errorf("%d built-in algorithms, want 5: %v", len(builtinAlgorithms), builtinAlgorithms)
+	}
+	for name := range builtinAlgorithms {
+		if err := RegisterAlgorithm(name, newTestCRC32, false); err == nil {
+			t.Errorf("built-in %s was replaced", name)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ErrObjectNotRestored is returned when reading an object that is in an
+// archive storage class or archive access tier and hasn't been restored.
+var ErrObjectNotRestored = errors.New("object is archived and not restored")
+
+// IsArchived reports whether objects of storageClass may need a restore
+// before they can be read. Intelligent-Tiering objects only do when they
+// were moved to an archive access tier, which HeadObject reports.
+func IsArchived(storageClass string) bool {
+	switch types.StorageClass(storageClass) {
+	case types.StorageClassGlacier, types.StorageClassDeepArchive, types.StorageClassIntelligentTiering:
+		return true
+	}
+	return false
+}
+
+// checkRestored returns an error wrapping ErrObjectNotRestored, with the
+// restore status, when the object can't be read until it is restored.
+func checkRestored(ctx context.Context, client s3.HeadObjectAPIClient, bucket, key string, sseC *sseCustomerKey) error {
+	input := &s3.HeadObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	}
+	if sseC != nil {
+		input.SSECustomerAlgorithm = &sseC.Algorithm
+		input.SSECustomerKey = &sseC.Key
+		input.SSECustomerKeyMD5 = &sseC.KeyMD5
+	}
+	out, err := client.HeadObject(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	// Intelligent-Tiering objects have an ArchiveStatus only in an archive
+	// access tier
+	archive := string(out.ArchiveStatus)
+	switch out.StorageClass {
+	case types.StorageClassGlacier, types.StorageClassDeepArchive:
+		archive = string(out.StorageClass)
+	}
+	if archive == "" {
+		return nil
+	}
+
+	// The Restore header is ongoing-request="false" once a restored copy is
+	// available, with the date it expires
+	restore := aws.ToString(out.Restore)
+	if strings.Contains(restore, `ongoing-request="false"`) {
+		return nil
+	}
+	status := "no restore has been requested"
+	if strings.Contains(restore, `ongoing-request="true"`) {
+		status = "a restore is in progress"
+	}
+	return fmt.Errorf("s3://%s/%s is in %s and %s: %w", bucket, key, archive, status, ErrObjectNotRestored)
+}
@@ -0,0 +1,203 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ErrNoObjectChecksum is returned for objects that were uploaded without an
+// additional checksum, so there is nothing to verify them against.
+var ErrNoObjectChecksum = errors.New("object has no stored checksum")
+
+// ErrIrregularParts is returned by InferPartSize for objects whose parts
+// aren't all the same size, apart from a smaller last part. Their checksum
+// can't be recomputed from a local file with a single part size.
+var ErrIrregularParts = errors.New("object parts have different sizes, the checksum can't be recomputed with a single part size")
+
+// GetObjectAttributesAPIClient is the S3 client method FetchObjectAttributes
+// needs.
+type GetObjectAttributesAPIClient interface {
+	GetObjectAttributes(context.Context, *s3.GetObjectAttributesInput, ...func(*s3.Options)) (*s3.GetObjectAttributesOutput, error)
+}
+
+// FetchObjectAttributes reads the stored checksum, ETag and part list of an
+// object with GetObjectAttributes, following the part list across pages.
+// PartSize is the size of the first part. Objects uploaded without an
+// additional checksum return ErrNoObjectChecksum.
+func FetchObjectAttributes(ctx context.Context, client GetObjectAttributesAPIClient, bucket, key string) (*ObjectAttributes, error) {
+	return fetchObjectAttributes(ctx, client, bucket, key, nil)
+}
+
+// fetchObjectAttributes is FetchObjectAttributes for objects that may be
+// encrypted with the SSE-C key sseC.
+func fetchObjectAttributes(ctx context.Context, client GetObjectAttributesAPIClient, bucket, key string, sseC *sseCustomerKey) (*ObjectAttributes, error) {
+	attrs := &ObjectAttributes{Filename: key}
+
+	var marker *string
+	for {
+		input := &s3.GetObjectAttributesInput{
+			Bucket: &bucket,
+			Key:    &key,
+			ObjectAttributes: []types.ObjectAttributes{
+				types.ObjectAttributesChecksum,
+				types.ObjectAttributesObjectParts,
+				types.ObjectAttributesObjectSize,
+				types.ObjectAttributesEtag,
+				types.ObjectAttributesStorageClass,
+			},
+			PartNumberMarker: marker,
+		}
+		if sseC != nil {
+			input.SSECustomerAlgorithm = &sseC.Algorithm
+			input.SSECustomerKey = &sseC.Key
+			input.SSECustomerKeyMD5 = &sseC.KeyMD5
+		}
+		out, err := client.GetObjectAttributes(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		if marker == nil {
+			if err := attrs.setChecksum(out); err != nil {
+				return nil, fmt.Errorf("s3://%s/%s: %w", bucket, key, err)
+			}
+		}
+		if out.ObjectParts == nil {
+			break
+		}
+		for _, p := range out.ObjectParts.Parts {
+			part := &PartInfo{Algorithm: attrs.Algorithm}
+			if p.PartNumber != nil {
+				part.PartNumber = *p.PartNumber
+			}
+			if p.Size != nil {
+				part.Size = *p.Size
+			}
+			if encoded := partChecksum(attrs.Algorithm, p); encoded != nil {
+				if part.Checksum, err = decodeChecksum(*encoded); err != nil {
+					return nil, fmt.Errorf("invalid checksum of part %d: %w", part.PartNumber, err)
+				}
+			}
+			attrs.PartList = append(attrs.PartList, part)
+		}
+		if out.ObjectParts.IsTruncated == nil || !*out.ObjectParts.IsTruncated {
+			break
+		}
+		marker = out.ObjectParts.NextPartNumberMarker
+	}
+
+	attrs.PartSize = int(attrs.Size)
+	if len(attrs.PartList) > 0 {
+		attrs.PartSize = int(attrs.PartList[0].Size)
+	}
+	return attrs, nil
+}
+
+// setChecksum fills in the object level attributes of the first page.
+func (attrs *ObjectAttributes) setChecksum(out *s3.GetObjectAttributesOutput) error {
+	if out.ObjectSize != nil {
+		attrs.Size = *out.ObjectSize
+	}
+	attrs.StorageClass = string(out.StorageClass)
+	if out.ETag != nil {
+		var err error
+		if attrs.Etag, err = convertS3EtagToBytes(*out.ETag); err != nil {
+			return err
+		}
+	}
+	if out.ObjectParts != nil && out.ObjectParts.TotalPartsCount != nil {
+		attrs.Parts = int(*out.ObjectParts.TotalPartsCount)
+	}
+
+	if out.Checksum == nil {
+		return ErrNoObjectChecksum
+	}
+	var encoded *string
+	switch {
+	case out.Checksum.ChecksumSHA256 != nil:
+		attrs.Algorithm, encoded = "sha256", out.Checksum.ChecksumSHA256
+	case out.Checksum.ChecksumSHA1 != nil:
+		attrs.Algorithm, encoded = "sha1", out.Checksum.ChecksumSHA1
+	case out.Checksum.ChecksumCRC32C != nil:
+		attrs.Algorithm, encoded = "crc32c", out.Checksum.ChecksumCRC32C
+	case out.Checksum.ChecksumCRC32 != nil:
+		return fmt.Errorf("CRC32 checksums aren't supported")
+	default:
+		return ErrNoObjectChecksum
+	}
+	checksum, _, err := splitPartCount(*encoded)
+	if err != nil {
+		return err
+	}
+	if attrs.Checksum, err = decodeChecksum(checksum); err != nil {
+		return fmt.Errorf("invalid checksum %q: %w", *encoded, err)
+	}
+	return nil
+}
+
+func partChecksum(algorithm string, p types.ObjectPart) *string {
+	switch algorithm {
+	case "sha256":
+		return p.ChecksumSHA256
+	case "sha1":
+		return p.ChecksumSHA1
+	case "crc32c":
+		return p.ChecksumCRC32C
+	}
+	return nil
+}
+
+// InferPartSize returns the part size the object was uploaded with. Every
+// part but the last must have the same size and the last part can be
+// smaller, otherwise ErrIrregularParts is returned. An object that wasn't
+// uploaded in parts is covered by one part of its size, or of
+// MIN_PART_SIZE when it is smaller.
+func (attrs *ObjectAttributes) InferPartSize() (int64, error) {
+	if attrs.Parts == 0 {
+		if attrs.Size < MIN_PART_SIZE {
+			return MIN_PART_SIZE, nil
+		}
+		return attrs.Size, nil
+	}
+	if len(attrs.PartList) != attrs.Parts {
+		return 0, fmt.Errorf("%s has %d parts but the sizes of %d are known", attrs.Filename, attrs.Parts, len(attrs.PartList))
+	}
+
+	partSize := attrs.PartList[0].Size
+	for i, p := range attrs.PartList {
+		last := i == len(attrs.PartList)-1
+		if p.Size != partSize && !(last && p.Size < partSize) {
+			return 0, fmt.Errorf("%s part %d is %d bytes, part 1 is %d bytes: %w", attrs.Filename, p.PartNumber, p.Size, partSize, ErrIrregularParts)
+		}
+	}
+	if partSize < MIN_PART_SIZE {
+		// A single part smaller than the minimum, any larger size covers it
+		partSize = MIN_PART_SIZE
+	}
+	return partSize, nil
+}
+
+// MatchObject sets the part size and algorithm of o to the ones the object
+// was uploaded with, see InferPartSize, so CalculateChecksum over a local
+// copy reproduces the object's checksum. TargetParts, PartAlignment and
+// FitPartLimit are cleared since they would change the part size.
+func (o *MultipartFileOpts) MatchObject(attrs *ObjectAttributes) error {
+	partSize, err := attrs.InferPartSize()
+	if err != nil {
+		return err
+	}
+	o.PartSize = partSize
+	o.TargetParts = 0
+	o.PartAlignment = 0
+	o.FitPartLimit = false
+	o.Algorithm = attrs.Algorithm
+	o.HashFun = nil
+	return nil
+}
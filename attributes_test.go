@@ -0,0 +1,90 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"errors"
+	"testing"
+)
+
+// objectParts returns the part list of an object with parts of sizes.
+func objectParts(sizes ...int64) []*PartInfo {
+	parts := make([]*PartInfo, len(sizes))
+	for i, size := range sizes {
+		parts[i] = &PartInfo{PartNumber: int32(i + 1), Size: size}
+	}
+	return parts
+}
+
+func TestInferPartSize(t *testing.T) {
+	const part = 8 << 20
+	tests := []struct {
+		name    string
+		attrs   *ObjectAttributes
+		want    int64
+		wantErr bool
+		// irregular is whether the error is ErrIrregularParts
+		irregular bool
+	}{
+		{"single PUT", &ObjectAttributes{Size: 3 * part}, 3 * part, false, false},
+		{"single PUT smaller than a part", &ObjectAttributes{Size: 100}, MIN_PART_SIZE, false, false},
+		{"even parts", &ObjectAttributes{Size: 3 * part, Parts: 3, PartList: objectParts(part, part, part)}, part, false, false},
+		{"uneven last part", &ObjectAttributes{Size: 2*part + 10, Parts: 3, PartList: objectParts(part, part, 10)}, part, false, false},
+		{"single small part", &ObjectAttributes{Size: 100, Parts: 1, PartList: objectParts(100)}, MIN_PART_SIZE, false, false},
+		{"single large part", &ObjectAttributes{Size: part, Parts: 1, PartList: objectParts(part)}, part, false, false},
+		{"larger last part", &ObjectAttributes{Size: 3*part + 10, Parts: 3, PartList: objectParts(part, part, part+10)}, 0, true, true},
+		{"smaller middle part", &ObjectAttributes{Size: 3*part - 10, Parts: 3, PartList: objectParts(part, part-10, part)}, 0, true, true},
+		{"missing part sizes", &ObjectAttributes{Size: 3 * part, Parts: 3, PartList: objectParts(part)}, 0, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.attrs.InferPartSize()
+			if (err != nil) != tt.wantErr || errors.Is(err, ErrIrregularParts) != tt.irregular {
+				t.Fatalf("err = %v, want error: %v, irregular parts: %v", err, tt.wantErr, tt.irregular)
+			}
+			if got != tt.want {
+				t.Errorf("part size %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchObject(t *testing.T) {
+	const part = 8 << 20
+	tests := []struct {
+		name  string
+		attrs *ObjectAttributes
+		want  int64
+	}{
+		{"uneven last part", &ObjectAttributes{Algorithm: "crc32c", Size: 2*part + 10, Parts: 3, PartList: objectParts(part, part, 10)}, part},
+		{"single PUT", &ObjectAttributes{Algorithm: "sha1", Size: 3 * part}, 3 * part},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &MultipartFileOpts{
+				PartSize:      MIN_PART_SIZE,
+				TargetParts:   7,
+				PartAlignment: 4096,
+				FitPartLimit:  true,
+				Algorithm:     "sha256",
+				HashFun:       newTestCRC32,
+			}
+			if err := o.MatchObject(tt.attrs); err != nil {
+				t.Fatal(err)
+			}
+			if o.PartSize != tt.want || o.Algorithm != tt.attrs.Algorithm {
+				t.Errorf("part size %d algorithm %s, want %d and %s", o.PartSize, o.Algorithm, tt.want, tt.attrs.Algorithm)
+			}
+			if o.TargetParts != 0 || o.PartAlignment != 0 || o.FitPartLimit || o.HashFun != nil {
+				t.Errorf("options %+v still change the part size or algorithm", o)
+			}
+		})
+	}
+
+	o := &MultipartFileOpts{PartSize: MIN_PART_SIZE}
+	irregular := &ObjectAttributes{Size: 3 * part, Parts: 3, PartList: objectParts(part, part+10, part-10)}
+	if err := o.MatchObject(irregular); !errors.Is(err, ErrIrregularParts) || o.PartSize != MIN_PART_SIZE {
+		t.Errorf("err = %v and part size %d, want ErrIrregularParts and the options unchanged", err, o.PartSize)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BatchEntry is a single file of a batch run together with the part size
+// it should be checksummed with. A PartSize of 0 uses the batch default.
+type BatchEntry struct {
+	Path     string `json:"path"`
+	PartSize int64  `json:"part_size"`
+}
+
+// ReadBatchFile loads a batch spec. Files ending in .json must contain an
+// array of BatchEntry objects, anything else is read as a CSV with the
+// columns path,part_size (part size in bytes, may be left empty).
+func ReadBatchFile(path string) ([]*BatchEntry, error) {
+	f, err := os.Open(LongPath(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []*BatchEntry{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		if err := json.NewDecoder(f).Decode(&entries); err != nil {
+			return nil, fmt.Errorf("unable to parse batch file %s: %w", path, err)
+		}
+	} else {
+		r := csv.NewReader(f)
+		r.FieldsPerRecord = -1
+		rows, err := r.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse batch file %s: %w", path, err)
+		}
+		for i, row := range rows {
+			if len(row) == 0 || row[0] == "" {
+				continue
+			}
+			e := &BatchEntry{Path: row[0]}
+			if len(row) > 1 && strings.TrimSpace(row[1]) != "" {
+				e.PartSize, err = strconv.ParseInt(strings.TrimSpace(row[1]), 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid part size on line %d of %s: %w", i+1, path, err)
+				}
+			}
+			entries = append(entries, e)
+		}
+	}
+
+	for i, e := range entries {
+		if e.Path == "" {
+			return nil, fmt.Errorf("entry %d of batch file %s has no path", i+1, path)
+		}
+		if e.PartSize < 0 {
+			return nil, fmt.Errorf("entry %d of batch file %s has a negative part size", i+1, path)
+		}
+	}
+	return entries, nil
+}
+
+// IsGlob reports whether path contains any of the filepath.Match
+// wildcards *, ? or [.
+func IsGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// GlobBatchEntries expands a filepath.Glob pattern into a batch of the
+// regular files it matches, in sorted order. A pattern that matches no
+// file is an error.
+func GlobBatchEntries(pattern string) ([]*BatchEntry, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	entries := []*BatchEntry{}
+	for _, m := range matches {
+		fileInfo, err := os.Stat(LongPath(m))
+		if err != nil {
+			return nil, err
+		}
+		if fileInfo.Mode().IsRegular() {
+			entries = append(entries, &BatchEntry{Path: m})
+		}
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no files match %q", pattern)
+	}
+	return entries, nil
+}
+
+// FilterModifiedSince returns the entries whose file was modified after since.
+func FilterModifiedSince(entries []*BatchEntry, since time.Time) ([]*BatchEntry, error) {
+	filtered := []*BatchEntry{}
+	for _, e := range entries {
+		fileInfo, err := os.Stat(LongPath(e.Path))
+		if err != nil {
+			return nil, err
+		}
+		if fileInfo.ModTime().After(since) {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered, nil
+}
+
+// ChecksumBatch runs CalculateChecksum for every entry, one file at a time,
+// using opts as the template for each file. Each entry's PartSize overrides
+// opts.PartSize and opts.TargetParts. No per-file manifest is written; the
+// caller gets all the results back and can write them as a single manifest.
+func ChecksumBatch(ctx context.Context, entries []*BatchEntry, opts MultipartFileOpts) ([]*ManifestFile, error) {
+	results := []*ManifestFile{}
+	for _, e := range entries {
+		o := opts.Copy()
+		o.FilePath = e.Path
+		o.ManifestFilePath = ""
+		if e.PartSize > 0 {
+			o.PartSize = e.PartSize
+			o.TargetParts = 0
+		}
+
+		mpf, err := NewMultipartFile(o)
+		if err != nil {
+			return results, err
+		}
+		info, err := mpf.CalculateChecksum(ctx)
+		if err != nil {
+			return results, fmt.Errorf("%s: %w", e.Path, err)
+		}
+		results = append(results, info)
+	}
+	return results, nil
+}
@@ -0,0 +1,70 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// ClientOptions configures the S3 client used to talk to a bucket.
+type ClientOptions struct {
+	Region       string
+	AWSProfile   string
+	UsePathStyle bool
+	// EndpointURL, when set, replaces the AWS endpoint, e.g. to talk to
+	// MinIO or another S3-compatible store. Those usually need
+	// UsePathStyle as well.
+	EndpointURL string
+	// RoleARN, when set, is assumed with STS on top of the default
+	// credential chain, e.g. to upload into a bucket of another account.
+	RoleARN string
+	// RoleSessionName names the assumed role session. The SDK generates
+	// one when it is empty.
+	RoleSessionName string
+}
+
+// NewS3Client loads the default AWS config for the given region and
+// optional shared config profile, assumes RoleARN when it is set, and
+// returns an S3 client for it.
+func NewS3Client(ctx context.Context, opts ClientOptions) (*s3.Client, error) {
+	return newS3Client(ctx, opts)
+}
+
+// newS3Client is NewS3Client with extra config load options, such as the
+// retry settings of an upload.
+func newS3Client(ctx context.Context, opts ClientOptions, extra ...func(*config.LoadOptions) error) (*s3.Client, error) {
+	optFns := []func(*config.LoadOptions) error{
+		config.WithRegion(opts.Region),
+	}
+	if opts.AWSProfile != "" {
+		optFns = append(optFns, config.WithSharedConfigProfile(opts.AWSProfile))
+
+	}
+	optFns = append(optFns, extra...)
+	cfg, err := config.LoadDefaultConfig(ctx, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	if opts.RoleARN != "" {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), opts.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+			if opts.RoleSessionName != "" {
+				o.RoleSessionName = opts.RoleSessionName
+			}
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.UsePathStyle = opts.UsePathStyle
+		if opts.EndpointURL != "" {
+			o.BaseEndpoint = &opts.EndpointURL
+		}
+	}), nil
+}
@@ -0,0 +1,474 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	s3checksum "amazon-s3-checksum-tool"
+)
+
+// checksumConfig holds the parsed flags of the checksum command.
+type checksumConfig struct {
+	File         string
+	Algorithm    string
+	FD           int
+	BatchFile    string
+	ManifestFile string
+	// PathMode and BaseDir set how files are named in the manifest, see
+	// s3checksum.ManifestPath.
+	PathMode string
+	BaseDir  string
+	// AppendManifest merges the results into ManifestFile instead of
+	// replacing it.
+	AppendManifest bool
+	PartSize       int64
+	TargetParts    int
+	// PartAlignment rounds the part size up to a multiple of this many
+	// bytes.
+	PartAlignment int64
+	// FitPartLimit raises the part size to stay within the S3 part limit.
+	FitPartLimit bool
+	Threads      int
+	// MaxMemory bounds the memory used by part buffers, in bytes.
+	MaxMemory    int64
+	Since        time.Time
+	Follow       bool
+	ExpectedSize int64
+	DoneFile     string
+	// RequireManifest fails the run when the manifest can't be written.
+	RequireManifest bool
+	LastPartOnly    bool
+	// SortBy orders the manifest of a batch run, see
+	// s3checksum.SortManifests. Empty keeps the batch file order.
+	SortBy string
+	// FullObject also computes the full object checksum, for output formats
+	// that print it.
+	FullObject bool
+	// TarMember checksums the named member of the tar archive File.
+	TarMember string
+	// RollingChecksum also computes a weak rolling checksum per part.
+	RollingChecksum bool
+	// OpenOnce reads every part from a single file handle.
+	OpenOnce bool
+	// Sequential reads each file front to back in a single pass.
+	Sequential bool
+	// SkipETag leaves the MD5 ETag out.
+	SkipETag bool
+	// ChecksumType is s3checksum.ChecksumTypeFullObject for a checksum
+	// over the whole file.
+	ChecksumType string
+	// Resume is a JSON manifest of an interrupted run over File whose
+	// parts are reused.
+	Resume string
+	// Recursive checksums every file under the directory File.
+	Recursive bool
+	// FollowSymlinks includes what symlinks point to in a Recursive walk.
+	FollowSymlinks bool
+	// Progress is called as parts finish, see MultipartFileOpts.Progress.
+	Progress func(completed, total int)
+}
+
+// checksumResult is everything the checksum command computed. It is
+// rendered separately so the computation can be inspected without
+// capturing stdout.
+type checksumResult struct {
+	Manifests []*s3checksum.ManifestFile
+	Elapsed   time.Duration
+}
+
+func runChecksum(ctx context.Context, cfg checksumConfig) (*checksumResult, error) {
+	start := time.Now()
+
+	if names := algorithmList(cfg.Algorithm); len(names) > 1 {
+		return runChecksumAlgorithms(ctx, cfg, names)
+	}
+
+	algorithm, err := s3checksum.LookupAlgorithm(cfg.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	// Algorithms S3 doesn't support have no composite to compare with, so
+	// the full object digest is what's useful for them
+	fullObject := !algorithm.S3Compatible || cfg.FullObject
+
+	// The template of every file of a multi-file run
+	batchOpts := s3checksum.MultipartFileOpts{
+		PartSize:               cfg.PartSize,
+		Threads:                cfg.Threads,
+		MaxMemoryBytes:         cfg.MaxMemory,
+		TargetParts:            cfg.TargetParts,
+		PartAlignment:          cfg.PartAlignment,
+		FitPartLimit:           cfg.FitPartLimit,
+		Algorithm:              algorithm.Name,
+		IncludeFullObject:      fullObject,
+		LastPartOnly:           cfg.LastPartOnly,
+		IncludeRollingChecksum: cfg.RollingChecksum,
+		OpenOnce:               cfg.OpenOnce,
+		Sequential:             cfg.Sequential,
+		SkipETag:               cfg.SkipETag,
+		ChecksumType:           cfg.ChecksumType,
+		Progress:               cfg.Progress,
+	}
+
+	if cfg.BatchFile != "" || s3checksum.IsGlob(cfg.File) {
+		var entries []*s3checksum.BatchEntry
+		if cfg.BatchFile != "" {
+			entries, err = s3checksum.ReadBatchFile(cfg.BatchFile)
+		} else {
+			entries, err = s3checksum.GlobBatchEntries(cfg.File)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !cfg.Since.IsZero() {
+			entries, err = s3checksum.FilterModifiedSince(entries, cfg.Since)
+			if err != nil {
+				return nil, err
+			}
+		}
+		results, err := s3checksum.ChecksumBatch(ctx, entries, batchOpts)
+		if err != nil {
+			return nil, err
+		}
+		if err := applyPathMode(cfg, results); err != nil {
+			return nil, err
+		}
+		if cfg.SortBy != "" {
+			if err := s3checksum.SortManifests(results, cfg.SortBy); err != nil {
+				return nil, err
+			}
+		}
+		if err := writeManifest(cfg, results); err != nil {
+			return nil, err
+		}
+		return &checksumResult{Manifests: results, Elapsed: time.Since(start)}, nil
+	}
+
+	if cfg.File != "" && cfg.File != "-" {
+		fileInfo, err := os.Stat(s3checksum.LongPath(cfg.File))
+		if err != nil {
+			return nil, err
+		}
+		if fileInfo.IsDir() != cfg.Recursive {
+			if cfg.Recursive {
+				return nil, fmt.Errorf("--recursive requires --file to be a directory, %s is not", cfg.File)
+			}
+			return nil, fmt.Errorf("%s is a directory, use --recursive to checksum every file in it", cfg.File)
+		}
+	}
+
+	if cfg.Recursive {
+		entries, err := s3checksum.DirectoryEntries(cfg.File, cfg.FollowSymlinks)
+		if err != nil {
+			return nil, err
+		}
+		if !cfg.Since.IsZero() {
+			entries, err = s3checksum.FilterModifiedSince(entries, cfg.Since)
+			if err != nil {
+				return nil, err
+			}
+		}
+		results, err := s3checksum.ChecksumDirectoryEntries(ctx, cfg.File, entries, batchOpts)
+		if err != nil {
+			return nil, err
+		}
+		if err := applyPathMode(cfg, results); err != nil {
+			return nil, err
+		}
+		if cfg.SortBy != "" {
+			if err := s3checksum.SortManifests(results, cfg.SortBy); err != nil {
+				return nil, err
+			}
+		}
+		if err := writeManifest(cfg, results); err != nil {
+			return nil, err
+		}
+		return &checksumResult{Manifests: results, Elapsed: time.Since(start)}, nil
+	}
+
+	if cfg.Follow {
+		info, err := s3checksum.ChecksumGrowingFile(ctx, s3checksum.TailOpts{
+			FilePath:          cfg.File,
+			PartSize:          cfg.PartSize,
+			Algorithm:         algorithm.Name,
+			ExpectedSize:      cfg.ExpectedSize,
+			DoneFile:          cfg.DoneFile,
+			IncludeFullObject: fullObject,
+		})
+		if err != nil {
+			return nil, err
+		}
+		manifests := []*s3checksum.ManifestFile{info}
+		if err := applyPathMode(cfg, manifests); err != nil {
+			return nil, err
+		}
+		if err := writeManifest(cfg, manifests); err != nil {
+			return nil, err
+		}
+		return &checksumResult{Manifests: manifests, Elapsed: time.Since(start)}, nil
+	}
+
+	opts := s3checksum.MultipartFileOpts{
+		FilePath:               cfg.File,
+		ManifestFilePath:       cfg.ManifestFile,
+		AppendManifest:         cfg.AppendManifest,
+		PartSize:               cfg.PartSize,
+		Threads:                cfg.Threads,
+		MaxMemoryBytes:         cfg.MaxMemory,
+		TargetParts:            cfg.TargetParts,
+		PartAlignment:          cfg.PartAlignment,
+		FitPartLimit:           cfg.FitPartLimit,
+		Algorithm:              algorithm.Name,
+		IncludeFullObject:      fullObject,
+		LastPartOnly:           cfg.LastPartOnly,
+		IncludeRollingChecksum: cfg.RollingChecksum,
+		OpenOnce:               cfg.OpenOnce,
+		Sequential:             cfg.Sequential,
+		SkipETag:               cfg.SkipETag,
+		ChecksumType:           cfg.ChecksumType,
+		Progress:               cfg.Progress,
+	}
+	if cfg.TarMember != "" {
+		// The manifest is written below, like the other single file runs
+		// that don't go through MultipartFile
+		opts.ManifestFilePath = ""
+		info, err := s3checksum.ChecksumTarMember(ctx, cfg.File, cfg.TarMember, opts)
+		if err != nil {
+			return nil, err
+		}
+		manifests := []*s3checksum.ManifestFile{info}
+		if err := writeManifest(cfg, manifests); err != nil {
+			return nil, err
+		}
+		return &checksumResult{Manifests: manifests, Elapsed: time.Since(start)}, nil
+	}
+	if cfg.File == "-" {
+		info, err := s3checksum.ChecksumStream(ctx, os.Stdin, opts)
+		if err = softManifestError(cfg, err); err != nil {
+			return nil, err
+		}
+		return &checksumResult{Manifests: []*s3checksum.ManifestFile{info}, Elapsed: time.Since(start)}, nil
+	}
+	if cfg.FD >= 0 {
+		// The descriptor is inherited from the parent process, so it's read
+		// in place rather than re-opened by path.
+		f := os.NewFile(uintptr(cfg.FD), fmt.Sprintf("fd:%d", cfg.FD))
+		if f == nil {
+			return nil, fmt.Errorf("invalid file descriptor %d", cfg.FD)
+		}
+		defer f.Close()
+		opts.FilePath = f.Name()
+		fileInfo, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		if !fileInfo.Mode().IsRegular() {
+			// Pipes and sockets have no size and can't be read at an
+			// offset, so they are read front to back like stdin
+			info, err := s3checksum.ChecksumStream(ctx, f, opts)
+			if err = softManifestError(cfg, err); err != nil {
+				return nil, err
+			}
+			return &checksumResult{Manifests: []*s3checksum.ManifestFile{info}, Elapsed: time.Since(start)}, nil
+		}
+		opts.Reader = f
+	}
+
+	if opts.ManifestName, err = s3checksum.ManifestPath(cfg.File, cfg.PathMode, cfg.BaseDir); err != nil {
+		return nil, err
+	}
+	if cfg.Resume != "" {
+		if opts.ResumeFrom, err = readResumeManifest(cfg.Resume); err != nil {
+			return nil, err
+		}
+	}
+
+	mpf, err := s3checksum.NewMultipartFile(opts)
+	if err != nil {
+		return nil, err
+	}
+	info, err := mpf.CalculateChecksum(ctx)
+	if err = softManifestError(cfg, err); err != nil {
+		return nil, err
+	}
+
+	return &checksumResult{
+		Manifests: []*s3checksum.ManifestFile{info},
+		Elapsed:   time.Since(start),
+	}, nil
+}
+
+// pathModes are the values --path-mode accepts.
+var pathModes = map[string]bool{"": true, s3checksum.PathModeAbsolute: true, s3checksum.PathModeRelative: true, s3checksum.PathModeBasename: true}
+
+// applyPathMode renames the files of a run that checksums them outside
+// MultipartFile with --path-mode. Recursive runs name files relative to the
+// directory, so they are joined with it first.
+func applyPathMode(cfg checksumConfig, manifests []*s3checksum.ManifestFile) error {
+	if cfg.PathMode == "" {
+		return nil
+	}
+	for _, m := range manifests {
+		path := m.Filename
+		if cfg.Recursive {
+			path = filepath.Join(cfg.File, path)
+		}
+		name, err := s3checksum.ManifestPath(path, cfg.PathMode, cfg.BaseDir)
+		if err != nil {
+			return err
+		}
+		m.Filename = name
+	}
+	return nil
+}
+
+// writeManifest writes the manifest of a multi-file run. A failure is only
+// logged unless the manifest is required.
+func writeManifest(cfg checksumConfig, manifests []*s3checksum.ManifestFile) error {
+	if cfg.ManifestFile == "" {
+		return nil
+	}
+	write := s3checksum.WriteManifestFile
+	if cfg.AppendManifest {
+		write = s3checksum.AppendToManifest
+	}
+	if err := write(cfg.ManifestFile, manifests); err != nil {
+		if cfg.RequireManifest {
+			return fmt.Errorf("error writing manifest file: %w", err)
+		}
+		slog.Error("error writing manifest file", "path", cfg.ManifestFile, "error", err)
+	}
+	return nil
+}
+
+// algorithmList splits the --algorithm flag, a name, a comma separated
+// list, or all for every registered algorithm.
+func algorithmList(s string) []string {
+	if strings.EqualFold(s, "all") {
+		return s3checksum.AlgorithmNames()
+	}
+	names := []string{}
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// runChecksumAlgorithms checksums a single file, or stdin, with several
+// algorithms in one read and writes one manifest per algorithm, see
+// s3checksum.WriteManifestsByAlgorithm.
+func runChecksumAlgorithms(ctx context.Context, cfg checksumConfig, names []string) (*checksumResult, error) {
+	start := time.Now()
+	if cfg.File == "" || s3checksum.IsGlob(cfg.File) || cfg.BatchFile != "" || cfg.Recursive || cfg.Follow || cfg.TarMember != "" || cfg.Resume != "" || cfg.FD >= 0 || cfg.AppendManifest {
+		return nil, fmt.Errorf("several algorithms need a single --file and can't be combined with --batch, --recursive, --follow, --tar-member, --resume, --fd or --append-manifest")
+	}
+	name := cfg.File
+	if cfg.File != "-" {
+		var err error
+		if name, err = s3checksum.ManifestPath(cfg.File, cfg.PathMode, cfg.BaseDir); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := make([]s3checksum.MultipartFileOpts, len(names))
+	for i, n := range names {
+		algorithm, err := s3checksum.LookupAlgorithm(n)
+		if err != nil {
+			return nil, err
+		}
+		opts[i] = s3checksum.MultipartFileOpts{
+			FilePath:          cfg.File,
+			ManifestName:      name,
+			PartSize:          cfg.PartSize,
+			PartAlignment:     cfg.PartAlignment,
+			Algorithm:         algorithm.Name,
+			IncludeFullObject: !algorithm.S3Compatible || cfg.FullObject,
+			SkipETag:          cfg.SkipETag,
+			ChecksumType:      cfg.ChecksumType,
+		}
+	}
+
+	r := os.Stdin
+	if cfg.File != "-" {
+		f, err := os.Open(s3checksum.LongPath(cfg.File))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	results, err := s3checksum.ChecksumStreams(ctx, r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.ManifestFile == s3checksum.ManifestStdout {
+		err = writeManifest(cfg, results)
+	} else if cfg.ManifestFile != "" {
+		var paths []string
+		paths, err = s3checksum.WriteManifestsByAlgorithm(cfg.ManifestFile, results)
+		if err == nil {
+			slog.Info("wrote a manifest per algorithm", "paths", paths)
+		} else if !cfg.RequireManifest {
+			slog.Error("error writing manifest file", "path", cfg.ManifestFile, "error", err)
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error writing manifest file: %w", err)
+	}
+	return &checksumResult{Manifests: results, Elapsed: time.Since(start)}, nil
+}
+
+// softManifestError logs a failure to write the manifest of a single file
+// run and drops it, so the checksum is still printed, unless the manifest is
+// required. Other errors are returned as they are.
+func softManifestError(cfg checksumConfig, err error) error {
+	if errors.Is(err, s3checksum.ErrWriteManifest) && !cfg.RequireManifest {
+		slog.Error(err.Error())
+		return nil
+	}
+	return err
+}
+
+// parseSince accepts either an RFC 3339 timestamp or a plain date.
+func parseSince(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("--since must be an RFC 3339 timestamp or a YYYY-MM-DD date: %q", s)
+	}
+	return t, nil
+}
+
+// readResumeManifest reads the manifest --resume points to, which must
+// hold a single file.
+func readResumeManifest(path string) (*s3checksum.ManifestFile, error) {
+	mf, err := s3checksum.ReadManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(mf) != 1 {
+		return nil, fmt.Errorf("%s has %d files, a run can only be resumed from the manifest of one file", path, len(mf))
+	}
+	return mf[0], nil
+}
+
+// normalizeChecksumType accepts the --checksum-type values in the form S3
+// uses, FULL_OBJECT, or in lower case with dashes, full-object.
+func normalizeChecksumType(t string) string {
+	return strings.ToUpper(strings.ReplaceAll(t, "-", "_"))
+}
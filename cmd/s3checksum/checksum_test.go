@@ -0,0 +1,142 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	s3checksum "amazon-s3-checksum-tool"
+)
+
+// writeTestFile writes size bytes of seeded random data to name in dir and
+// returns its path.
+func writeTestFile(t *testing.T, dir, name string, size int) string {
+	t.Helper()
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// libraryChecksum is the manifest the library computes for path.
+func libraryChecksum(t *testing.T, path string) *s3checksum.ManifestFile {
+	t.Helper()
+	m, err := s3checksum.NewMultipartFile(s3checksum.MultipartFileOpts{FilePath: path, PartSize: s3checksum.MIN_PART_SIZE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mf, err := m.CalculateChecksum(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mf
+}
+
+func TestRunChecksum(t *testing.T) {
+	dir := t.TempDir()
+	small := writeTestFile(t, dir, "b.bin", 100)
+	large := writeTestFile(t, dir, "a.bin", 2*s3checksum.MIN_PART_SIZE+100)
+
+	tests := []struct {
+		name string
+		cfg  checksumConfig
+		want []string
+	}{
+		{"single file", checksumConfig{File: large}, []string{large}},
+		{"pattern sorted by name", checksumConfig{File: filepath.Join(dir, "*.bin"), SortBy: "name"}, []string{large, small}},
+		{"batch", checksumConfig{BatchFile: writeBatchFile(t, small, large)}, []string{small, large}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Algorithm = "sha256"
+			tt.cfg.PartSize = s3checksum.MIN_PART_SIZE
+			tt.cfg.Threads = 4
+			tt.cfg.FD = -1
+			tt.cfg.ManifestFile = filepath.Join(t.TempDir(), "manifest.json")
+
+			result, err := runChecksum(context.Background(), tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Manifests) != len(tt.want) {
+				t.Fatalf("%d manifests, want %d", len(result.Manifests), len(tt.want))
+			}
+			for i, path := range tt.want {
+				got, want := result.Manifests[i], libraryChecksum(t, path)
+				if got.Filename != path || !bytes.Equal(got.Checksum, want.Checksum) || !bytes.Equal(got.Etag, want.Etag) || len(got.PartList) != len(want.PartList) {
+					t.Errorf("manifest %d is %s %s, want %s %s", i, got.Filename, got.Checksum, path, want.Checksum)
+				}
+			}
+
+			written, err := s3checksum.ReadManifest(tt.cfg.ManifestFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(written) != len(tt.want) {
+				t.Errorf("the manifest file has %d files, want %d", len(written), len(tt.want))
+			}
+		})
+	}
+}
+
+// writeBatchFile writes a batch file listing paths.
+func writeBatchFile(t *testing.T, paths ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "batch.txt")
+	content := ""
+	for _, p := range paths {
+		content += p + "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunChecksumDirectoryNeedsRecursive(t *testing.T) {
+	_, err := runChecksum(context.Background(), checksumConfig{File: t.TempDir(), Algorithm: "sha256", PartSize: s3checksum.MIN_PART_SIZE, FD: -1})
+	if err == nil {
+		t.Error("no error checksumming a directory without --recursive")
+	}
+}
+
+// TestRunChecksumPipeFD checks that a pipe passed with --fd, which has no
+// size, is read front to back and checksums like the same bytes on disk.
+func TestRunChecksumPipeFD(t *testing.T) {
+	path := writeTestFile(t, t.TempDir(), "data", s3checksum.MIN_PART_SIZE+100)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	go func() {
+		w.Write(data)
+		w.Close()
+	}()
+
+	result, err := runChecksum(context.Background(), checksumConfig{
+		Algorithm: "sha256",
+		PartSize:  s3checksum.MIN_PART_SIZE,
+		FD:        int(r.Fd()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, want := result.Manifests[0], libraryChecksum(t, path)
+	if !bytes.Equal(got.Checksum, want.Checksum) || !bytes.Equal(got.Etag, want.Etag) || len(got.PartList) != 2 {
+		t.Errorf("pipe checksum %s with %d parts, want %s with 2", got.Checksum, len(got.PartList), want.Checksum)
+	}
+}
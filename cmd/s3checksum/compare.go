@@ -0,0 +1,46 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	s3checksum "amazon-s3-checksum-tool"
+)
+
+// writeManifestDiff prints d as text, one line per difference and a
+// summary line, or as indented JSON when output is json.
+func writeManifestDiff(w io.Writer, output string, d *s3checksum.ManifestDiff, enc s3checksum.Encoding) error {
+	if output == "json" {
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		return e.Encode(d)
+	}
+
+	for _, f := range d.Added {
+		fmt.Fprintf(w, "ADDED\t%s\n", f)
+	}
+	for _, f := range d.Removed {
+		fmt.Fprintf(w, "REMOVED\t%s\n", f)
+	}
+	for _, c := range d.Changed {
+		fmt.Fprintf(w, "CHANGED\t%s\tbefore %s\tafter %s", c.Filename, c.Before.Encode(enc), c.After.Encode(enc))
+		if c.Reason != "" {
+			fmt.Fprintf(w, "\tparts not compared: %s\n", c.Reason)
+			continue
+		}
+		parts := make([]string, len(c.Parts))
+		for i, p := range c.Parts {
+			parts[i] = strconv.Itoa(int(p))
+		}
+		fmt.Fprintf(w, "\tparts %s\n", strings.Join(parts, ","))
+	}
+	_, err := fmt.Fprintf(w, "Added: %d\tRemoved: %d\tChanged: %d\tUnchanged: %d\n",
+		len(d.Added), len(d.Removed), len(d.Changed), d.Unchanged)
+	return err
+}
@@ -0,0 +1,42 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	s3checksum "amazon-s3-checksum-tool"
+)
+
+// downloadResult is the manifest of a verified download, rendered
+// separately like checksumResult.
+type downloadResult struct {
+	Manifest *s3checksum.ManifestFile
+}
+
+// runDownload downloads an object and verifies the local copy, see
+// s3checksum.Download.
+func runDownload(ctx context.Context, opts s3checksum.DownloadOptions) (*downloadResult, error) {
+	info, err := s3checksum.Download(ctx, &opts)
+	if err != nil {
+		return nil, err
+	}
+	return &downloadResult{Manifest: info}, nil
+}
+
+// render prints the summary of the downloaded object and that it was
+// verified.
+func (r *downloadResult) render(w io.Writer) error {
+	renderer, err := s3checksum.NewRenderer("summary")
+	if err != nil {
+		return err
+	}
+	if err := renderer.Render(w, []*s3checksum.ManifestFile{r.Manifest}); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, "Download verified")
+	return err
+}
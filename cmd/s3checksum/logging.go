@@ -0,0 +1,44 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	s3checksum "amazon-s3-checksum-tool"
+)
+
+// setupLogging sends the diagnostics of the CLI, the standard log package
+// and the s3checksum package to w, as text or as one JSON object per line,
+// at level and above. Text at the info level keeps the default logger.
+func setupLogging(w io.Writer, format, level string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("unknown log level %q, expected debug, info, warn or error", level)
+	}
+	opts := &slog.HandlerOptions{Level: l}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "text":
+		if l == slog.LevelInfo {
+			// The default logger already writes plain text at info
+			return nil
+		}
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format %q, expected text or json", format)
+	}
+
+	// SetDefault also routes the standard log package through handler
+	logger := slog.New(handler)
+	slog.SetDefault(logger)
+	s3checksum.SetLogger(logger)
+	return nil
+}
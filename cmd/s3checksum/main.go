@@ -6,8 +6,12 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
+	"os/signal"
+	"strings"
+	"time"
 
 	s3checksum "amazon-s3-checksum-tool"
 
@@ -21,30 +25,135 @@ func main() {
 	var key string
 	var manifestFile string
 	var threads int
+	var maxMemory int64
 	var chunksize int64
 	var printHex bool
 	var region string
 	var awsProfile string
 	var usePathStyle bool
+	var endpointURL string
+	var roleARN string
+	var roleSessionName string
+	var format string
+	var output string
+	var beforeManifest string
+	var afterManifest string
+	var recursive bool
+	var fitPartLimit bool
+	var verifyETag bool
+	var s3URL string
+	var quiet bool
+	var concurrency int
+	var s3Concurrency int
+	var batchFile string
+	var numParts int
+	var fd int
+	var since string
+	var inventoryFile string
+	var inventorySchema string
+	var keyPrefix string
+	var localRoot string
+	var follow bool
+	var expectedSize int64
+	var doneFile string
+	var writeMetadata bool
+	var rangeStart int64
+	var rangeLength int64
+	var requireManifest bool
+	var algorithm string
+	var leavePartsOnError bool
+	var lastPartOnly bool
+	var sortBy string
+	var partAlignment int64
+	var etag string
+	var candidates string
+	var tarMember string
+	var statusFormat string
+	var metricsFile string
+	var rollingChecksum bool
+	var failFast bool
+	var compositeOnly bool
+	var openOnce bool
+	var partsDir string
+	var expectedChecksum string
+	var maxRetries int
+	var retryBaseDelay time.Duration
+	var uploadTimeout time.Duration
+	var olderThan time.Duration
+	var resumeManifest string
+	var sequential bool
+	var skipETag bool
+	var checksumType string
+	var maxBandwidth int64
+	var appendManifest bool
+	var partNumber int
+	var followSymlinks bool
+	var pathMode string
+	var baseDir string
+	var logFormat string
+	var logLevel string
+	var dryRun bool
+	var sse string
+	var sseKMSKeyID string
+	var sseCKeyFile string
+	var storageClass string
+	var contentType string
 
 	//
 	app := &cli.App{
 		Usage: "CLI utility for S3 concurrent uploads and integrity checking",
+		// --metadata and --tag values may contain commas
+		DisableSliceFlagSeparator: true,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "log-format",
+				Value:       "text",
+				Usage:       "--log-format=json writes diagnostics to stderr as one JSON object per line, with fields such as part_number, bytes and duration_ms",
+				Destination: &logFormat,
+			},
+			&cli.StringFlag{
+				Name:        "log-level",
+				Value:       "info",
+				Usage:       "--log-level=debug also logs every part checksummed; debug, info, warn or error",
+				Destination: &logLevel,
+			},
+		},
+		Before: func(c *cli.Context) error {
+			return setupLogging(os.Stderr, logFormat, logLevel)
+		},
 		Commands: []*cli.Command{
 			{
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:        "file",
 						Value:       "",
-						Usage:       "file",
+						Usage:       "file, a pattern such as 'data/*.parquet' matching several files, or - to read the data from stdin",
 						Destination: &file,
 					},
 					&cli.StringFlag{
 						Name:        "manifest",
 						Value:       "manifest.json",
-						Usage:       "--manifest output.json will generate a json file with all the parts and the checksums so it can be verified later",
+						Usage:       "--manifest output.json will generate a json file with all the parts and the checksums so it can be verified later; --manifest - writes it to stdout",
 						Destination: &manifestFile,
 					},
+					&cli.BoolFlag{
+						Name:        "append-manifest",
+						Value:       false,
+						Usage:       "--append-manifest adds the files to --manifest, replacing entries of the same file, instead of overwriting it; parallel runs can share it",
+						Destination: &appendManifest,
+					},
+					&cli.StringFlag{
+						Name:        "path-mode",
+						Value:       "",
+						Usage:       "--path-mode=relative records files in the manifest relative to --base-dir; absolute records the absolute path and basename only the file name, instead of the path as given",
+						Destination: &pathMode,
+					},
+					&cli.StringFlag{
+						Name:        "base-dir",
+						Value:       ".",
+						Usage:       "--base-dir=/data is the directory --path-mode=relative paths are relative to",
+						Destination: &baseDir,
+					},
 					&cli.Int64Flag{
 						Name:        "chunksize",
 						Value:       64,
@@ -63,44 +172,332 @@ func main() {
 						Usage:       "--threads=10",
 						Destination: &threads,
 					},
+					&cli.Int64Flag{
+						Name:        "max-memory",
+						Value:       0,
+						Usage:       "--max-memory=512 caps the part buffers at 512MB, about --threads x --chunksize, by running fewer threads",
+						Destination: &maxMemory,
+					},
 					&cli.BoolFlag{
 						Name:        "print-hex",
 						Value:       false,
 						Destination: &printHex,
 					},
+					&cli.StringFlag{
+						Name:        "format",
+						Value:       "text",
+						Usage:       "--format=json sets the output format, one of: " + strings.Join(s3checksum.Formats(), ", "),
+						Destination: &format,
+					},
+					&cli.StringFlag{
+						Name:        "output",
+						Value:       "text",
+						Usage:       "--output=json prints the manifests, with every part checksum, as one JSON document; shorthand for --format=json",
+						Destination: &output,
+					},
+					&cli.BoolFlag{
+						Name:        "recursive",
+						Value:       false,
+						Usage:       "--recursive with --file=dir checksums every file under dir into one manifest, with paths relative to dir",
+						Destination: &recursive,
+					},
+					&cli.BoolFlag{
+						Name:        "follow-symlinks",
+						Value:       false,
+						Usage:       "--follow-symlinks with --recursive checksums the files and directories symlinks point to, instead of skipping them",
+						Destination: &followSymlinks,
+					},
+					&cli.BoolFlag{
+						Name:        "fit-part-limit",
+						Value:       false,
+						Usage:       "--fit-part-limit raises --chunksize when the file would need more than the 10,000 parts S3 allows, instead of failing",
+						Destination: &fitPartLimit,
+					},
+					&cli.BoolFlag{
+						Name:        "quiet",
+						Value:       false,
+						Usage:       "--quiet leaves out the per-part checksum lines, which otherwise go to stderr",
+						Destination: &quiet,
+					},
+					&cli.StringFlag{
+						Name:        "batch",
+						Value:       "",
+						Usage:       "--batch files.csv checksums every file listed in a CSV (path,part_size) or JSON batch file, each with its own part size in bytes",
+						Destination: &batchFile,
+					},
+					&cli.IntFlag{
+						Name:        "num-parts",
+						Value:       0,
+						Usage:       "--num-parts=100 picks the part size that splits the file into 100 parts, overriding --chunksize",
+						Destination: &numParts,
+					},
+					&cli.IntFlag{
+						Name:        "fd",
+						Value:       -1,
+						Usage:       "--fd=3 checksums a file descriptor inherited from the parent process instead of --file; pipes are read front to back like --file -",
+						Destination: &fd,
+					},
+					&cli.StringFlag{
+						Name:        "since",
+						Value:       "",
+						Usage:       "--since=2024-01-31 only checksums the --batch, --recursive or --file pattern files modified after the given date or RFC 3339 timestamp",
+						Destination: &since,
+					},
+					&cli.BoolFlag{
+						Name:        "follow",
+						Value:       false,
+						Usage:       "--follow hashes --file while it is still being written, finishing at --expected-size or once --done-file exists",
+						Destination: &follow,
+					},
+					&cli.Int64Flag{
+						Name:        "expected-size",
+						Value:       0,
+						Usage:       "--expected-size=1048576 is the final size in bytes of a --follow file",
+						Destination: &expectedSize,
+					},
+					&cli.StringFlag{
+						Name:        "done-file",
+						Value:       "",
+						Usage:       "--done-file=data.done is created by the writer once a --follow file is complete",
+						Destination: &doneFile,
+					},
+					&cli.BoolFlag{
+						Name:        "require-manifest",
+						Value:       false,
+						Usage:       "--require-manifest exits with an error when the manifest can't be written instead of only logging it",
+						Destination: &requireManifest,
+					},
+					&cli.StringFlag{
+						Name:        "algorithm",
+						Value:       s3checksum.DefaultAlgorithm,
+						Usage:       "--algorithm=sha256 selects the checksum algorithm, one of: " + strings.Join(s3checksum.AlgorithmNames(), ", ") + "; a comma separated list or all checksums a single file with each in one read, writing a manifest per algorithm",
+						Destination: &algorithm,
+					},
+					&cli.BoolFlag{
+						Name:        "last-part-only",
+						Value:       false,
+						Usage:       "--last-part-only hashes only the final part as a quick truncation check; it is not a full integrity check",
+						Destination: &lastPartOnly,
+					},
+					&cli.StringFlag{
+						Name:        "sort-by",
+						Value:       "",
+						Usage:       "--sort-by=checksum orders the --batch manifest by checksum, name or size; checksum puts duplicate files next to each other",
+						Destination: &sortBy,
+					},
+					&cli.Int64Flag{
+						Name:        "part-alignment",
+						Value:       0,
+						Usage:       "--part-alignment=1048576 rounds the part size up to a multiple of this many bytes; this changes the composite checksum, so use the same value everywhere it is compared",
+						Destination: &partAlignment,
+					},
+					&cli.StringFlag{
+						Name:        "tar-member",
+						Value:       "",
+						Usage:       "--tar-member=dir/name checksums one member of the tar or tar.gz archive given by --file without extracting it",
+						Destination: &tarMember,
+					},
+					&cli.StringFlag{
+						Name:        "status-format",
+						Value:       "",
+						Usage:       "--status-format=kv|json ends the output with a status line (status, checksum, parts, bytes, duration) for monitoring",
+						Destination: &statusFormat,
+					},
+					&cli.StringFlag{
+						Name:        "metrics-file",
+						Value:       "",
+						Usage:       "--metrics-file=/var/lib/node_exporter/s3checksum.prom writes Prometheus metrics of the run for the textfile collector",
+						Destination: &metricsFile,
+					},
+					&cli.BoolFlag{
+						Name:        "rolling-checksum",
+						Value:       false,
+						Usage:       "--rolling-checksum also records an Adler-32 rolling checksum per part in json output, to find changed parts cheaply",
+						Destination: &rollingChecksum,
+					},
+					&cli.BoolFlag{
+						Name:        "sequential",
+						Value:       false,
+						Usage:       "--sequential reads the file once front to back instead of --threads parts at a time, which is faster on spinning disks",
+						Destination: &sequential,
+					},
+					&cli.StringFlag{
+						Name:        "checksum-type",
+						Value:       "",
+						Usage:       "--checksum-type=full-object computes one SHA256 over the whole file, without the -N part count, for objects uploaded with ChecksumType FULL_OBJECT",
+						Destination: &checksumType,
+					},
+					&cli.BoolFlag{
+						Name:        "skip-etag",
+						Value:       false,
+						Usage:       "--skip-etag leaves out the MD5 ETag, nearly halving the hashing work when only the checksum is needed",
+						Destination: &skipETag,
+					},
+					&cli.StringFlag{
+						Name:        "resume",
+						Value:       "",
+						Usage:       "--resume=manifest.json reuses the parts an interrupted run wrote to its JSON manifest and only reads the missing ones",
+						Destination: &resumeManifest,
+					},
+					&cli.BoolFlag{
+						Name:        "open-once",
+						Value:       false,
+						Usage:       "--open-once opens the file a single time and reads every part with ReadAt instead of opening it per part",
+						Destination: &openOnce,
+					},
 				},
 				Name:  "checksum",
 				Usage: "checksum",
 				Action: func(c *cli.Context) error {
-					if printHex {
-						s3checksum.PrintHexMode()
-					}
 					if threads < 0 {
-						log.Fatalf("threads must be a positive value. Input value: %d", threads)
+						return fmt.Errorf("threads must be a positive value. Input value: %d", threads)
 					}
-					if file == "" {
-						return fmt.Errorf("--file flag is required")
+					if file == "" && batchFile == "" && fd < 0 {
+						return fmt.Errorf("--file, --fd or --batch flag is required")
 					}
-					mpf, err := s3checksum.NewMultipartFile(s3checksum.MultipartFileOpts{
-						FilePath:         file,
-						ManifestFilePath: manifestFile,
-						PartSize:         chunksize * 1024 * 1024,
-						Threads:          threads,
+					if fd >= 0 && (file != "" || batchFile != "") {
+						return fmt.Errorf("--fd can't be combined with --file or --batch, the descriptor is read instead of the file")
+					}
+					switch output {
+					case "text":
+					case "json":
+						if format != "text" && format != "json" {
+							return fmt.Errorf("--output=json can't be combined with --format=%s", format)
+						}
+						format = "json"
+					default:
+						return fmt.Errorf("unknown output %q, expected text or json", output)
+					}
+					// Per-part lines of the text format go to stderr so
+					// stdout only has the checksums
+					partsW := io.Writer(os.Stderr)
+					if quiet {
+						partsW = io.Discard
+					}
+					renderer, err := s3checksum.NewRendererWithOptions(format, s3checksum.RenderOptions{
+						Encoding:    checksumEncoding(printHex),
+						PartsWriter: partsW,
 					})
 					if err != nil {
 						return err
 					}
-					info, err := mpf.CalculateChecksum(context.Background())
+					if follow && file == "" {
+						return fmt.Errorf("--follow requires --file")
+					}
+					if sortBy != "" && batchFile == "" && !recursive && !s3checksum.IsGlob(file) {
+						return fmt.Errorf("--sort-by requires --batch, --recursive or a --file pattern")
+					}
+					if s3checksum.IsGlob(file) && (follow || tarMember != "" || recursive) {
+						return fmt.Errorf("a --file pattern can't be combined with --follow, --tar-member or --recursive")
+					}
+					if recursive && (file == "" || follow || batchFile != "" || tarMember != "") {
+						return fmt.Errorf("--recursive requires --file and can't be combined with --follow, --batch or --tar-member")
+					}
+					if appendManifest && manifestFile == s3checksum.ManifestStdout {
+						return fmt.Errorf("--append-manifest needs a --manifest file, it can't append to stdout")
+					}
+					if !pathModes[pathMode] {
+						return fmt.Errorf("unknown path mode %q, expected absolute, relative or basename", pathMode)
+					}
+					if pathMode != "" && (file == "-" || fd >= 0 || tarMember != "") {
+						return fmt.Errorf("--path-mode needs files named by path and can't be combined with --file -, --fd or --tar-member")
+					}
+					if followSymlinks && !recursive {
+						return fmt.Errorf("--follow-symlinks requires --recursive")
+					}
+					if !statusFormats[statusFormat] {
+						return fmt.Errorf("unknown status format %q, expected kv or json", statusFormat)
+					}
+					if tarMember != "" && (file == "" || follow || batchFile != "") {
+						return fmt.Errorf("--tar-member requires --file and can't be combined with --follow or --batch")
+					}
+					if tarMember != "" && lastPartOnly {
+						return fmt.Errorf("--last-part-only can't be combined with --tar-member, the member is read front to back")
+					}
+					if follow && partAlignment != 0 {
+						return fmt.Errorf("--part-alignment can't be combined with --follow")
+					}
+					if follow && lastPartOnly {
+						return fmt.Errorf("--last-part-only can't be combined with --follow")
+					}
+					if resumeManifest != "" && (file == "" || file == "-" || s3checksum.IsGlob(file) || follow || batchFile != "" || tarMember != "" || recursive) {
+						return fmt.Errorf("--resume requires a single --file and can't be combined with --follow, --batch, --tar-member or --recursive")
+					}
+					var sinceTime time.Time
+					if since != "" {
+						if batchFile == "" && !s3checksum.IsGlob(file) && !recursive {
+							return fmt.Errorf("--since can only be used with --batch, --recursive or a --file pattern")
+						}
+						if sinceTime, err = parseSince(since); err != nil {
+							return err
+						}
+					}
+					var progress func(completed, total int)
+					if isTerminal(os.Stderr) && !quiet {
+						progress = progressPrinter(os.Stderr)
+					}
+					// An interrupted run stops cleanly and writes the parts it
+					// finished, so it can be picked up with --resume
+					ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+					defer stop()
+					result, err := runChecksum(ctx, checksumConfig{
+						File:            file,
+						Algorithm:       algorithm,
+						FD:              fd,
+						BatchFile:       batchFile,
+						ManifestFile:    manifestFile,
+						AppendManifest:  appendManifest,
+						PathMode:        pathMode,
+						BaseDir:         baseDir,
+						PartSize:        chunksize * 1024 * 1024,
+						TargetParts:     numParts,
+						PartAlignment:   partAlignment,
+						FitPartLimit:    fitPartLimit,
+						Threads:         threads,
+						MaxMemory:       maxMemory * 1024 * 1024,
+						Since:           sinceTime,
+						Follow:          follow,
+						ExpectedSize:    expectedSize,
+						DoneFile:        doneFile,
+						RequireManifest: requireManifest,
+						LastPartOnly:    lastPartOnly,
+						SortBy:          sortBy,
+						FullObject:      s3checksum.NeedsFullObject(format),
+						TarMember:       tarMember,
+						RollingChecksum: rollingChecksum,
+						OpenOnce:        openOnce,
+						Progress:        progress,
+						Recursive:       recursive,
+						FollowSymlinks:  followSymlinks,
+						Resume:          resumeManifest,
+						Sequential:      sequential,
+						SkipETag:        skipETag,
+						ChecksumType:    normalizeChecksumType(checksumType),
+					})
+					if metricsFile != "" {
+						if metricsErr := writeMetrics(metricsFile, newRunStatus(result, err)); metricsErr != nil {
+							slog.Error("error writing metrics file", "path", metricsFile, "error", metricsErr)
+						}
+					}
+					// With --manifest - stdout only has the manifest
+					out := io.Writer(os.Stdout)
+					if manifestFile == s3checksum.ManifestStdout {
+						out = os.Stderr
+					}
 					if err != nil {
+						if statusErr := writeStatus(out, statusFormat, nil, err); statusErr != nil {
+							slog.Error(statusErr.Error())
+						}
 						return err
 					}
 
-					for _, part := range info.PartList {
-						fmt.Printf("Part: %05d\t\t%s\n", part.PartNumber, part.Checksum)
+					if err := renderer.Render(out, result.Manifests); err != nil {
+						return err
 					}
-					fmt.Printf("Amazon S3 SHA256:\t%s-%d\n", info.Checksum, len(info.PartList))
-					fmt.Printf("Amazon S3 Etag:\t%x-%d\n", info.Etag, len(info.PartList))
-					return nil
+					if !quiet {
+						printThroughput(os.Stderr, result)
+					}
+					return writeStatus(out, statusFormat, result, nil)
 				},
 			},
 			{
@@ -117,6 +514,12 @@ func main() {
 						Usage:       "key",
 						Destination: &key,
 					},
+					&cli.StringFlag{
+						Name:        "dest",
+						Value:       "",
+						Usage:       "--dest=s3://bucket/key instead of --bucket and --key; a key ending in / gets the file name appended",
+						Destination: &s3URL,
+					},
 					&cli.StringFlag{
 						Name:        "file",
 						Value:       "",
@@ -126,7 +529,7 @@ func main() {
 					&cli.StringFlag{
 						Name:        "manifest",
 						Value:       "manifest.json",
-						Usage:       "--manifest output.json will generate a json file with all the parts and the checksums so it can be verified later",
+						Usage:       "--manifest output.json will generate a json file with all the parts and the checksums so it can be verified later; --manifest - writes it to stdout",
 						Destination: &manifestFile,
 					},
 					&cli.IntFlag{
@@ -147,6 +550,24 @@ func main() {
 						Usage:       "--use-path-style changes to path-style (old) insteaad of virtual-hosted style (new) s3 hostnames",
 						Destination: &usePathStyle,
 					},
+					&cli.StringFlag{
+						Name:        "endpoint-url",
+						Value:       "",
+						Usage:       "--endpoint-url=http://localhost:9000 talks to an S3-compatible store such as MinIO, usually with --use-path-style",
+						Destination: &endpointURL,
+					},
+					&cli.StringFlag{
+						Name:        "role-arn",
+						Value:       "",
+						Usage:       "--role-arn=arn:aws:iam::123456789012:role/name assumes the role, e.g. for a bucket in another account",
+						Destination: &roleARN,
+					},
+					&cli.StringFlag{
+						Name:        "role-session-name",
+						Value:       "",
+						Usage:       "--role-session-name names the assumed role session",
+						Destination: &roleSessionName,
+					},
 					&cli.StringFlag{
 						Name:        "region",
 						Value:       "us-west-2",
@@ -159,6 +580,116 @@ func main() {
 						Usage:       "",
 						Destination: &awsProfile,
 					},
+					&cli.BoolFlag{
+						Name:        "write-metadata",
+						Value:       false,
+						Usage:       "--write-metadata stores the part size and algorithm as x-amz-meta-part-size and x-amz-meta-algorithm",
+						Destination: &writeMetadata,
+					},
+					&cli.BoolFlag{
+						Name:        "leave-parts-on-error",
+						Value:       false,
+						Usage:       "--leave-parts-on-error keeps uploaded parts when the upload fails so it can be resumed; they are billed until the upload is completed or aborted",
+						Destination: &leavePartsOnError,
+					},
+					&cli.IntFlag{
+						Name:        "max-retries",
+						Value:       0,
+						Usage:       "--max-retries=5 retries each failed request, and then the whole upload, up to 5 times",
+						Destination: &maxRetries,
+					},
+					&cli.DurationFlag{
+						Name:        "retry-base-delay",
+						Value:       0,
+						Usage:       "--retry-base-delay=500ms is the base of the exponential backoff between retries",
+						Destination: &retryBaseDelay,
+					},
+					&cli.StringFlag{
+						Name:        "sse",
+						Value:       "",
+						Usage:       "--sse=aws:kms sets the server-side encryption, AES256, aws:kms or aws:kms:dsse; defaults to aws:kms with --sse-kms-key-id",
+						Destination: &sse,
+					},
+					&cli.StringFlag{
+						Name:        "sse-kms-key-id",
+						Value:       "",
+						Usage:       "--sse-kms-key-id=<key id or ARN> encrypts the object with this KMS key",
+						Destination: &sseKMSKeyID,
+					},
+					&cli.StringFlag{
+						Name:        "sse-c-key",
+						Value:       "",
+						Usage:       "--sse-c-key=key.bin is a file with the AES-256 SSE-C key, as 32 raw bytes or base64",
+						Destination: &sseCKeyFile,
+					},
+					&cli.StringFlag{
+						Name:        "storage-class",
+						Value:       "",
+						Usage:       "--storage-class=GLACIER_IR uploads straight into a storage class instead of STANDARD",
+						Destination: &storageClass,
+					},
+					&cli.StringFlag{
+						Name:        "content-type",
+						Value:       "",
+						Usage:       "--content-type=text/html sets the Content-Type instead of detecting it from the file extension and content",
+						Destination: &contentType,
+					},
+					&cli.StringSliceFlag{
+						Name:  "metadata",
+						Usage: "--metadata key=value stores x-amz-meta-key user metadata, repeat for more keys",
+					},
+					&cli.StringSliceFlag{
+						Name:  "tag",
+						Usage: "--tag key=value sets an object tag, repeat for more tags",
+					},
+					&cli.BoolFlag{
+						Name:        "verify-etag",
+						Value:       false,
+						Usage:       "--verify-etag re-reads the file after the upload and checks its MD5 ETag against the one S3 stored",
+						Destination: &verifyETag,
+					},
+					&cli.BoolFlag{
+						Name:        "recursive",
+						Value:       false,
+						Usage:       "--recursive with --file=dir uploads every file under dir, keyed by its path below dir",
+						Destination: &recursive,
+					},
+					&cli.StringFlag{
+						Name:        "prefix",
+						Value:       "",
+						Usage:       "--prefix=backups/ is prepended to the keys when uploading several files",
+						Destination: &keyPrefix,
+					},
+					&cli.IntFlag{
+						Name:        "concurrency",
+						Value:       4,
+						Usage:       "--concurrency=4 is how many files are uploaded at a time, each with up to --threads connections",
+						Destination: &concurrency,
+					},
+					&cli.StringFlag{
+						Name:        "checksum-type",
+						Value:       "",
+						Usage:       "--checksum-type=full-object uploads the file with a single PutObject, up to 5GB, so S3 stores a full object SHA256 without the -N part count",
+						Destination: &checksumType,
+					},
+					&cli.Int64Flag{
+						Name:        "max-bandwidth",
+						Value:       0,
+						Usage:       "--max-bandwidth=10485760 caps the upload at 10MB/s, in bytes per second, across all parts and files uploaded at a time",
+						Destination: &maxBandwidth,
+					},
+					&cli.DurationFlag{
+						Name:        "timeout",
+						Value:       0,
+						Usage:       "--timeout=30m gives up on a file's upload after 30 minutes and aborts its multipart upload",
+						Destination: &uploadTimeout,
+					},
+					&cli.BoolFlag{
+						Name:        "quiet",
+						Value:       false,
+						Usage:       "--quiet leaves out the per-part checksum lines, which otherwise go to stderr",
+						Destination: &quiet,
+					},
 				},
 				Name:  "upload",
 				Usage: "upload",
@@ -167,18 +698,883 @@ func main() {
 					if file == "" {
 						return fmt.Errorf("--file flag is required")
 					}
-
-					return s3checksum.Upload(context.Background(), &s3checksum.UploadOptions{
-						Bucket:       bucket,
-						Key:          key,
-						NumRoutines:  threads,
-						LocalFile:    file,
-						ManifestFile: manifestFile,
-						PartSize:     chunksize * 1024 * 1024,
-						Region:       region,
-						AWSProfile:   awsProfile,
-						UsePathStyle: usePathStyle,
-					})
+					userMetadata, err := parseKeyValues("metadata", c.StringSlice("metadata"))
+					if err != nil {
+						return err
+					}
+					objectTags, err := parseKeyValues("tag", c.StringSlice("tag"))
+					if err != nil {
+						return err
+					}
+					sseCKey, err := readSSECKey(sseCKeyFile)
+					if err != nil {
+						return err
+					}
+					uploadOpts := s3checksum.UploadOptions{
+						Bucket:                bucket,
+						NumRoutines:           threads,
+						ManifestFile:          manifestFile,
+						PartSize:              chunksize * 1024 * 1024,
+						WriteChecksumMetadata: writeMetadata,
+						LeavePartsOnError:     leavePartsOnError,
+						MaxRetries:            maxRetries,
+						RetryBaseDelay:        retryBaseDelay,
+						ServerSideEncryption:  sse,
+						SSEKMSKeyID:           sseKMSKeyID,
+						SSECustomerKey:        sseCKey,
+						StorageClass:          storageClass,
+						ContentType:           contentType,
+						Metadata:              userMetadata,
+						Tags:                  objectTags,
+						VerifyETag:            verifyETag,
+						Timeout:               uploadTimeout,
+						Quiet:                 quiet,
+						ChecksumType:          normalizeChecksumType(checksumType),
+						MaxBandwidth:          maxBandwidth,
+						ClientOptions: s3checksum.ClientOptions{
+							Region:          region,
+							AWSProfile:      awsProfile,
+							UsePathStyle:    usePathStyle,
+							EndpointURL:     endpointURL,
+							RoleARN:         roleARN,
+							RoleSessionName: roleSessionName,
+						},
+					}
+					// The summary moves to stderr when stdout has the
+					// manifest, the per-part lines of a single file go to
+					// stderr
+					out := io.Writer(os.Stdout)
+					if manifestFile == s3checksum.ManifestStdout {
+						out = os.Stderr
+					}
+					partsW := io.Writer(os.Stderr)
+					if quiet {
+						partsW = io.Discard
+					}
+					var result *uploadResult
+					if recursive || s3checksum.IsGlob(file) {
+						partsW = io.Discard
+						result, err = runUploadAll(context.Background(), uploadAll{
+							UploadOptions: uploadOpts,
+							Pattern:       file,
+							Recursive:     recursive,
+							Key:           key,
+							Dest:          s3URL,
+							KeyPrefix:     keyPrefix,
+							Concurrency:   concurrency,
+						})
+					} else {
+						uploadOpts.LocalFile = file
+						uploadOpts.Bucket, uploadOpts.Key, err = resolveS3URL("dest", s3URL, bucket, key, file)
+						if err != nil {
+							return err
+						}
+						result, err = runUpload(context.Background(), uploadOpts)
+					}
+					if result != nil {
+						if renderErr := result.render(out, partsW); renderErr != nil && err == nil {
+							err = renderErr
+						}
+					}
+					return err
+				},
+			},
+			{
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "bucket",
+						Value:       "",
+						Usage:       "bucket",
+						Destination: &bucket,
+					},
+					&cli.StringFlag{
+						Name:        "key",
+						Value:       "",
+						Usage:       "key",
+						Destination: &key,
+					},
+					&cli.StringFlag{
+						Name:        "source",
+						Value:       "",
+						Usage:       "--source=s3://bucket/key instead of --bucket and --key",
+						Destination: &s3URL,
+					},
+					&cli.StringFlag{
+						Name:        "sse-c-key",
+						Value:       "",
+						Usage:       "--sse-c-key=key.bin is a file with the SSE-C key the object was uploaded with, as 32 raw bytes or base64",
+						Destination: &sseCKeyFile,
+					},
+					&cli.StringFlag{
+						Name:        "file",
+						Value:       "",
+						Usage:       "file",
+						Destination: &file,
+					},
+					&cli.IntFlag{
+						Name:        "threads",
+						Value:       16,
+						Usage:       "--threads=10",
+						Destination: &threads,
+					},
+					&cli.BoolFlag{
+						Name:        "use-path-style",
+						Value:       false,
+						Usage:       "--use-path-style changes to path-style (old) insteaad of virtual-hosted style (new) s3 hostnames",
+						Destination: &usePathStyle,
+					},
+					&cli.StringFlag{
+						Name:        "endpoint-url",
+						Value:       "",
+						Usage:       "--endpoint-url=http://localhost:9000 talks to an S3-compatible store such as MinIO, usually with --use-path-style",
+						Destination: &endpointURL,
+					},
+					&cli.StringFlag{
+						Name:        "role-arn",
+						Value:       "",
+						Usage:       "--role-arn=arn:aws:iam::123456789012:role/name assumes the role, e.g. for a bucket in another account",
+						Destination: &roleARN,
+					},
+					&cli.StringFlag{
+						Name:        "role-session-name",
+						Value:       "",
+						Usage:       "--role-session-name names the assumed role session",
+						Destination: &roleSessionName,
+					},
+					&cli.StringFlag{
+						Name:        "region",
+						Value:       "us-west-2",
+						Usage:       "region",
+						Destination: &region,
+					},
+					&cli.StringFlag{
+						Name:        "profile",
+						Value:       "",
+						Usage:       "",
+						Destination: &awsProfile,
+					},
+				},
+				Name:  "download",
+				Usage: "download an object and verify it against the object's stored checksum",
+				Action: func(c *cli.Context) error {
+					if file == "" {
+						return fmt.Errorf("--file flag is required")
+					}
+					bucket, key, err := resolveS3URL("source", s3URL, bucket, key, "")
+					if err != nil {
+						return err
+					}
+					sseCKey, err := readSSECKey(sseCKeyFile)
+					if err != nil {
+						return err
+					}
+					result, err := runDownload(context.Background(), s3checksum.DownloadOptions{
+						Bucket:         bucket,
+						Key:            key,
+						LocalFile:      file,
+						NumRoutines:    threads,
+						SSECustomerKey: sseCKey,
+						ClientOptions: s3checksum.ClientOptions{
+							Region:          region,
+							AWSProfile:      awsProfile,
+							UsePathStyle:    usePathStyle,
+							EndpointURL:     endpointURL,
+							RoleARN:         roleARN,
+							RoleSessionName: roleSessionName,
+						},
+					})
+					if err != nil {
+						return err
+					}
+					return result.render(os.Stdout)
+				},
+			},
+			{
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "bucket",
+						Value:       "",
+						Usage:       "bucket",
+						Destination: &bucket,
+					},
+					&cli.StringFlag{
+						Name:        "prefix",
+						Value:       "",
+						Usage:       "--prefix=backups/ only aborts uploads of keys under backups/",
+						Destination: &keyPrefix,
+					},
+					&cli.DurationFlag{
+						Name:        "older-than",
+						Value:       24 * time.Hour,
+						Usage:       "--older-than=72h only aborts uploads started more than 72 hours ago, so running uploads are left alone",
+						Destination: &olderThan,
+					},
+					&cli.BoolFlag{
+						Name:        "dry-run",
+						Value:       false,
+						Usage:       "--dry-run lists the uploads that would be aborted without aborting them",
+						Destination: &dryRun,
+					},
+					&cli.BoolFlag{
+						Name:        "use-path-style",
+						Value:       false,
+						Usage:       "--use-path-style changes to path-style (old) insteaad of virtual-hosted style (new) s3 hostnames",
+						Destination: &usePathStyle,
+					},
+					&cli.StringFlag{
+						Name:        "endpoint-url",
+						Value:       "",
+						Usage:       "--endpoint-url=http://localhost:9000 talks to an S3-compatible store such as MinIO, usually with --use-path-style",
+						Destination: &endpointURL,
+					},
+					&cli.StringFlag{
+						Name:        "role-arn",
+						Value:       "",
+						Usage:       "--role-arn=arn:aws:iam::123456789012:role/name assumes the role, e.g. for a bucket in another account",
+						Destination: &roleARN,
+					},
+					&cli.StringFlag{
+						Name:        "role-session-name",
+						Value:       "",
+						Usage:       "--role-session-name names the assumed role session",
+						Destination: &roleSessionName,
+					},
+					&cli.StringFlag{
+						Name:        "region",
+						Value:       "us-west-2",
+						Usage:       "region",
+						Destination: &region,
+					},
+					&cli.StringFlag{
+						Name:        "profile",
+						Value:       "",
+						Usage:       "",
+						Destination: &awsProfile,
+					},
+				},
+				Name:  "abort-incomplete",
+				Usage: "abort the multipart uploads of a bucket that were started long ago and never completed",
+				Action: func(c *cli.Context) error {
+					if bucket == "" {
+						return fmt.Errorf("--bucket flag is required")
+					}
+					aborted, err := s3checksum.AbortIncompleteUploads(context.Background(), &s3checksum.AbortIncompleteOptions{
+						Bucket:    bucket,
+						Prefix:    keyPrefix,
+						OlderThan: olderThan,
+						DryRun:    dryRun,
+						ClientOptions: s3checksum.ClientOptions{
+							Region:          region,
+							AWSProfile:      awsProfile,
+							UsePathStyle:    usePathStyle,
+							EndpointURL:     endpointURL,
+							RoleARN:         roleARN,
+							RoleSessionName: roleSessionName,
+						},
+					})
+					action := "ABORTED"
+					if dryRun {
+						action = "WOULD ABORT"
+					}
+					for _, u := range aborted {
+						fmt.Printf("%s\t%s\t%s\tstarted %s\n", action, u.Key, u.UploadID, u.Initiated.Format(time.RFC3339))
+					}
+					return err
+				},
+			},
+			{
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "manifest",
+						Value:       "manifest.json",
+						Usage:       "--manifest manifest.json is the output of checksum --format json or jsonl",
+						Destination: &manifestFile,
+					},
+					&cli.StringFlag{
+						Name:        "base-dir",
+						Value:       ".",
+						Usage:       "--base-dir=/data is the directory relative paths in the manifest are read from, such as the --file directory of a --recursive manifest",
+						Destination: &baseDir,
+					},
+					&cli.IntFlag{
+						Name:        "threads",
+						Value:       16,
+						Usage:       "--threads=10",
+						Destination: &threads,
+					},
+					&cli.BoolFlag{
+						Name:        "fail-fast",
+						Value:       false,
+						Usage:       "--fail-fast checks parts in order and stops at the first mismatch",
+						Destination: &failFast,
+					},
+					&cli.BoolFlag{
+						Name:        "composite-only",
+						Value:       false,
+						Usage:       "--composite-only checks the stored composite checksums against the stored part checksums without reading the files",
+						Destination: &compositeOnly,
+					},
+					&cli.BoolFlag{
+						Name:        "print-hex",
+						Value:       false,
+						Usage:       "--print-hex prints checksums in hex instead of base64",
+						Destination: &printHex,
+					},
+				},
+				Name:  "verify",
+				Usage: "recompute the checksums of the files in a manifest and report the parts that changed",
+				Action: func(c *cli.Context) error {
+					result, err := runVerify(context.Background(), verifyConfig{
+						Manifest:      manifestFile,
+						BaseDir:       baseDir,
+						Threads:       threads,
+						FailFast:      failFast,
+						CompositeOnly: compositeOnly,
+					})
+					if result != nil {
+						result.render(os.Stdout, checksumEncoding(printHex))
+					}
+					return err
+				},
+			},
+			{
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "before",
+						Value:       "",
+						Usage:       "--before old.json is the manifest of the earlier run",
+						Destination: &beforeManifest,
+					},
+					&cli.StringFlag{
+						Name:        "after",
+						Value:       "",
+						Usage:       "--after new.json is the manifest of the later run",
+						Destination: &afterManifest,
+					},
+					&cli.StringFlag{
+						Name:        "output",
+						Value:       "text",
+						Usage:       "--output=json prints the differences as JSON instead of text",
+						Destination: &output,
+					},
+					&cli.BoolFlag{
+						Name:        "print-hex",
+						Value:       false,
+						Usage:       "--print-hex prints checksums in hex instead of base64",
+						Destination: &printHex,
+					},
+				},
+				Name:  "compare",
+				Usage: "report the files added, removed or changed between two manifests",
+				Action: func(c *cli.Context) error {
+					if beforeManifest == "" || afterManifest == "" {
+						return fmt.Errorf("--before and --after flags are required")
+					}
+					if output != "text" && output != "json" {
+						return fmt.Errorf("unknown output %q, expected text or json", output)
+					}
+					diff, err := s3checksum.CompareManifestFiles(beforeManifest, afterManifest)
+					if err != nil {
+						return err
+					}
+					if err := writeManifestDiff(os.Stdout, output, diff, checksumEncoding(printHex)); err != nil {
+						return err
+					}
+					if !diff.OK() {
+						return fmt.Errorf("manifests differ: %w", s3checksum.ErrChecksumMismatch)
+					}
+					return nil
+				},
+			},
+			{
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "manifest",
+						Value:       "manifest.json",
+						Usage:       "--manifest manifest.json is the manifest written by the checksum command",
+						Destination: &manifestFile,
+					},
+					&cli.StringFlag{
+						Name:        "inventory",
+						Value:       "",
+						Usage:       "--inventory data.csv.gz is an S3 Inventory CSV data file",
+						Destination: &inventoryFile,
+					},
+					&cli.StringFlag{
+						Name:        "schema",
+						Value:       s3checksum.DefaultInventorySchema,
+						Usage:       "--schema is the fileSchema listed in the inventory manifest.json",
+						Destination: &inventorySchema,
+					},
+					&cli.StringFlag{
+						Name:        "prefix",
+						Value:       "",
+						Usage:       "--prefix=my-folder/ is prepended to local file names to form the object key",
+						Destination: &keyPrefix,
+					},
+					&cli.StringFlag{
+						Name:        "root",
+						Value:       "",
+						Usage:       "--root=/data makes local file names relative to /data before forming the object key",
+						Destination: &localRoot,
+					},
+				},
+				Name:  "reconcile",
+				Usage: "reconcile a local manifest against an S3 Inventory report",
+				Action: func(c *cli.Context) error {
+					if inventoryFile == "" {
+						return fmt.Errorf("--inventory flag is required")
+					}
+					report, err := s3checksum.ReconcileInventory(manifestFile, inventoryFile, inventorySchema, keyPrefix, localRoot)
+					if err != nil {
+						return err
+					}
+					printReconcileReport(os.Stdout, report)
+					if !report.OK() {
+						return fmt.Errorf("manifest and inventory differ: %w", s3checksum.ErrChecksumMismatch)
+					}
+					return nil
+				},
+			},
+			{
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "bucket",
+						Value:       "",
+						Usage:       "bucket",
+						Destination: &bucket,
+					},
+					&cli.StringFlag{
+						Name:        "key",
+						Value:       "",
+						Usage:       "key",
+						Destination: &key,
+					},
+					&cli.StringFlag{
+						Name:        "file",
+						Value:       "",
+						Usage:       "file",
+						Destination: &file,
+					},
+					&cli.Int64Flag{
+						Name:        "start",
+						Value:       0,
+						Usage:       "--start=1048576 is the offset in bytes of the range to check",
+						Destination: &rangeStart,
+					},
+					&cli.Int64Flag{
+						Name:        "length",
+						Value:       0,
+						Usage:       "--length=1048576 is the number of bytes to check",
+						Destination: &rangeLength,
+					},
+					&cli.BoolFlag{
+						Name:        "use-path-style",
+						Value:       false,
+						Usage:       "--use-path-style changes to path-style (old) insteaad of virtual-hosted style (new) s3 hostnames",
+						Destination: &usePathStyle,
+					},
+					&cli.StringFlag{
+						Name:        "endpoint-url",
+						Value:       "",
+						Usage:       "--endpoint-url=http://localhost:9000 talks to an S3-compatible store such as MinIO, usually with --use-path-style",
+						Destination: &endpointURL,
+					},
+					&cli.StringFlag{
+						Name:        "role-arn",
+						Value:       "",
+						Usage:       "--role-arn=arn:aws:iam::123456789012:role/name assumes the role, e.g. for a bucket in another account",
+						Destination: &roleARN,
+					},
+					&cli.StringFlag{
+						Name:        "role-session-name",
+						Value:       "",
+						Usage:       "--role-session-name names the assumed role session",
+						Destination: &roleSessionName,
+					},
+					&cli.StringFlag{
+						Name:        "region",
+						Value:       "us-west-2",
+						Usage:       "region",
+						Destination: &region,
+					},
+					&cli.StringFlag{
+						Name:        "profile",
+						Value:       "",
+						Usage:       "",
+						Destination: &awsProfile,
+					},
+				},
+				Name:  "verify-range",
+				Usage: "compare a byte range of a local file with the same range of an S3 object",
+				Action: func(c *cli.Context) error {
+					if file == "" {
+						return fmt.Errorf("--file flag is required")
+					}
+					ctx := context.Background()
+					client, err := s3checksum.NewS3Client(ctx, s3checksum.ClientOptions{
+						Region:          region,
+						AWSProfile:      awsProfile,
+						UsePathStyle:    usePathStyle,
+						EndpointURL:     endpointURL,
+						RoleARN:         roleARN,
+						RoleSessionName: roleSessionName,
+					})
+					if err != nil {
+						return err
+					}
+					check, err := runVerifyRange(ctx, client, rangeConfig{
+						Bucket: bucket,
+						Key:    key,
+						File:   file,
+						Start:  rangeStart,
+						Length: rangeLength,
+					})
+					if check != nil {
+						printRangeCheck(os.Stdout, check)
+					}
+					return err
+				},
+			},
+			{
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "bucket",
+						Value:       "",
+						Usage:       "bucket",
+						Destination: &bucket,
+					},
+					&cli.StringFlag{
+						Name:        "key",
+						Value:       "",
+						Usage:       "key",
+						Destination: &key,
+					},
+					&cli.StringFlag{
+						Name:        "file",
+						Value:       "",
+						Usage:       "file",
+						Destination: &file,
+					},
+					&cli.BoolFlag{
+						Name:        "recursive",
+						Value:       false,
+						Usage:       "--recursive with --file=dir verifies every file under dir against the object keyed by its path below dir, as upload --recursive names them",
+						Destination: &recursive,
+					},
+					&cli.StringFlag{
+						Name:        "prefix",
+						Value:       "",
+						Usage:       "--prefix=backups/ is prepended to the keys when verifying several files",
+						Destination: &keyPrefix,
+					},
+					&cli.IntFlag{
+						Name:        "concurrency",
+						Value:       4,
+						Usage:       "--concurrency=4 is how many files are verified at a time, each read with up to --threads threads",
+						Destination: &concurrency,
+					},
+					&cli.IntFlag{
+						Name:        "s3-concurrency",
+						Value:       0,
+						Usage:       "--s3-concurrency=8 caps the S3 requests in flight when verifying several files, separately from --concurrency; lower it when throttled requests are reported",
+						Destination: &s3Concurrency,
+					},
+					&cli.IntFlag{
+						Name:        "threads",
+						Value:       16,
+						Usage:       "--threads=10",
+						Destination: &threads,
+					},
+					&cli.BoolFlag{
+						Name:        "use-path-style",
+						Value:       false,
+						Usage:       "--use-path-style changes to path-style (old) insteaad of virtual-hosted style (new) s3 hostnames",
+						Destination: &usePathStyle,
+					},
+					&cli.StringFlag{
+						Name:        "endpoint-url",
+						Value:       "",
+						Usage:       "--endpoint-url=http://localhost:9000 talks to an S3-compatible store such as MinIO, usually with --use-path-style",
+						Destination: &endpointURL,
+					},
+					&cli.StringFlag{
+						Name:        "role-arn",
+						Value:       "",
+						Usage:       "--role-arn=arn:aws:iam::123456789012:role/name assumes the role, e.g. for a bucket in another account",
+						Destination: &roleARN,
+					},
+					&cli.StringFlag{
+						Name:        "role-session-name",
+						Value:       "",
+						Usage:       "--role-session-name names the assumed role session",
+						Destination: &roleSessionName,
+					},
+					&cli.StringFlag{
+						Name:        "region",
+						Value:       "us-west-2",
+						Usage:       "region",
+						Destination: &region,
+					},
+					&cli.StringFlag{
+						Name:        "profile",
+						Value:       "",
+						Usage:       "",
+						Destination: &awsProfile,
+					},
+				},
+				Name:  "verify-remote",
+				Usage: "check that a local file matches an S3 object without downloading it",
+				Action: func(c *cli.Context) error {
+					several := recursive || s3checksum.IsGlob(file)
+					if file == "" || bucket == "" || (key == "" && !several) {
+						return fmt.Errorf("--file, --bucket and --key flags are required")
+					}
+					if several && key != "" {
+						return fmt.Errorf("--key can't be used when verifying several files, use --prefix")
+					}
+					if s3Concurrency < 0 {
+						return fmt.Errorf("--s3-concurrency must be a positive value")
+					}
+					ctx := context.Background()
+					client, err := s3checksum.NewS3Client(ctx, s3checksum.ClientOptions{
+						Region:          region,
+						AWSProfile:      awsProfile,
+						UsePathStyle:    usePathStyle,
+						EndpointURL:     endpointURL,
+						RoleARN:         roleARN,
+						RoleSessionName: roleSessionName,
+					})
+					if err != nil {
+						return err
+					}
+					if several {
+						return runVerifyRemoteAll(ctx, client, file, recursive, &s3checksum.VerifyRemoteAllOptions{
+							Bucket:        bucket,
+							KeyPrefix:     keyPrefix,
+							Concurrency:   concurrency,
+							Threads:       threads,
+							S3Concurrency: s3Concurrency,
+						})
+					}
+					check, err := s3checksum.VerifyRemote(ctx, client, bucket, key, file, threads)
+					if err != nil {
+						return err
+					}
+					printRemoteCheck(os.Stdout, check)
+					if !check.OK() {
+						return fmt.Errorf("%s doesn't match s3://%s/%s: %w", file, bucket, key, s3checksum.ErrChecksumMismatch)
+					}
+					return nil
+				},
+			},
+			{
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "dir",
+						Value:       "",
+						Usage:       "--dir=parts/ holds one file per part, named with its part number",
+						Destination: &partsDir,
+					},
+					&cli.StringFlag{
+						Name:        "checksum",
+						Value:       "",
+						Usage:       "--checksum=<checksum>-<parts> is the composite checksum of the original object",
+						Destination: &expectedChecksum,
+					},
+					&cli.StringFlag{
+						Name:        "algorithm",
+						Value:       s3checksum.DefaultAlgorithm,
+						Usage:       "--algorithm=sha256 is the algorithm of --checksum",
+						Destination: &algorithm,
+					},
+					&cli.BoolFlag{
+						Name:        "print-hex",
+						Value:       false,
+						Usage:       "--print-hex prints checksums in hex instead of base64",
+						Destination: &printHex,
+					},
+				},
+				Name:  "verify-parts",
+				Usage: "check that separate part files recombine to an object's composite checksum before reassembling them",
+				Action: func(c *cli.Context) error {
+					if partsDir == "" || expectedChecksum == "" {
+						return fmt.Errorf("--dir and --checksum flags are required")
+					}
+					info, err := s3checksum.VerifyReassembly(partsDir, algorithm, expectedChecksum)
+					if info != nil {
+						renderer, _ := s3checksum.NewRendererWithEncoding("text", checksumEncoding(printHex))
+						if err := renderer.Render(os.Stdout, []*s3checksum.ManifestFile{info}); err != nil {
+							return err
+						}
+					}
+					if err != nil {
+						return err
+					}
+					fmt.Println("Reassembly matches")
+					return nil
+				},
+			},
+			{
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "file",
+						Value:       "",
+						Usage:       "file",
+						Destination: &file,
+					},
+					&cli.Int64Flag{
+						Name:        "chunksize",
+						Value:       64,
+						Usage:       "--chunksize=10 is the part size in MB the file was split with; it must match the --chunksize of the original checksum or upload, or a different byte range is read",
+						Destination: &chunksize,
+					},
+					&cli.IntFlag{
+						Name:        "part",
+						Value:       0,
+						Usage:       "--part=3 is the number of the part to checksum, starting at 1",
+						Destination: &partNumber,
+					},
+					&cli.StringFlag{
+						Name:        "algorithm",
+						Value:       s3checksum.DefaultAlgorithm,
+						Usage:       "--algorithm=sha256 is the checksum algorithm",
+						Destination: &algorithm,
+					},
+					&cli.BoolFlag{
+						Name:        "print-hex",
+						Value:       false,
+						Usage:       "--print-hex prints checksums in hex instead of base64",
+						Destination: &printHex,
+					},
+				},
+				Name:  "checksum-part",
+				Usage: "checksum a single part of a file without reading the rest of it",
+				Action: func(c *cli.Context) error {
+					if file == "" || partNumber < 1 {
+						return fmt.Errorf("--file and --part flags are required")
+					}
+					mpf, err := s3checksum.NewMultipartFile(s3checksum.MultipartFileOpts{
+						FilePath:  file,
+						PartSize:  chunksize * 1024 * 1024,
+						Algorithm: algorithm,
+					})
+					if err != nil {
+						return err
+					}
+					if partNumber > mpf.NumberOfParts {
+						return fmt.Errorf("%s has %d parts of %d bytes, there is no part %d", file, mpf.NumberOfParts, mpf.PartSize, partNumber)
+					}
+					part, err := mpf.CalculateChecksumForPart(context.Background(), int32(partNumber-1))
+					if err != nil {
+						return err
+					}
+					printPart(os.Stdout, part, mpf.PartSize, checksumEncoding(printHex))
+					return nil
+				},
+			},
+			{
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "file",
+						Value:       "",
+						Usage:       "file",
+						Destination: &file,
+					},
+					&cli.StringFlag{
+						Name:        "etag",
+						Value:       "",
+						Usage:       "--etag=<hexmd5>-<parts> is the object's ETag; when empty it is read from --bucket and --key",
+						Destination: &etag,
+					},
+					&cli.StringFlag{
+						Name:        "candidates",
+						Value:       "",
+						Usage:       "--candidates=8,16,64,128 are the part sizes to try in megabytes (default 5,8,16,64,128)",
+						Destination: &candidates,
+					},
+					&cli.StringFlag{
+						Name:        "bucket",
+						Value:       "",
+						Usage:       "bucket",
+						Destination: &bucket,
+					},
+					&cli.StringFlag{
+						Name:        "key",
+						Value:       "",
+						Usage:       "key",
+						Destination: &key,
+					},
+					&cli.BoolFlag{
+						Name:        "use-path-style",
+						Value:       false,
+						Usage:       "--use-path-style changes to path-style (old) insteaad of virtual-hosted style (new) s3 hostnames",
+						Destination: &usePathStyle,
+					},
+					&cli.StringFlag{
+						Name:        "endpoint-url",
+						Value:       "",
+						Usage:       "--endpoint-url=http://localhost:9000 talks to an S3-compatible store such as MinIO, usually with --use-path-style",
+						Destination: &endpointURL,
+					},
+					&cli.StringFlag{
+						Name:        "role-arn",
+						Value:       "",
+						Usage:       "--role-arn=arn:aws:iam::123456789012:role/name assumes the role, e.g. for a bucket in another account",
+						Destination: &roleARN,
+					},
+					&cli.StringFlag{
+						Name:        "role-session-name",
+						Value:       "",
+						Usage:       "--role-session-name names the assumed role session",
+						Destination: &roleSessionName,
+					},
+					&cli.StringFlag{
+						Name:        "region",
+						Value:       "us-west-2",
+						Usage:       "region",
+						Destination: &region,
+					},
+					&cli.StringFlag{
+						Name:        "profile",
+						Value:       "",
+						Usage:       "",
+						Destination: &awsProfile,
+					},
+				},
+				Name:  "probe-part-size",
+				Usage: "find the part size an object was uploaded with by matching its ETag against a local copy",
+				Action: func(c *cli.Context) error {
+					if file == "" {
+						return fmt.Errorf("--file flag is required")
+					}
+					sizes, err := parsePartSizes(candidates)
+					if err != nil {
+						return err
+					}
+					ctx := context.Background()
+					if etag == "" {
+						if bucket == "" || key == "" {
+							return fmt.Errorf("--etag or --bucket and --key flags are required")
+						}
+						etag, err = fetchEtag(ctx, s3checksum.ClientOptions{
+							Region:          region,
+							AWSProfile:      awsProfile,
+							UsePathStyle:    usePathStyle,
+							EndpointURL:     endpointURL,
+							RoleARN:         roleARN,
+							RoleSessionName: roleSessionName,
+						}, bucket, key)
+						if err != nil {
+							return err
+						}
+					}
+					partSize, err := s3checksum.ProbePartSize(ctx, file, etag, sizes)
+					if err != nil {
+						return err
+					}
+					fmt.Printf("Part size:\t%d (%dMB)\n", partSize, partSize/1024/1024)
+					return nil
 				},
 			},
 		},
@@ -186,7 +1582,8 @@ func main() {
 
 	err := app.Run(os.Args)
 	if err != nil {
-		log.Fatal(err)
+		slog.Error(err.Error())
+		os.Exit(s3checksum.ExitCode(err))
 	}
 
 }
@@ -0,0 +1,50 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	s3checksum "amazon-s3-checksum-tool"
+)
+
+// writeMetrics writes the outcome of a run in the Prometheus text format for
+// the node_exporter textfile collector. The file is written next to path and
+// renamed into place so the collector never reads a partial file. The
+// metric names are documented in the README and must stay stable.
+func writeMetrics(path string, s *runStatus) error {
+	errors := 0
+	if s.Status != "ok" {
+		errors = 1
+	}
+
+	var b strings.Builder
+	metric := func(name, help string, value interface{}) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n%s %v\n", name, help, name, name, value)
+	}
+	metric("s3checksum_files_processed", "Files checksummed by the last run.", s.Files)
+	metric("s3checksum_bytes_hashed", "Bytes hashed by the last run.", s.Bytes)
+	metric("s3checksum_parts_computed", "Part checksums computed by the last run.", s.Parts)
+	metric("s3checksum_errors", "Errors in the last run.", errors)
+	metric("s3checksum_duration_seconds", "Duration of the last run in seconds.", s.Duration)
+	metric("s3checksum_last_run_timestamp_seconds", "Unix time the last run finished.", time.Now().Unix())
+
+	tmp, err := os.CreateTemp(s3checksum.LongPath(filepath.Dir(path)), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(b.String()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s3checksum.LongPath(path))
+}
@@ -0,0 +1,22 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	s3checksum "amazon-s3-checksum-tool"
+)
+
+// printPart prints the offset, size, checksum and MD5 of a single part, the
+// MD5 in hex as S3 reports it as the part's ETag.
+func printPart(w io.Writer, part *s3checksum.PartInfo, partSize int64, enc s3checksum.Encoding) {
+	fmt.Fprintf(w, "Part:\t%05d\n", part.PartNumber)
+	fmt.Fprintf(w, "Offset:\t%d\n", int64(part.PartNumber-1)*partSize)
+	fmt.Fprintf(w, "Size:\t%d\n", part.Size)
+	fmt.Fprintf(w, "%s:\t%s\n", strings.ToUpper(part.Algorithm), part.Checksum.Encode(enc))
+	fmt.Fprintf(w, "MD5:\t%x\n", []byte(part.MD5Checksum))
+}
@@ -0,0 +1,51 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	s3checksum "amazon-s3-checksum-tool"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// parsePartSizes parses a comma separated list of part sizes in megabytes,
+// the unit of --chunksize. An empty list means the default candidates.
+func parsePartSizes(s string) ([]int64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	sizes := []int64{}
+	for _, v := range strings.Split(s, ",") {
+		mb, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil || mb <= 0 {
+			return nil, fmt.Errorf("invalid part size %q, expected a number of megabytes", v)
+		}
+		sizes = append(sizes, mb*1024*1024)
+	}
+	return sizes, nil
+}
+
+// fetchEtag returns the ETag of an object with a HeadObject request.
+func fetchEtag(ctx context.Context, opts s3checksum.ClientOptions, bucket, key string) (string, error) {
+	client, err := s3checksum.NewS3Client(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+	out, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return "", err
+	}
+	if out.ETag == nil {
+		return "", fmt.Errorf("s3://%s/%s has no ETag", bucket, key)
+	}
+	return *out.ETag, nil
+}
@@ -0,0 +1,28 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// isTerminal reports whether f is a character device, so a progress line
+// that is redrawn in place won't end up in a log file.
+func isTerminal(f *os.File) bool {
+	fileInfo, err := f.Stat()
+	return err == nil && fileInfo.Mode()&os.ModeCharDevice != 0
+}
+
+// progressPrinter returns a MultipartFileOpts.Progress callback that redraws
+// a parts completed / total line on w, ending it once all parts are done.
+func progressPrinter(w io.Writer) func(completed, total int) {
+	return func(completed, total int) {
+		fmt.Fprintf(w, "\rParts: %d/%d (%d%%)", completed, total, completed*100/total)
+		if completed == total {
+			fmt.Fprintln(w)
+		}
+	}
+}
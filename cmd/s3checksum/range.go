@@ -0,0 +1,45 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	s3checksum "amazon-s3-checksum-tool"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+)
+
+// rangeConfig holds the parsed flags of the verify-range command.
+type rangeConfig struct {
+	Bucket string
+	Key    string
+	File   string
+	Start  int64
+	Length int64
+}
+
+// runVerifyRange compares a range of cfg.File with the same range of the
+// object. Ranges that differ are returned along with an
+// ErrChecksumMismatch error.
+func runVerifyRange(ctx context.Context, client manager.DownloadAPIClient, cfg rangeConfig) (*s3checksum.RangeCheck, error) {
+	check, err := s3checksum.VerifyRange(ctx, client, cfg.Bucket, cfg.Key, cfg.File, cfg.Start, cfg.Length, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !check.Match() {
+		return check, fmt.Errorf("range checksums differ: %w", s3checksum.ErrChecksumMismatch)
+	}
+	return check, nil
+}
+
+// printRangeCheck prints the range and both of its checksums. The remote
+// one is computed from the downloaded bytes, it isn't a checksum S3 stored.
+func printRangeCheck(w io.Writer, check *s3checksum.RangeCheck) {
+	fmt.Fprintf(w, "Range:\t%d-%d\n", check.Start, check.Start+check.Length-1)
+	fmt.Fprintf(w, "Local SHA256:\t%s\n", check.Local)
+	fmt.Fprintf(w, "Downloaded range SHA256:\t%s\n", check.Remote)
+}
@@ -0,0 +1,76 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	s3checksum "amazon-s3-checksum-tool"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// fakeRangeClient serves ranged GetObject requests from object.
+type fakeRangeClient struct {
+	object []byte
+}
+
+func (c *fakeRangeClient) GetObject(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	var start, end int
+	if _, err := fmt.Sscanf(*in.Range, "bytes=%d-%d", &start, &end); err != nil {
+		return nil, err
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(c.object[start : end+1]))}, nil
+}
+
+func TestRunVerifyRange(t *testing.T) {
+	path := writeTestFile(t, t.TempDir(), "data", 1000)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := bytes.Clone(data)
+	changed[600] ^= 0xff
+
+	tests := []struct {
+		name     string
+		object   []byte
+		start    int64
+		mismatch bool
+	}{
+		{"same bytes", data, 500, false},
+		{"changed byte in the range", changed, 500, true},
+		{"changed byte outside the range", changed, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check, err := runVerifyRange(context.Background(), &fakeRangeClient{object: tt.object}, rangeConfig{
+				Bucket: "bucket",
+				Key:    "key",
+				File:   path,
+				Start:  tt.start,
+				Length: 200,
+			})
+			if errors.Is(err, s3checksum.ErrChecksumMismatch) != tt.mismatch {
+				t.Fatalf("err = %v, want a mismatch: %v", err, tt.mismatch)
+			}
+			out := &bytes.Buffer{}
+			printRangeCheck(out, check)
+			local := sha256.Sum256(data[tt.start : tt.start+200])
+			remote := sha256.Sum256(tt.object[tt.start : tt.start+200])
+			want := fmt.Sprintf("Range:\t%d-%d\nLocal SHA256:\t%s\nDownloaded range SHA256:\t%s\n",
+				tt.start, tt.start+199, s3checksum.ByteSlice(local[:]), s3checksum.ByteSlice(remote[:]))
+			if out.String() != want {
+				t.Errorf("output\n%q\nwant\n%q", out, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	s3checksum "amazon-s3-checksum-tool"
+)
+
+func printReconcileReport(w io.Writer, r *s3checksum.ReconcileReport) {
+	for _, m := range r.Mismatched {
+		fmt.Fprintf(w, "MISMATCH\t%s\tlocal %s\tinventory %s\n", m.Key, m.LocalETag, m.InventoryETag)
+	}
+	for _, k := range r.MissingInInventory {
+		fmt.Fprintf(w, "MISSING IN INVENTORY\t%s\n", k)
+	}
+	for _, k := range r.MissingLocally {
+		fmt.Fprintf(w, "MISSING LOCALLY\t%s\n", k)
+	}
+	fmt.Fprintf(w, "Matched: %d\tMismatched: %d\tMissing in inventory: %d\tMissing locally: %d\n",
+		len(r.Matched), len(r.Mismatched), len(r.MissingInInventory), len(r.MissingLocally))
+}
@@ -0,0 +1,85 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	s3checksum "amazon-s3-checksum-tool"
+)
+
+// printRemoteCheck prints the local and remote checksums followed by a pass
+// line, or a fail line with the reason.
+func printRemoteCheck(w io.Writer, check *s3checksum.RemoteCheck) {
+	label := "Etag"
+	if check.Algorithm != "etag" {
+		label = strings.ToUpper(check.Algorithm)
+	}
+	if check.Local != "" {
+		fmt.Fprintf(w, "Local %s:\t%s\n", label, check.Local)
+	}
+	fmt.Fprintf(w, "Amazon S3 %s:\t%s\n", label, check.Remote)
+	if s3checksum.IsArchived(check.StorageClass) {
+		fmt.Fprintf(w, "Storage class:\t%s, verified against the stored %s without reading the object\n", check.StorageClass, strings.ToLower(label))
+	}
+	for _, n := range check.MismatchedParts {
+		fmt.Fprintf(w, "MISMATCH\tpart %05d\toffset %d\n", n, int64(n-1)*check.PartSize)
+	}
+	object := fmt.Sprintf("s3://%s/%s", check.Bucket, check.Key)
+	if check.OK() {
+		fmt.Fprintf(w, "PASS\t%s\t%s\n", check.LocalFile, object)
+		return
+	}
+	fmt.Fprintf(w, "FAIL\t%s\t%s\t%s mismatch: %s\n", check.LocalFile, object, check.Mismatch, check.Reason)
+}
+
+// runVerifyRemoteAll verifies the files under the directory pattern, with
+// recursive, or matching the glob pattern, each against the object it was
+// uploaded to.
+func runVerifyRemoteAll(ctx context.Context, client s3checksum.VerifyRemoteAPIClient, pattern string, recursive bool, opts *s3checksum.VerifyRemoteAllOptions) error {
+	if recursive {
+		files, err := s3checksum.WalkFiles(pattern, false)
+		if err != nil {
+			return err
+		}
+		opts.Files = files
+		opts.Root = pattern
+	} else {
+		entries, err := s3checksum.GlobBatchEntries(pattern)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			opts.Files = append(opts.Files, e.Path)
+		}
+	}
+
+	result, err := s3checksum.VerifyRemoteAll(ctx, client, opts)
+	if result == nil {
+		return err
+	}
+	failed := 0
+	for _, check := range result.Checks {
+		if check == nil {
+			continue
+		}
+		printRemoteCheck(os.Stdout, check)
+		if !check.OK() {
+			failed++
+		}
+	}
+	if result.Throttled > 0 {
+		slog.Warn("S3 throttled requests, setting a lower --s3-concurrency may verify faster", "throttled", result.Throttled, "s3_concurrency", opts.S3Concurrency)
+	}
+	if failed > 0 {
+		err = errors.Join(err, fmt.Errorf("%d of %d files don't match their objects: %w", failed, len(opts.Files), s3checksum.ErrChecksumMismatch))
+	}
+	return err
+}
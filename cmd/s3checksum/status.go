@@ -0,0 +1,90 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// statusFormats are the values --status-format accepts.
+var statusFormats = map[string]bool{"": true, "kv": true, "json": true}
+
+// runStatus is the final status line of a checksum run, for monitoring
+// wrappers that parse stdout.
+type runStatus struct {
+	Status   string  `json:"status"`
+	Error    string  `json:"error,omitempty"`
+	Checksum string  `json:"checksum,omitempty"`
+	Files    int     `json:"files"`
+	Parts    int     `json:"parts"`
+	Bytes    int64   `json:"bytes"`
+	Duration float64 `json:"duration_seconds"`
+}
+
+func newRunStatus(result *checksumResult, runErr error) *runStatus {
+	s := &runStatus{Status: "ok"}
+	if runErr != nil {
+		s.Status = "error"
+		s.Error = runErr.Error()
+	}
+	if result == nil {
+		return s
+	}
+	s.Files = len(result.Manifests)
+	s.Duration = result.Elapsed.Seconds()
+	for _, m := range result.Manifests {
+		s.Parts += len(m.PartList)
+		s.Bytes += m.BytesChecksummed()
+	}
+	if len(result.Manifests) == 1 && !result.Manifests[0].Partial {
+		m := result.Manifests[0]
+		s.Checksum = m.Checksum.String()
+		if len(m.PartList) > 0 {
+			// Single PUT objects have no part count
+			s.Checksum = fmt.Sprintf("%s-%d", s.Checksum, len(m.PartList))
+		}
+	}
+	return s
+}
+
+// writeStatus prints the status line as key=value pairs (kv) or a single
+// JSON object (json). An empty format prints nothing.
+func writeStatus(w io.Writer, format string, result *checksumResult, runErr error) error {
+	s := newRunStatus(result, runErr)
+	switch format {
+	case "":
+		return nil
+	case "json":
+		return json.NewEncoder(w).Encode(s)
+	case "kv":
+		line := fmt.Sprintf("status=%s files=%d parts=%d bytes=%d duration_seconds=%.3f", s.Status, s.Files, s.Parts, s.Bytes, s.Duration)
+		if s.Checksum != "" {
+			line += " checksum=" + s.Checksum
+		}
+		if s.Error != "" {
+			line += " error=" + strconv.Quote(s.Error)
+		}
+		_, err := fmt.Fprintln(w, line)
+		return err
+	}
+	return fmt.Errorf("unknown status format %q, expected kv or json", format)
+}
+
+// printThroughput prints the bytes read by a run, how long it took and the
+// throughput in MB/s.
+func printThroughput(w io.Writer, result *checksumResult) {
+	var bytes int64
+	for _, m := range result.Manifests {
+		bytes += m.BytesChecksummed()
+	}
+	var mbPerSecond float64
+	if seconds := result.Elapsed.Seconds(); seconds > 0 {
+		mbPerSecond = float64(bytes) / 1024 / 1024 / seconds
+	}
+	fmt.Fprintf(w, "Checksummed %d bytes in %s (%.1f MB/s)\n", bytes, result.Elapsed.Round(time.Millisecond), mbPerSecond)
+}
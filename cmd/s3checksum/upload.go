@@ -0,0 +1,164 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	s3checksum "amazon-s3-checksum-tool"
+)
+
+// parseKeyValues parses the key=value arguments of a repeatable flag into a
+// map. Values may themselves contain '='.
+func parseKeyValues(flag string, args []string) (map[string]string, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	m := map[string]string{}
+	for _, arg := range args {
+		k, v, ok := strings.Cut(arg, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("--%s %q must be key=value", flag, arg)
+		}
+		if _, dup := m[k]; dup {
+			return nil, fmt.Errorf("--%s %s is given more than once", flag, k)
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+// resolveS3URL returns the bucket and key of an s3://bucket/key URL given
+// with flag, or bucket and key unchanged when there is no URL. Giving both
+// is an error. When localFile is set, a URL without a key or ending in /
+// gets the base name of localFile appended, like aws s3 cp does.
+func resolveS3URL(flag, s3url, bucket, key, localFile string) (string, string, error) {
+	if s3url == "" {
+		return bucket, key, nil
+	}
+	if bucket != "" || key != "" {
+		return "", "", fmt.Errorf("--%s can't be combined with --bucket or --key", flag)
+	}
+	bucket, key = s3checksum.ExtractBucketAndPath(s3url)
+	if bucket == "" {
+		return "", "", fmt.Errorf("--%s %q is not an s3://bucket/key URL", flag, s3url)
+	}
+	if localFile != "" && (key == "" || strings.HasSuffix(key, "/")) {
+		key += filepath.Base(localFile)
+	}
+	if key == "" {
+		return "", "", fmt.Errorf("--%s %q has no key", flag, s3url)
+	}
+	return bucket, key, nil
+}
+
+// uploadAll holds the flags of an upload of several files.
+type uploadAll struct {
+	s3checksum.UploadOptions
+	// Pattern is a glob pattern, or the directory to upload when
+	// Recursive is set.
+	Pattern   string
+	Recursive bool
+	// Key is the --key flag, which can't be used for several files.
+	Key string
+	// Dest is an s3://bucket/prefix URL, instead of the bucket and
+	// KeyPrefix.
+	Dest        string
+	KeyPrefix   string
+	Concurrency int
+}
+
+// uploadResult is everything the upload command uploaded. Like
+// checksumResult it is rendered separately from the upload.
+type uploadResult struct {
+	Manifests []*s3checksum.ManifestFile
+	// EtagVerified is set when --verify-etag checked the ETag of a single
+	// file upload.
+	EtagVerified bool
+}
+
+// render prints the checksum and ETag of every object to w, and the part
+// checksums to partsW.
+func (r *uploadResult) render(w, partsW io.Writer) error {
+	renderer, err := s3checksum.NewRendererWithOptions("text", s3checksum.RenderOptions{PartsWriter: partsW})
+	if err != nil {
+		return err
+	}
+	if err := renderer.Render(w, r.Manifests); err != nil {
+		return err
+	}
+	if r.EtagVerified {
+		_, err = fmt.Fprintln(w, "Amazon S3 Etag verified")
+	}
+	return err
+}
+
+// runUpload uploads a single file. When the upload succeeded but its ETag
+// doesn't verify, the result is returned along with the error.
+func runUpload(ctx context.Context, opts s3checksum.UploadOptions) (*uploadResult, error) {
+	uploaded, err := s3checksum.UploadWithResult(ctx, &opts)
+	if uploaded == nil {
+		return nil, err
+	}
+	return &uploadResult{
+		Manifests:    []*s3checksum.ManifestFile{uploaded.Manifest},
+		EtagVerified: uploaded.EtagVerified,
+	}, err
+}
+
+// runUploadAll uploads the files matching a pattern, or every file under a
+// directory, with s3checksum.UploadAll. The files that were uploaded are
+// returned along with the error of the ones that failed.
+func runUploadAll(ctx context.Context, cfg uploadAll) (*uploadResult, error) {
+	if cfg.Key != "" {
+		return nil, fmt.Errorf("--key can't be used when uploading several files, use --prefix")
+	}
+	if cfg.Dest != "" {
+		if cfg.Bucket != "" || cfg.KeyPrefix != "" {
+			return nil, fmt.Errorf("--dest can't be combined with --bucket or --prefix")
+		}
+		cfg.Bucket, cfg.KeyPrefix = s3checksum.ExtractBucketAndPath(cfg.Dest)
+		if cfg.Bucket == "" {
+			return nil, fmt.Errorf("--dest %q is not an s3://bucket/prefix URL", cfg.Dest)
+		}
+	}
+
+	opts := &s3checksum.UploadAllOptions{
+		UploadOptions: cfg.UploadOptions,
+		KeyPrefix:     cfg.KeyPrefix,
+		Concurrency:   cfg.Concurrency,
+	}
+	if cfg.Recursive {
+		files, err := s3checksum.WalkFiles(cfg.Pattern, false)
+		if err != nil {
+			return nil, err
+		}
+		opts.Files = files
+		opts.Root = cfg.Pattern
+	} else {
+		entries, err := s3checksum.GlobBatchEntries(cfg.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			opts.Files = append(opts.Files, e.Path)
+		}
+	}
+
+	uploaded, err := s3checksum.UploadAll(ctx, opts)
+	return &uploadResult{Manifests: uploaded}, err
+}
+
+// readSSECKey reads the --sse-c-key file, returning an empty key when the
+// flag isn't set.
+func readSSECKey(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	return s3checksum.ReadSSECustomerKey(path)
+}
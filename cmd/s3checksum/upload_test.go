@@ -0,0 +1,130 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	s3checksum "amazon-s3-checksum-tool"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// fakeUploadClient takes single part uploads with PutObject and answers
+// like S3, with the ETag and the SHA256 of the body.
+type fakeUploadClient struct {
+	bodies map[string][]byte
+}
+
+func (c *fakeUploadClient) PutObject(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	body, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.bodies[*in.Key] = body
+	etag := md5.Sum(body)
+	sum := sha256.Sum256(body)
+	return &s3.PutObjectOutput{
+		ETag:           aws.String(`"` + hex.EncodeToString(etag[:]) + `"`),
+		ChecksumSHA256: aws.String(base64.StdEncoding.EncodeToString(sum[:])),
+	}, nil
+}
+
+func (c *fakeUploadClient) UploadPart(context.Context, *s3.UploadPartInput, ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	return nil, fmt.Errorf("unexpected UploadPart")
+}
+
+func (c *fakeUploadClient) CreateMultipartUpload(context.Context, *s3.CreateMultipartUploadInput, ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	return nil, fmt.Errorf("unexpected CreateMultipartUpload")
+}
+
+func (c *fakeUploadClient) CompleteMultipartUpload(context.Context, *s3.CompleteMultipartUploadInput, ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	return nil, fmt.Errorf("unexpected CompleteMultipartUpload")
+}
+
+func (c *fakeUploadClient) AbortMultipartUpload(context.Context, *s3.AbortMultipartUploadInput, ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	return nil, fmt.Errorf("unexpected AbortMultipartUpload")
+}
+
+func (c *fakeUploadClient) HeadObject(context.Context, *s3.HeadObjectInput, ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	return nil, fmt.Errorf("unexpected HeadObject")
+}
+
+func TestRunUpload(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "data.bin", 1000)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &fakeUploadClient{bodies: map[string][]byte{}}
+
+	result, err := runUpload(context.Background(), s3checksum.UploadOptions{
+		Bucket:      "bucket",
+		Key:         "key",
+		LocalFile:   path,
+		PartSize:    s3checksum.MIN_PART_SIZE,
+		ContentType: "application/octet-stream",
+		Client:      client,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(client.bodies["key"], data) {
+		t.Error("the object isn't the file")
+	}
+
+	out, parts := &bytes.Buffer{}, &bytes.Buffer{}
+	if err := result.render(out, parts); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	etag := md5.Sum(data)
+	want := fmt.Sprintf("Amazon S3 SHA256:\t%s\nAmazon S3 Etag:\t%x\n", base64.StdEncoding.EncodeToString(sum[:]), etag)
+	if out.String() != want {
+		t.Errorf("output %q, want %q", out, want)
+	}
+	if parts.Len() != 0 {
+		t.Errorf("a single PutObject has no parts, printed %q", parts)
+	}
+}
+
+func TestRunUploadAll(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "a", 100)
+	writeTestFile(t, dir, "b", 200)
+	client := &fakeUploadClient{bodies: map[string][]byte{}}
+
+	result, err := runUploadAll(context.Background(), uploadAll{
+		UploadOptions: s3checksum.UploadOptions{
+			Bucket:      "bucket",
+			PartSize:    s3checksum.MIN_PART_SIZE,
+			ContentType: "application/octet-stream",
+			Client:      client,
+		},
+		Pattern:   dir,
+		Recursive: true,
+		KeyPrefix: "backup",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Manifests) != 2 || len(client.bodies["backup/a"]) != 100 || len(client.bodies["backup/b"]) != 200 {
+		t.Errorf("uploaded %d files as %v", len(result.Manifests), client.bodies)
+	}
+
+	if _, err := runUploadAll(context.Background(), uploadAll{Pattern: dir, Recursive: true, Key: "key"}); err == nil {
+		t.Error("no error for --key with several files")
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	s3checksum "amazon-s3-checksum-tool"
+)
+
+// verifyConfig holds the parsed flags of the verify command.
+type verifyConfig struct {
+	Manifest string
+	// BaseDir is the directory relative paths in the manifest are read
+	// from.
+	BaseDir  string
+	Threads  int
+	FailFast bool
+	// CompositeOnly checks the stored composites against the stored part
+	// checksums, without reading the files.
+	CompositeOnly bool
+}
+
+// verifyResult is what the verify command checked, rendered separately
+// like checksumResult.
+type verifyResult struct {
+	// Results are the files that were read again.
+	Results []*s3checksum.VerifyResult
+	// Composites are the files whose stored composite matched their parts,
+	// with CompositeOnly.
+	Composites []*s3checksum.ManifestFile
+}
+
+// runVerify checks the files of cfg.Manifest. The files checked so far are
+// returned along with the error when one fails, which wraps
+// ErrChecksumMismatch when a file no longer matches.
+func runVerify(ctx context.Context, cfg verifyConfig) (*verifyResult, error) {
+	if cfg.CompositeOnly {
+		mf, err := s3checksum.ReadManifest(cfg.Manifest)
+		if err != nil {
+			return nil, err
+		}
+		result := &verifyResult{}
+		for _, m := range mf {
+			if err := s3checksum.CheckManifestComposite(m); err != nil {
+				return result, err
+			}
+			result.Composites = append(result.Composites, m)
+		}
+		return result, nil
+	}
+
+	results, err := s3checksum.VerifyManifestIn(ctx, cfg.Manifest, cfg.BaseDir, cfg.Threads, cfg.FailFast)
+	result := &verifyResult{Results: results}
+	if err != nil {
+		return result, err
+	}
+	for _, r := range results {
+		if !r.OK() {
+			return result, fmt.Errorf("verification failed: %w", s3checksum.ErrChecksumMismatch)
+		}
+	}
+	return result, nil
+}
+
+// render prints a line per file checked, see printVerifyResults.
+func (r *verifyResult) render(w io.Writer, enc s3checksum.Encoding) {
+	for _, m := range r.Composites {
+		fmt.Fprintf(w, "PASS\t%s\tcomposite of %d parts\n", m.Filename, len(m.PartList))
+	}
+	printVerifyResults(w, r.Results, enc)
+}
+
+// printVerifyResults prints every mismatching part and a pass or fail line
+// per file. Checksums are printed in enc.
+func printVerifyResults(w io.Writer, results []*s3checksum.VerifyResult, enc s3checksum.Encoding) {
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(w, "FAIL\t%s\t%v\n", r.Filename, r.Err)
+			continue
+		}
+		for _, m := range r.Mismatches {
+			if m.PartNumber == 0 {
+				fmt.Fprintf(w, "MISMATCH\t%s\texpected %s\tactual %s\n", r.Filename, m.Expected.Encode(enc), m.Actual.Encode(enc))
+				continue
+			}
+			fmt.Fprintf(w, "MISMATCH\t%s\tpart %05d\toffset %d\texpected %s\tactual %s\n", r.Filename, m.PartNumber, m.Offset, m.Expected.Encode(enc), m.Actual.Encode(enc))
+		}
+		if r.OK() {
+			fmt.Fprintf(w, "PASS\t%s\t%d parts\n", r.Filename, r.Parts)
+		} else {
+			fmt.Fprintf(w, "FAIL\t%s\t%d of %d parts differ\n", r.Filename, len(r.Mismatches), r.Parts)
+		}
+	}
+}
+
+// checksumEncoding is the encoding selected by the --print-hex flag.
+func checksumEncoding(printHex bool) s3checksum.Encoding {
+	if printHex {
+		return s3checksum.EncodingHex
+	}
+	return s3checksum.EncodingBase64
+}
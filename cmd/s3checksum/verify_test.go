@@ -0,0 +1,65 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	s3checksum "amazon-s3-checksum-tool"
+)
+
+func TestRunVerify(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestFile(t, dir, "data", 2*s3checksum.MIN_PART_SIZE)
+	manifest := filepath.Join(dir, "manifest.json")
+	if err := s3checksum.WriteManifestFile(manifest, []*s3checksum.ManifestFile{libraryChecksum(t, path)}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := runVerify(context.Background(), verifyConfig{Manifest: manifest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	result.render(out, s3checksum.EncodingHex)
+	if want := "PASS\t" + path + "\t2 parts\n"; out.String() != want {
+		t.Errorf("output %q, want %q", out, want)
+	}
+
+	result, err = runVerify(context.Background(), verifyConfig{Manifest: manifest, CompositeOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	result.render(out, s3checksum.EncodingHex)
+	if want := "PASS\t" + path + "\tcomposite of 2 parts\n"; out.String() != want {
+		t.Errorf("composite only output %q, want %q", out, want)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte("changed"), s3checksum.MIN_PART_SIZE); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	result, err = runVerify(context.Background(), verifyConfig{Manifest: manifest})
+	if !errors.Is(err, s3checksum.ErrChecksumMismatch) {
+		t.Fatalf("err = %v, want ErrChecksumMismatch", err)
+	}
+	out.Reset()
+	result.render(out, s3checksum.EncodingHex)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "MISMATCH\t"+path+"\tpart 00002\toffset 5242880\t") || lines[1] != "FAIL\t"+path+"\t1 of 2 parts differ" {
+		t.Errorf("output of a changed file:\n%s", out)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// ChangedFile is a file whose composite checksum differs between two
+// manifests.
+type ChangedFile struct {
+	Filename string    `json:"filename"`
+	Before   ByteSlice `json:"before"`
+	After    ByteSlice `json:"after"`
+	// Parts are the numbers of the parts that differ. It is empty when the
+	// parts can't be compared, see Reason.
+	Parts []int32 `json:"parts,omitempty"`
+	// Reason explains why the parts weren't compared, e.g. because the
+	// part sizes differ or a manifest has no part list.
+	Reason string `json:"reason,omitempty"`
+}
+
+// ManifestDiff is the outcome of comparing two manifests by file name.
+type ManifestDiff struct {
+	Added     []string       `json:"added"`
+	Removed   []string       `json:"removed"`
+	Changed   []*ChangedFile `json:"changed"`
+	Unchanged int            `json:"unchanged"`
+}
+
+// OK reports whether both manifests hold the same files with the same
+// checksums.
+func (d *ManifestDiff) OK() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// CompareManifests matches the files of before and after by Filename and
+// reports the ones that were added, removed or whose composite checksum
+// changed. For changed files the differing parts are found with
+// ChangedParts.
+func CompareManifests(before, after []*ManifestFile) *ManifestDiff {
+	diff := &ManifestDiff{Added: []string{}, Removed: []string{}, Changed: []*ChangedFile{}}
+
+	old := map[string]*ManifestFile{}
+	for _, m := range before {
+		old[m.Filename] = m
+	}
+
+	seen := map[string]bool{}
+	for _, m := range after {
+		seen[m.Filename] = true
+		prev, ok := old[m.Filename]
+		if !ok {
+			diff.Added = append(diff.Added, m.Filename)
+			continue
+		}
+		if bytes.Equal(prev.Checksum, m.Checksum) && strings.EqualFold(prev.Algorithm, m.Algorithm) && len(prev.PartList) == len(m.PartList) {
+			diff.Unchanged++
+			continue
+		}
+
+		changed := &ChangedFile{Filename: m.Filename, Before: prev.Checksum, After: m.Checksum}
+		if !hasPartChecksums(prev) || !hasPartChecksums(m) {
+			changed.Reason = "the manifest has no part checksums"
+		} else if parts, err := ChangedParts(prev, m); err != nil {
+			changed.Reason = err.Error()
+		} else {
+			changed.Parts = parts
+			// Parts removed from the end don't show up in ChangedParts
+			for n := len(m.PartList) + 1; n <= len(prev.PartList); n++ {
+				changed.Parts = append(changed.Parts, int32(n))
+			}
+		}
+		diff.Changed = append(diff.Changed, changed)
+	}
+
+	for _, m := range before {
+		if !seen[m.Filename] {
+			diff.Removed = append(diff.Removed, m.Filename)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Slice(diff.Changed, func(i, j int) bool {
+		return diff.Changed[i].Filename < diff.Changed[j].Filename
+	})
+	return diff
+}
+
+// hasPartChecksums reports whether every part of m has a checksum, which
+// isn't the case for CSV manifests.
+func hasPartChecksums(m *ManifestFile) bool {
+	for _, p := range m.PartList {
+		if len(p.Checksum) == 0 {
+			return false
+		}
+	}
+	return len(m.PartList) > 0
+}
+
+// CompareManifestFiles loads two manifests written by WriteManifestFile and
+// compares them with CompareManifests.
+func CompareManifestFiles(beforePath, afterPath string) (*ManifestDiff, error) {
+	before, err := readManifestFile(beforePath)
+	if err != nil {
+		return nil, err
+	}
+	after, err := readManifestFile(afterPath)
+	if err != nil {
+		return nil, err
+	}
+	return CompareManifests(before, after), nil
+}
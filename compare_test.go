@@ -0,0 +1,87 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"reflect"
+	"testing"
+)
+
+// partsManifest returns a manifest of name whose parts have the one byte
+// checksums in parts. The composite is the part checksums joined, so it
+// changes with them like a real one.
+func partsManifest(name string, parts ...byte) *ManifestFile {
+	m := &ManifestFile{Filename: name, PartSize: MIN_PART_SIZE, Algorithm: "sha256", Checksum: ByteSlice(parts)}
+	for i, c := range parts {
+		m.PartList = append(m.PartList, &PartInfo{PartNumber: int32(i + 1), Size: MIN_PART_SIZE, Algorithm: "sha256", Checksum: ByteSlice{c}})
+	}
+	return m
+}
+
+func TestCompareManifests(t *testing.T) {
+	noParts := partsManifest("csv.bin", 1, 2)
+	for _, p := range noParts.PartList {
+		p.Checksum = nil
+	}
+	larger := partsManifest("resized.bin", 1, 2)
+	larger.PartSize = 2 * MIN_PART_SIZE
+
+	before := []*ManifestFile{
+		partsManifest("same.bin", 1, 2, 3),
+		partsManifest("removed.bin", 1),
+		partsManifest("middle.bin", 1, 2, 3),
+		partsManifest("truncated.bin", 1, 2, 3, 4),
+		partsManifest("grown.bin", 1, 2),
+		partsManifest("csv.bin", 1, 3),
+		partsManifest("resized.bin", 1, 2, 3),
+	}
+	after := []*ManifestFile{
+		partsManifest("same.bin", 1, 2, 3),
+		partsManifest("added.bin", 1),
+		partsManifest("middle.bin", 1, 9, 3),
+		partsManifest("truncated.bin", 1, 2),
+		partsManifest("grown.bin", 1, 2, 3),
+		noParts,
+		larger,
+	}
+
+	diff := CompareManifests(before, after)
+	if diff.OK() {
+		t.Error("OK() for manifests that differ")
+	}
+	if !reflect.DeepEqual(diff.Added, []string{"added.bin"}) || !reflect.DeepEqual(diff.Removed, []string{"removed.bin"}) || diff.Unchanged != 1 {
+		t.Errorf("added %q removed %q unchanged %d, want added.bin, removed.bin and 1", diff.Added, diff.Removed, diff.Unchanged)
+	}
+
+	want := map[string][]int32{
+		"grown.bin":     {3},
+		"middle.bin":    {2},
+		"truncated.bin": {3, 4},
+		"csv.bin":       nil,
+		"resized.bin":   nil,
+	}
+	if len(diff.Changed) != len(want) {
+		t.Fatalf("%d changed files, want %d", len(diff.Changed), len(want))
+	}
+	for i, c := range diff.Changed {
+		if i > 0 && diff.Changed[i-1].Filename > c.Filename {
+			t.Errorf("changed files aren't sorted: %s before %s", diff.Changed[i-1].Filename, c.Filename)
+		}
+		parts, ok := want[c.Filename]
+		if !ok {
+			t.Errorf("%s reported changed", c.Filename)
+			continue
+		}
+		if !reflect.DeepEqual(c.Parts, parts) {
+			t.Errorf("%s changed parts %v, want %v", c.Filename, c.Parts, parts)
+		}
+		if (parts == nil) != (c.Reason != "") {
+			t.Errorf("%s reason %q, want one only when the parts can't be compared", c.Filename, c.Reason)
+		}
+	}
+
+	if d := CompareManifests(before, before); !d.OK() || d.Unchanged != len(before) {
+		t.Errorf("a manifest compared with itself: %+v", d)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"hash"
+	"sync"
+)
+
+// CompositeAccumulator combines part checksums into the composite checksum
+// and ETag of an object as the parts finish, in any order and from any
+// goroutine. A part is hashed as soon as every part before it has been
+// added, so only the parts that finished ahead of a missing one are held,
+// and the composite never needs the whole part list to be sorted.
+//
+// Window bounds how far ahead of the missing part the producers may get:
+// Reserve blocks a part that is Window or more parts ahead until the gap
+// closes. Calling Reserve before reading a part's data keeps the memory of
+// concurrent readers bounded even when part 5 finishes before part 2. The
+// window has to be at least the number of concurrent producers, or a
+// producer holding the missing part could wait on the others.
+type CompositeAccumulator struct {
+	window int32
+
+	mu       sync.Mutex
+	checksum hash.Hash
+	etag     hash.Hash
+	// next is the number of the first part that hasn't been hashed
+	next    int32
+	pending map[int32]*PartInfo
+	// first is kept for objects of a single part, which have no composite
+	first *PartInfo
+	// advanced is closed, and replaced, whenever next moves
+	advanced chan struct{}
+}
+
+// NewCompositeAccumulator returns an accumulator for parts hashed with
+// hashFun, numbered from 1. A window of 0 or less doesn't limit how far
+// ahead parts may be added.
+func NewCompositeAccumulator(hashFun func() hash.Hash, window int) *CompositeAccumulator {
+	return &CompositeAccumulator{
+		window:   int32(window),
+		checksum: hashFun(),
+		etag:     md5.New(),
+		next:     1,
+		pending:  map[int32]*PartInfo{},
+		advanced: make(chan struct{}),
+	}
+}
+
+// Reserve blocks until partNumber is within the window of the next part to
+// be combined, or ctx is done.
+func (a *CompositeAccumulator) Reserve(ctx context.Context, partNumber int32) error {
+	if a.window <= 0 {
+		return nil
+	}
+	for {
+		a.mu.Lock()
+		if partNumber-a.next < a.window {
+			a.mu.Unlock()
+			return nil
+		}
+		advanced := a.advanced
+		a.mu.Unlock()
+
+		select {
+		case <-advanced:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Add submits the checksum of a part. It is combined right away when it is
+// the next part, along with any later parts that were waiting on it.
+func (a *CompositeAccumulator) Add(part *PartInfo) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if part.PartNumber < 1 {
+		return fmt.Errorf("invalid part number %d, parts are numbered from 1", part.PartNumber)
+	}
+	if part.PartNumber < a.next || a.pending[part.PartNumber] != nil {
+		return fmt.Errorf("part %d was added twice", part.PartNumber)
+	}
+	a.pending[part.PartNumber] = part
+
+	moved := false
+	for p := a.pending[a.next]; p != nil; p = a.pending[a.next] {
+		delete(a.pending, a.next)
+		if a.first == nil {
+			a.first = p
+		}
+		a.checksum.Write(p.Checksum)
+		a.etag.Write(p.MD5Checksum)
+		a.next++
+		moved = true
+	}
+	if moved {
+		close(a.advanced)
+		a.advanced = make(chan struct{})
+	}
+	return nil
+}
+
+// Sum returns the composite checksum and ETag of the parts added so far. An
+// object of a single part has no composite, its checksum and ETag are the
+// part's. It fails while a part is missing before the last one added.
+func (a *CompositeAccumulator) Sum() (checksum, etag ByteSlice, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.pending) > 0 {
+		return nil, nil, fmt.Errorf("part %d is missing, %d later parts are waiting on it", a.next, len(a.pending))
+	}
+	switch a.next {
+	case 1:
+		return nil, nil, fmt.Errorf("no parts to combine")
+	case 2:
+		return a.first.Checksum, a.first.MD5Checksum, nil
+	}
+	return a.checksum.Sum(nil), a.etag.Sum(nil), nil
+}
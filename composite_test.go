@@ -0,0 +1,150 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testParts(n int) []*PartInfo {
+	parts := make([]*PartInfo, n)
+	for i := range parts {
+		data := []byte(fmt.Sprintf("part %d", i+1))
+		sum := sha256.Sum256(data)
+		etag := md5.Sum(data)
+		parts[i] = &PartInfo{PartNumber: int32(i + 1), Checksum: sum[:], MD5Checksum: etag[:]}
+	}
+	return parts
+}
+
+func TestCompositeAccumulatorOutOfOrder(t *testing.T) {
+	for _, tc := range []struct {
+		parts, goroutines, window int
+	}{
+		{parts: 1, goroutines: 1},
+		{parts: 2, goroutines: 2, window: 2},
+		{parts: 100, goroutines: 8},
+		{parts: 100, goroutines: 8, window: 8},
+		{parts: 1000, goroutines: 16, window: 32},
+	} {
+		t.Run(fmt.Sprintf("%d parts %d goroutines window %d", tc.parts, tc.goroutines, tc.window), func(t *testing.T) {
+			parts := testParts(tc.parts)
+			acc := NewCompositeAccumulator(sha256.New, tc.window)
+
+			// Each goroutine takes the next part number, like the workers
+			// of CalculateChecksum, and finishes it after a random delay
+			next := make(chan *PartInfo)
+			go func() {
+				defer close(next)
+				for _, p := range parts {
+					next <- p
+				}
+			}()
+			errs := make(chan error, tc.goroutines)
+			var wg sync.WaitGroup
+			for g := 0; g < tc.goroutines; g++ {
+				wg.Add(1)
+				go func(seed int64) {
+					defer wg.Done()
+					rnd := rand.New(rand.NewSource(seed))
+					for p := range next {
+						if err := acc.Reserve(context.Background(), p.PartNumber); err != nil {
+							errs <- err
+							return
+						}
+						time.Sleep(time.Duration(rnd.Intn(200)) * time.Microsecond)
+						if err := acc.Add(p); err != nil {
+							errs <- err
+							return
+						}
+					}
+				}(int64(g))
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Fatal(err)
+			}
+
+			checksum, etag, err := acc.Sum()
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantChecksum := combineChecksums(parts, sha256.New, func(p *PartInfo) []byte { return p.Checksum })
+			wantEtag := combineChecksums(parts, md5.New, func(p *PartInfo) []byte { return p.MD5Checksum })
+			if len(parts) == 1 {
+				wantChecksum, wantEtag = parts[0].Checksum, parts[0].MD5Checksum
+			}
+			if !bytes.Equal(checksum, wantChecksum) {
+				t.Errorf("checksum %s, want %s", checksum, wantChecksum)
+			}
+			if !bytes.Equal(etag, wantEtag) {
+				t.Errorf("etag %s, want %s", etag, wantEtag)
+			}
+		})
+	}
+}
+
+func TestCompositeAccumulatorReserve(t *testing.T) {
+	parts := testParts(4)
+	acc := NewCompositeAccumulator(sha256.New, 2)
+	if err := acc.Reserve(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// Part 3 is outside the window until part 1 is added
+	reserved := make(chan error)
+	go func() { reserved <- acc.Reserve(context.Background(), 3) }()
+	select {
+	case err := <-reserved:
+		t.Fatalf("part 3 was reserved ahead of part 1: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if err := acc.Add(parts[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-reserved; err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := acc.Reserve(ctx, 4); err != context.Canceled {
+		t.Errorf("Reserve with a cancelled context returned %v", err)
+	}
+}
+
+func TestCompositeAccumulatorErrors(t *testing.T) {
+	parts := testParts(3)
+	acc := NewCompositeAccumulator(sha256.New, 0)
+	if err := acc.Add(&PartInfo{PartNumber: 0}); err == nil {
+		t.Error("part 0 was accepted")
+	}
+	if err := acc.Add(parts[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := acc.Add(parts[1]); err == nil {
+		t.Error("part 2 was added twice")
+	}
+	if _, _, err := acc.Sum(); err == nil {
+		t.Error("Sum succeeded with part 1 missing")
+	}
+	if err := acc.Add(parts[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := acc.Add(parts[0]); err == nil {
+		t.Error("part 1 was added again after it was combined")
+	}
+	if _, _, err := acc.Sum(); err != nil {
+		t.Error(err)
+	}
+}
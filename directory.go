@@ -0,0 +1,134 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// WalkFiles returns the paths of the regular files under dir and all its
+// subdirectories, sorted so manifests of the same tree are diffable.
+// Symlinks are skipped unless followSymlinks is set, when the files and
+// directories they point to are included under the symlink's path. A
+// directory reached a second time, through a symlink cycle or another link
+// to it, is skipped. Other special files are always skipped.
+func WalkFiles(dir string, followSymlinks bool) ([]string, error) {
+	w := &fileWalker{follow: followSymlinks, visited: map[string]bool{}}
+	if err := w.walk(dir, LongPath(dir)); err != nil {
+		return nil, err
+	}
+	sort.Strings(w.paths)
+	return w.paths, nil
+}
+
+type fileWalker struct {
+	follow bool
+	// visited holds the real paths of the directories walked so far, when
+	// following symlinks
+	visited map[string]bool
+	paths   []string
+}
+
+// walk adds the files under the directory at actual, named as if they were
+// under dir. The two differ for a symlinked directory, which WalkDir
+// doesn't descend into, so its target is walked instead.
+func (w *fileWalker) walk(dir, actual string) error {
+	return filepath.WalkDir(actual, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(actual, p)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dir, rel)
+
+		switch {
+		case d.IsDir() && w.follow:
+			real, err := filepath.EvalSymlinks(p)
+			if err != nil {
+				return err
+			}
+			if w.visited[real] {
+				logger().Warn("skipping directory already walked through another symlink", "path", path, "target", real)
+				return filepath.SkipDir
+			}
+			w.visited[real] = true
+		case d.Type().IsRegular():
+			w.paths = append(w.paths, path)
+		case d.Type()&fs.ModeSymlink != 0 && w.follow:
+			return w.followLink(path, p)
+		}
+		return nil
+	})
+}
+
+// followLink adds the file, or the files under the directory, that the
+// symlink at actual points to.
+func (w *fileWalker) followLink(path, actual string) error {
+	info, err := os.Stat(LongPath(actual))
+	if err != nil {
+		logger().Warn("skipping broken symlink", "path", path, "error", err)
+		return nil
+	}
+	switch {
+	case info.Mode().IsRegular():
+		w.paths = append(w.paths, path)
+	case info.IsDir():
+		real, err := filepath.EvalSymlinks(actual)
+		if err != nil {
+			return err
+		}
+		return w.walk(path, LongPath(real))
+	}
+	return nil
+}
+
+// ChecksumDirectory checksums every file WalkFiles finds under dir with
+// ChecksumBatch, using opts as the template for each file. Each manifest's
+// Filename is the path relative to dir, and the manifests are in the order
+// of WalkFiles.
+func ChecksumDirectory(ctx context.Context, dir string, followSymlinks bool, opts MultipartFileOpts) ([]*ManifestFile, error) {
+	entries, err := DirectoryEntries(dir, followSymlinks)
+	if err != nil {
+		return nil, err
+	}
+	return ChecksumDirectoryEntries(ctx, dir, entries, opts)
+}
+
+// DirectoryEntries returns a batch entry for every file WalkFiles finds
+// under dir, to be filtered, e.g. with FilterModifiedSince, before
+// ChecksumDirectoryEntries.
+func DirectoryEntries(dir string, followSymlinks bool) ([]*BatchEntry, error) {
+	paths, err := WalkFiles(dir, followSymlinks)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*BatchEntry, len(paths))
+	for i, p := range paths {
+		entries[i] = &BatchEntry{Path: p}
+	}
+	return entries, nil
+}
+
+// ChecksumDirectoryEntries is ChecksumDirectory for entries of files under
+// dir, see DirectoryEntries.
+func ChecksumDirectoryEntries(ctx context.Context, dir string, entries []*BatchEntry, opts MultipartFileOpts) ([]*ManifestFile, error) {
+	results, err := ChecksumBatch(ctx, entries, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range results {
+		rel, err := filepath.Rel(dir, m.Filename)
+		if err != nil {
+			return nil, err
+		}
+		m.Filename = rel
+	}
+	return results, nil
+}
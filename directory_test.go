@@ -0,0 +1,108 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChecksumDirectorySince(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"old", "sub/new"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	since := time.Now().Add(-time.Hour)
+	old := since.Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "old"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := DirectoryEntries(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries, err = FilterModifiedSince(entries, since); err != nil {
+		t.Fatal(err)
+	}
+	results, err := ChecksumDirectoryEntries(context.Background(), dir, entries, MultipartFileOpts{PartSize: MIN_PART_SIZE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Filename != filepath.Join("sub", "new") {
+		t.Errorf("got %d manifests, want only sub/new", len(results))
+		for _, m := range results {
+			t.Log(m.Filename)
+		}
+	}
+}
+
+// TestWalkFilesSymlinkLoop walks a tree with symlinks back to its root, to
+// itself and to a sibling directory. The walk must end and list every file
+// once.
+func TestWalkFilesSymlinkLoop(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "a"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"top.txt", filepath.Join("a", "file.txt")} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		filepath.Join("a", "loop"): "..",
+		"self":                     ".",
+		"b":                        "a",
+		"link.txt":                 filepath.Join("a", "file.txt"),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+			t.Skipf("can't create symlinks: %v", err)
+		}
+	}
+
+	tests := []struct {
+		follow bool
+		want   []string
+	}{
+		{false, []string{filepath.Join("a", "file.txt"), "top.txt"}},
+		{true, []string{filepath.Join("a", "file.txt"), "link.txt", "top.txt"}},
+	}
+	for _, tt := range tests {
+		done := make(chan struct{})
+		var paths []string
+		var err error
+		go func() {
+			defer close(done)
+			paths, err = WalkFiles(dir, tt.follow)
+		}()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("follow %v: the walk didn't end", tt.follow)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, len(paths))
+		for i, p := range paths {
+			if got[i], err = filepath.Rel(dir, p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("follow %v: walked %q, want each of %q once", tt.follow, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// CorruptSuffix is appended to the name of a download whose checksum
+// doesn't match the object.
+const CorruptSuffix = ".corrupt"
+
+type DownloadOptions struct {
+	Bucket      string
+	Key         string
+	LocalFile   string
+	NumRoutines int
+	// SSECustomerKey is the base64 encoded SSE-C key the object was
+	// uploaded with, see UploadOptions.SSECustomerKey.
+	SSECustomerKey string
+	ClientOptions
+}
+
+// Download fetches an object with the S3 download manager and verifies the
+// local copy against the object's stored checksum, recomputed with the
+// object's part size. A copy that doesn't match is renamed with
+// CorruptSuffix and an error is returned. Objects uploaded with parts of
+// different sizes can't be verified this way and return ErrIrregularParts.
+// Archived objects that haven't been restored return ErrObjectNotRestored
+// before anything is downloaded; VerifyRemote can still check them.
+func Download(ctx context.Context, opts *DownloadOptions) (*ManifestFile, error) {
+	var sseC *sseCustomerKey
+	if opts.SSECustomerKey != "" {
+		var err error
+		if sseC, err = newSSECustomerKey(opts.SSECustomerKey); err != nil {
+			return nil, err
+		}
+	}
+
+	client, err := NewS3Client(ctx, opts.ClientOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := fetchObjectAttributes(ctx, client, opts.Bucket, opts.Key, sseC)
+	if err != nil {
+		return nil, err
+	}
+	if IsArchived(remote.StorageClass) {
+		if err := checkRestored(ctx, client, opts.Bucket, opts.Key, sseC); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.Create(LongPath(opts.LocalFile))
+	if err != nil {
+		return nil, err
+	}
+	if opts.NumRoutines == 0 {
+		opts.NumRoutines = 16
+	}
+	downloader := manager.NewDownloader(client, func(d *manager.Downloader) {
+		d.Concurrency = opts.NumRoutines
+	})
+
+	logger().Info("beginning download", "bucket", opts.Bucket, "key", opts.Key)
+	input := &s3.GetObjectInput{
+		Bucket: &opts.Bucket,
+		Key:    &opts.Key,
+	}
+	if sseC != nil {
+		input.SSECustomerAlgorithm = &sseC.Algorithm
+		input.SSECustomerKey = &sseC.Key
+		input.SSECustomerKeyMD5 = &sseC.KeyMD5
+	}
+	_, err = downloader.Download(ctx, f, input)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	local, err := checksumLocalCopy(ctx, opts.LocalFile, opts.NumRoutines, remote)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(local.Checksum, remote.Checksum) {
+		corrupt := opts.LocalFile + CorruptSuffix
+		if err := os.Rename(LongPath(opts.LocalFile), LongPath(corrupt)); err != nil {
+			return local, err
+		}
+		return local, withCategory(ErrChecksumMismatch, fmt.Errorf("downloaded %s checksum %s doesn't match s3://%s/%s checksum %s, kept as %s",
+			algorithmLabel(remote.Algorithm), local.Checksum, opts.Bucket, opts.Key, remote.Checksum, corrupt))
+	}
+	return local, nil
+}
+
+// checksumLocalCopy computes the checksum of the file at path the way S3
+// computed the one of remote, with the object's algorithm and part size.
+func checksumLocalCopy(ctx context.Context, path string, threads int, remote *ObjectAttributes) (*ManifestFile, error) {
+	a, err := LookupAlgorithm(remote.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if remote.Size == 0 {
+		// An empty object has the checksum of zero bytes
+		return &ManifestFile{Filename: path, Algorithm: a.Name, Checksum: a.HashFun().Sum(nil)}, nil
+	}
+
+	mpfOpts := MultipartFileOpts{
+		FilePath: path,
+		Threads:  threads,
+	}
+	if err := mpfOpts.MatchObject(remote); err != nil {
+		return nil, err
+	}
+	mpf, err := NewMultipartFile(mpfOpts)
+	if err != nil {
+		return nil, err
+	}
+	local, err := mpf.CalculateChecksum(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if remote.Parts == 1 && len(local.PartList) == 0 {
+		// A multipart upload of a single part still has a composite
+		part := &PartInfo{PartNumber: 1, Size: local.Size, Algorithm: a.Name, Checksum: local.Checksum}
+		local.PartList = []*PartInfo{part}
+		local.Checksum = combineChecksums(local.PartList, a.HashFun, func(p *PartInfo) []byte { return p.Checksum })
+	}
+	return local, nil
+}
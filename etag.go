@@ -0,0 +1,86 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ErrEtagNotMD5 is returned by VerifyObjectEtag for objects encrypted with
+// SSE-KMS or SSE-C, whose ETag isn't derived from the MD5 of the data.
+var ErrEtagNotMD5 = errors.New("the ETag of an SSE-KMS or SSE-C encrypted object isn't an MD5 of its data")
+
+// VerifyObjectEtag compares the ETag S3 stored for an object with the one
+// computed from the local file. The part size and count are read from a
+// HeadObject of part 1, so multipart objects are compared with their -N
+// suffix whatever part size the upload used.
+func VerifyObjectEtag(ctx context.Context, client s3.HeadObjectAPIClient, bucket, key, localPath string) error {
+	out, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:     &bucket,
+		Key:        &key,
+		PartNumber: aws.Int32(1),
+	})
+	if err != nil {
+		return err
+	}
+	if out.ETag == nil {
+		return fmt.Errorf("s3://%s/%s has no ETag", bucket, key)
+	}
+	switch out.ServerSideEncryption {
+	case types.ServerSideEncryptionAwsKms, types.ServerSideEncryptionAwsKmsDsse:
+		return ErrEtagNotMD5
+	}
+	if out.SSECustomerAlgorithm != nil {
+		return ErrEtagNotMD5
+	}
+
+	remote, err := convertS3EtagToBytes(*out.ETag)
+	if err != nil {
+		return err
+	}
+	parts := 0
+	if out.PartsCount != nil {
+		parts = int(*out.PartsCount)
+	}
+
+	var local []byte
+	if parts == 0 {
+		local, err = fileMD5(localPath)
+	} else {
+		local, err = multipartEtag(ctx, localPath, aws.ToInt64(out.ContentLength))
+	}
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(local, remote) {
+		return withCategory(ErrChecksumMismatch, fmt.Errorf("s3://%s/%s ETag %s doesn't match %s computed from %s",
+			bucket, key, formatEtag(remote, parts), formatEtag(local, parts), localPath))
+	}
+	return nil
+}
+
+// fileMD5 is the ETag of an object uploaded with a single PUT.
+func fileMD5(path string) ([]byte, error) {
+	f, err := os.Open(LongPath(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,78 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// fakeHeadClient answers HeadObject of part 1 of an object uploaded with
+// partSize byte parts, or with a single PUT when partSize is 0.
+type fakeHeadClient struct {
+	object   []byte
+	partSize int
+	sse      types.ServerSideEncryption
+	// partNumbers are the PartNumbers of the requests
+	partNumbers []int32
+}
+
+func (c *fakeHeadClient) HeadObject(ctx context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	c.partNumbers = append(c.partNumbers, aws.ToInt32(in.PartNumber))
+	out := &s3.HeadObjectOutput{ServerSideEncryption: c.sse}
+	if c.partSize == 0 {
+		sum := md5.Sum(c.object)
+		out.ETag = aws.String(fmt.Sprintf(`"%x"`, sum))
+		out.ContentLength = aws.Int64(int64(len(c.object)))
+		return out, nil
+	}
+	etags := md5.New()
+	parts := 0
+	for start := 0; start < len(c.object); start += c.partSize {
+		sum := md5.Sum(c.object[start:min(start+c.partSize, len(c.object))])
+		etags.Write(sum[:])
+		parts++
+	}
+	out.ETag = aws.String(fmt.Sprintf(`"%x-%d"`, etags.Sum(nil), parts))
+	out.PartsCount = aws.Int32(int32(parts))
+	out.ContentLength = aws.Int64(int64(min(c.partSize, len(c.object))))
+	return out, nil
+}
+
+func TestVerifyObjectEtag(t *testing.T) {
+	path, data := writeTestFile(t, 2*MIN_PART_SIZE+100)
+	changed := append([]byte{}, data...)
+	changed[MIN_PART_SIZE+1] ^= 0xff
+
+	tests := []struct {
+		name    string
+		client  *fakeHeadClient
+		wantErr error
+	}{
+		{"single part", &fakeHeadClient{object: data}, nil},
+		{"single part changed", &fakeHeadClient{object: changed}, ErrChecksumMismatch},
+		{"multipart", &fakeHeadClient{object: data, partSize: MIN_PART_SIZE}, nil},
+		{"multipart with larger parts", &fakeHeadClient{object: data, partSize: MIN_PART_SIZE + 4096}, nil},
+		{"multipart changed", &fakeHeadClient{object: changed, partSize: MIN_PART_SIZE}, ErrChecksumMismatch},
+		{"SSE-KMS", &fakeHeadClient{object: data, partSize: MIN_PART_SIZE, sse: types.ServerSideEncryptionAwsKms}, ErrEtagNotMD5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyObjectEtag(context.Background(), tt.client, "bucket", "key", path)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil) != (err == nil) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if len(tt.client.partNumbers) != 1 || tt.client.partNumbers[0] != 1 {
+				t.Errorf("HeadObject of parts %v, want only part 1", tt.client.partNumbers)
+			}
+		})
+	}
+}
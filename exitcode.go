@@ -0,0 +1,74 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// Exit codes of the s3checksum command, see ExitCode. They are a stable
+// contract for scripts: new categories get new codes, existing ones keep
+// theirs.
+const (
+	// ExitOK means every check passed.
+	ExitOK = 0
+	// ExitError is any failure not covered by a more specific code, such
+	// as invalid flags or S3 errors.
+	ExitError = 1
+	// ExitChecksumMismatch means the data was read but a checksum or ETag
+	// didn't match, i.e. the data differs or is corrupted.
+	ExitChecksumMismatch = 2
+	// ExitFileNotFound means a file or manifest given doesn't exist.
+	ExitFileNotFound = 3
+	// ExitInvalidManifest means a manifest couldn't be parsed.
+	ExitInvalidManifest = 4
+)
+
+var (
+	// ErrChecksumMismatch is matched with errors.Is by the errors of
+	// checks that found data not matching its checksum.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrInvalidManifest is matched with errors.Is by the errors of
+	// manifests that can't be parsed.
+	ErrInvalidManifest = errors.New("invalid manifest")
+)
+
+// ExitCode maps err to the exit code of its category.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, ErrChecksumMismatch):
+		return ExitChecksumMismatch
+	case errors.Is(err, ErrInvalidManifest):
+		return ExitInvalidManifest
+	case errors.Is(err, fs.ErrNotExist):
+		return ExitFileNotFound
+	}
+	return ExitError
+}
+
+// categoryError makes errors.Is match category for err while keeping the
+// message of err.
+type categoryError struct {
+	category error
+	err      error
+}
+
+func (e *categoryError) Error() string {
+	return e.err.Error()
+}
+
+func (e *categoryError) Unwrap() error {
+	return e.err
+}
+
+func (e *categoryError) Is(target error) bool {
+	return target == e.category
+}
+
+func withCategory(category, err error) error {
+	return &categoryError{category: category, err: err}
+}
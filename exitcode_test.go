@@ -0,0 +1,58 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	path, _ := writeTestFile(t, MIN_PART_SIZE+100)
+	dir := t.TempDir()
+	garbage := filepath.Join(dir, "garbage.json")
+	if err := os.WriteFile(garbage, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	verify := func(mf *ManifestFile) error {
+		_, err := VerifyManifestFile(context.Background(), mf, 1, false)
+		return err
+	}
+	verifyManifest := func(path string) error {
+		_, err := VerifyManifest(context.Background(), path, 1, false)
+		return err
+	}
+	mismatch := withCategory(ErrChecksumMismatch, errors.New("checksums differ"))
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"no error", nil, ExitOK},
+		{"other error", errors.New("access denied"), ExitError},
+		{"checksum mismatch", mismatch, ExitChecksumMismatch},
+		{"wrapped mismatch", fmt.Errorf("verification failed: %w", mismatch), ExitChecksumMismatch},
+		{"size changed", verify(&ManifestFile{Filename: path, PartSize: MIN_PART_SIZE, Size: 1}), ExitChecksumMismatch},
+		{"part count changed", verify(&ManifestFile{Filename: path, PartSize: MIN_PART_SIZE, PartList: []*PartInfo{{PartNumber: 1}}}), ExitChecksumMismatch},
+		{"missing file", verify(&ManifestFile{Filename: filepath.Join(dir, "missing"), PartSize: MIN_PART_SIZE}), ExitFileNotFound},
+		{"missing manifest", verifyManifest(filepath.Join(dir, "missing.json")), ExitFileNotFound},
+		{"unparsable manifest", verifyManifest(garbage), ExitInvalidManifest},
+		{"unknown algorithm", verify(&ManifestFile{Filename: path, PartSize: MIN_PART_SIZE, Algorithm: "md4"}), ExitInvalidManifest},
+		{"mismatch and missing file", errors.Join(&fs.PathError{Op: "open", Path: "x", Err: fs.ErrNotExist}, mismatch), ExitChecksumMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
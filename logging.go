@@ -0,0 +1,27 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"log/slog"
+	"sync/atomic"
+)
+
+var packageLogger atomic.Pointer[slog.Logger]
+
+// SetLogger sends the diagnostics of the package, such as retries, per-part
+// timings and manifests that couldn't be written, to l. By default they go
+// to slog.Default, which writes plain text through the standard log
+// package. Passing nil restores the default.
+func SetLogger(l *slog.Logger) {
+	packageLogger.Store(l)
+}
+
+// logger returns the logger set with SetLogger, or slog.Default.
+func logger() *slog.Logger {
+	if l := packageLogger.Load(); l != nil {
+		return l
+	}
+	return slog.Default()
+}
@@ -0,0 +1,11 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package s3checksum
+
+// LongPath returns path unchanged; only Windows limits path length.
+func LongPath(path string) string {
+	return path
+}
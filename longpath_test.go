@@ -0,0 +1,63 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestLongPaths checksums a file and writes and reads its manifests at a
+// path longer than the legacy Windows MAX_PATH of 260 characters.
+func TestLongPaths(t *testing.T) {
+	_, data := writeTestFile(t, MIN_PART_SIZE+100)
+	dir := filepath.Join(t.TempDir(), strings.Repeat("nested-directory"+string(filepath.Separator), 20))
+	if err := os.MkdirAll(LongPath(dir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "data.bin")
+	if len(path) <= 260 {
+		t.Fatalf("%d character path isn't over the limit", len(path))
+	}
+	if err := os.WriteFile(LongPath(path), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewMultipartFile(MultipartFileOpts{FilePath: path, PartSize: MIN_PART_SIZE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := m.CalculateChecksum(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.PartList) != 2 {
+		t.Errorf("%d parts, want 2", len(manifest.PartList))
+	}
+
+	if _, err := ChecksumFileRange(path, 0, 100, nil); err != nil {
+		t.Error(err)
+	}
+
+	for _, name := range []string{"manifest.json", "manifest.csv"} {
+		manifestPath := filepath.Join(dir, name)
+		if err := WriteManifestFile(manifestPath, []*ManifestFile{manifest}); err != nil {
+			t.Fatal(err)
+		}
+		if err := AppendToManifest(manifestPath, []*ManifestFile{manifest}); err != nil {
+			t.Fatal(err)
+		}
+		mf, err := readManifestFile(manifestPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mf) != 1 || !bytes.Equal(mf[0].Checksum, manifest.Checksum) {
+			t.Errorf("%s read back as %v", name, mf)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build windows
+
+package s3checksum
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// maxShortPath is the longest path the Windows APIs accept without the
+// extended-length prefix. Directories are limited to MAX_PATH minus the
+// 12 characters of an 8.3 file name.
+const maxShortPath = 248
+
+// LongPath adds the \\?\ extended-length prefix to paths that are too long
+// for the legacy MAX_PATH limit, so deeply nested files can be opened. The
+// prefix turns off path normalization, so the path is made absolute and
+// cleaned first.
+func LongPath(path string) string {
+	if len(path) < maxShortPath || strings.HasPrefix(path, `\\?\`) {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		// UNC paths, \\server\share, become \\?\UNC\server\share
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
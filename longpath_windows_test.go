@@ -0,0 +1,42 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build windows
+
+package s3checksum
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLongPathWindows(t *testing.T) {
+	long := strings.Repeat(`nested\`, 40) + "file.bin"
+	cwd, err := filepath.Abs(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"short drive path", `C:\data\file.bin`, `C:\data\file.bin`},
+		{"short UNC path", `\\server\share\file.bin`, `\\server\share\file.bin`},
+		{"long drive path", `C:\` + long, `\\?\C:\` + long},
+		{"long drive path is cleaned", `C:\tmp\..\` + long, `\\?\C:\` + long},
+		{"long UNC path", `\\server\share\` + long, `\\?\UNC\server\share\` + long},
+		{"long relative path", long, `\\?\` + filepath.Join(cwd, long)},
+		{"already prefixed", `\\?\C:\` + long, `\\?\C:\` + long},
+		{"already prefixed UNC", `\\?\UNC\server\share\` + long, `\\?\UNC\server\share\` + long},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LongPath(tt.path); got != tt.want {
+				t.Errorf("LongPath(%q)\n= %q\nwant %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
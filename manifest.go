@@ -4,19 +4,34 @@
 package s3checksum
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/csv"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var (
-	printHex = false
+	printHex   = false
+	lowerHexRe = regexp.MustCompile(`^[0-9a-f]+$`)
 )
 
-// PrintHexMode sets the CLI to print checksums in hex instead of base64
+// PrintHexMode makes ByteSlice.String print checksums in hex instead of
+// base64 for the whole process.
+//
+// Deprecated: pass EncodingHex to ByteSlice.Encode or
+// NewRendererWithEncoding instead.
 func PrintHexMode() {
 	printHex = true
 }
@@ -26,7 +41,14 @@ type PartInfo struct {
 	Size        int64     `json:"size"`
 	Algorithm   string    `json:"algorithm"`
 	Checksum    ByteSlice `json:"checksum"`
-	MD5Checksum []byte    `json:""`
+	MD5Checksum ByteSlice `json:"md5_checksum"`
+	// RollingChecksum is the rsync style Adler-32 weak checksum of the part,
+	// when computed. It is cheap to compare, so changed parts can be found
+	// by comparing it first and confirming with Checksum.
+	RollingChecksum uint32 `json:"rolling_checksum,omitempty"`
+	// Duration is how long the part took to read and hash. It differs
+	// from run to run, so it isn't stored in manifests.
+	Duration time.Duration `json:"-"`
 }
 
 type ManifestFile struct {
@@ -36,6 +58,51 @@ type ManifestFile struct {
 	Checksum  ByteSlice   `json:"checksum"`
 	Etag      []byte      `json:"Etag"`
 	Algorithm string      `json:"algorithm"`
+	// ChecksumType is ChecksumTypeFullObject when Checksum was computed
+	// over the whole file, see MultipartFileOpts.ChecksumType. Empty is a
+	// composite, unless Algorithm is a full object algorithm.
+	ChecksumType string `json:"checksum_type,omitempty"`
+	// FullObjectChecksum is the checksum of the whole file, when computed.
+	FullObjectChecksum ByteSlice `json:"full_object_checksum,omitempty"`
+	Size               int64     `json:"size,omitempty"`
+	// Partial marks a manifest that only holds the last part's checksum,
+	// see MultipartFileOpts.LastPartOnly. It has no composite checksum.
+	Partial bool `json:"partial,omitempty"`
+	// Incomplete marks a manifest written by an interrupted
+	// CalculateChecksum. It lists the parts finished so far and has no
+	// composite checksum; see MultipartFileOpts.ResumeFrom.
+	Incomplete bool `json:"incomplete,omitempty"`
+	// Elapsed is how long CalculateChecksum took, see Throughput. Like
+	// PartInfo.Duration it isn't stored in manifests.
+	Elapsed time.Duration `json:"-"`
+}
+
+// isFullObject reports whether Checksum is a full object checksum, which
+// has no part count and can't be recombined from the parts.
+func (mf *ManifestFile) isFullObject() bool {
+	return mf.ChecksumType == ChecksumTypeFullObject || isFullObjectAlgorithm(mf.Algorithm)
+}
+
+// BytesChecksummed is the number of bytes of the file that were read: the
+// whole file, or only the listed parts of a Partial manifest.
+func (mf *ManifestFile) BytesChecksummed() int64 {
+	if !mf.Partial {
+		return mf.Size
+	}
+	var n int64
+	for _, p := range mf.PartList {
+		n += p.Size
+	}
+	return n
+}
+
+// Throughput is BytesChecksummed per second of Elapsed, or 0 when Elapsed
+// isn't known.
+func (mf *ManifestFile) Throughput() float64 {
+	if mf.Elapsed <= 0 {
+		return 0
+	}
+	return float64(mf.BytesChecksummed()) / mf.Elapsed.Seconds()
 }
 
 type ObjectAttributes struct {
@@ -44,10 +111,25 @@ type ObjectAttributes struct {
 	Algorithm string    `json:"algorithm"`
 	Checksum  ByteSlice `json:"checksum"`
 	Etag      []byte    `json:"Etag"`
+	// Size is the object size and Parts the number of parts S3 reports,
+	// 0 for objects that weren't uploaded in parts.
+	Size     int64       `json:"size,omitempty"`
+	Parts    int         `json:"parts,omitempty"`
+	PartList []*PartInfo `json:"part_list,omitempty"`
+	// StorageClass is the object's storage class, empty for STANDARD.
+	StorageClass string `json:"storage_class,omitempty"`
 }
 
 type ByteSlice []byte
 
+// Encoding selects how checksums are printed in human readable output.
+type Encoding string
+
+const (
+	EncodingBase64 Encoding = "base64"
+	EncodingHex    Encoding = "hex"
+)
+
 func (m ByteSlice) MarshalJSON() ([]byte, error) {
 	return json.Marshal(hex.EncodeToString(m))
 }
@@ -66,6 +148,19 @@ func (m *ByteSlice) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// Encode returns m in the given encoding. An empty encoding is the same as
+// String.
+func (m ByteSlice) Encode(e Encoding) string {
+	switch e {
+	case EncodingHex:
+		return hex.EncodeToString(m)
+	case EncodingBase64:
+		return base64.StdEncoding.EncodeToString(m)
+	}
+	return m.String()
+}
+
+// String returns m in base64, or in hex after PrintHexMode was called.
 func (m ByteSlice) String() string {
 	if printHex {
 		return hex.EncodeToString(m)
@@ -75,28 +170,358 @@ func (m ByteSlice) String() string {
 
 }
 
+// ManifestStdout as a manifest path writes the manifest to stdout as JSON,
+// see WriteManifestFile.
+const ManifestStdout = "-"
+
+// WriteManifest writes mf as a JSON array with every part checksum, in
+// the format ReadManifest reads back.
+func WriteManifest(path string, mf []*ManifestFile) error {
+	f, err := os.Create(LongPath(path))
+	if err != nil {
+		return err
+	}
+	if err := WriteManifestTo(f, mf); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteManifestTo is WriteManifest to w.
+func WriteManifestTo(w io.Writer, mf []*ManifestFile) error {
+	return renderJSON(w, mf)
+}
+
+// WriteManifestFile writes mf with WriteManifest when path has a .json
+// extension and with WriteSimpleManifest otherwise. ManifestStdout writes
+// the JSON manifest to stdout instead of a file.
+func WriteManifestFile(path string, mf []*ManifestFile) error {
+	if path == ManifestStdout {
+		return WriteManifestTo(os.Stdout, mf)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return WriteManifest(path, mf)
+	}
+	return WriteSimpleManifest(path, mf)
+}
+
+// manifestLockTimeout is how long AppendToManifest waits for another
+// writer to release the manifest.
+const manifestLockTimeout = 30 * time.Second
+
+// AppendToManifest merges mf into the manifest at path, written by
+// WriteManifestFile, and rewrites it in the same format. Entries are
+// matched by Filename: a file already in the manifest is replaced in
+// place, new files are added at the end. A missing manifest is created.
+//
+// Parallel callers appending to the same path are serialized with a
+// path+".lock" file, and the manifest is replaced atomically, so readers
+// never see a partial write. A lock left behind by a killed process makes
+// AppendToManifest fail after 30 seconds; remove it once no other run is
+// writing the manifest.
+func AppendToManifest(path string, mf []*ManifestFile) error {
+	if path == ManifestStdout {
+		return fmt.Errorf("a manifest written to stdout can't be appended to")
+	}
+	unlock, err := lockManifest(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	existing, err := readManifestFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	merged := mergeManifests(existing, mf)
+
+	tmp, err := os.CreateTemp(LongPath(filepath.Dir(path)), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = renderJSON(tmp, merged)
+	} else {
+		err = renderCSV(tmp, merged, "")
+	}
+	if err == nil {
+		// CreateTemp makes the file private, manifests are shared
+		err = tmp.Chmod(0o644)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), LongPath(path))
+}
+
+// lockManifest takes the lock file of the manifest at path and returns the
+// function that releases it.
+func lockManifest(path string) (func(), error) {
+	lock := LongPath(path + ".lock")
+	deadline := time.Now().Add(manifestLockTimeout)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for the manifest lock %s, remove it if no other run is writing %s", lock, path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// mergeManifests replaces the entries of existing that have the same
+// Filename as an entry of mf and appends the others.
+func mergeManifests(existing, mf []*ManifestFile) []*ManifestFile {
+	merged := append([]*ManifestFile{}, existing...)
+	index := map[string]int{}
+	for i, m := range merged {
+		index[m.Filename] = i
+	}
+	for _, m := range mf {
+		if i, ok := index[m.Filename]; ok {
+			merged[i] = m
+			continue
+		}
+		index[m.Filename] = len(merged)
+		merged = append(merged, m)
+	}
+	return merged
+}
+
 // WriteSimpleManifest is a simplified CSV that doesn't include part checksums,
 // only checksum of checksums.
 func WriteSimpleManifest(path string, mf []*ManifestFile) error {
-	f, err := os.Create(path)
+	f, err := os.Create(LongPath(path))
 	if err != nil {
 		return err
 	}
 	defer f.Close()
-	rows := [][]string{}
-	for _, v := range mf {
-		partSize := fmt.Sprintf("%d", v.PartSize)
-		checksumOfChecksums := fmt.Sprintf("%s-%d", v.Checksum.String(), len(v.PartList))
-		etag := fmt.Sprintf("%x-%d", v.Etag, len(v.PartList))
-
-		rows = append(rows, []string{
-			v.Filename,
-			partSize,
-			v.Algorithm,
-			checksumOfChecksums,
-			etag,
-		})
-	}
-
-	return csv.NewWriter(f).WriteAll(rows)
+
+	return WriteSimpleManifestTo(f, mf)
+}
+
+// WriteSimpleManifestTo is WriteSimpleManifest to w.
+func WriteSimpleManifestTo(w io.Writer, mf []*ManifestFile) error {
+	return renderCSV(w, mf, "")
+}
+
+// SortKeys are the keys SortManifests accepts.
+var SortKeys = []string{"checksum", "name", "size"}
+
+// SortManifests orders a multi-file manifest by composite checksum, file
+// name or size. Sorting by checksum puts identical files next to each
+// other. The sort is stable, so entries with equal keys keep their order.
+func SortManifests(mf []*ManifestFile, by string) error {
+	var less func(a, b *ManifestFile) bool
+	switch by {
+	case "checksum":
+		less = func(a, b *ManifestFile) bool { return bytes.Compare(a.Checksum, b.Checksum) < 0 }
+	case "name":
+		less = func(a, b *ManifestFile) bool { return a.Filename < b.Filename }
+	case "size":
+		less = func(a, b *ManifestFile) bool { return a.Size < b.Size }
+	default:
+		return fmt.Errorf("unknown sort key %q, expected one of: %s", by, strings.Join(SortKeys, ", "))
+	}
+	sort.SliceStable(mf, func(i, j int) bool { return less(mf[i], mf[j]) })
+	return nil
+}
+
+// ReadManifest reads manifests printed with --format json or jsonl: a JSON
+// array of manifests, or one manifest object per line. CSV manifests written
+// by WriteSimpleManifest have no part checksums and are rejected. Every
+// manifest is checked with ValidatePartAlgorithms.
+func ReadManifest(path string) ([]*ManifestFile, error) {
+	data, err := os.ReadFile(LongPath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] != '[' && trimmed[0] != '{' {
+		return nil, withCategory(ErrInvalidManifest, fmt.Errorf("%s is not a JSON manifest; CSV manifests only hold the composite checksums, write a JSON one with --manifest name.json or --format json", path))
+	}
+
+	mf := []*ManifestFile{}
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &mf); err != nil {
+			return nil, withCategory(ErrInvalidManifest, fmt.Errorf("unable to parse manifest %s: %w", path, err))
+		}
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		for dec.More() {
+			m := &ManifestFile{}
+			if err := dec.Decode(m); err != nil {
+				return nil, withCategory(ErrInvalidManifest, fmt.Errorf("unable to parse manifest %s: %w", path, err))
+			}
+			mf = append(mf, m)
+		}
+	}
+
+	for _, m := range mf {
+		if err := ValidatePartAlgorithms(m); err != nil {
+			return nil, withCategory(ErrInvalidManifest, err)
+		}
+	}
+	return mf, nil
+}
+
+// readManifestFile reads a manifest written by WriteManifestFile, as JSON
+// for .json paths and CSV otherwise.
+func readManifestFile(path string) ([]*ManifestFile, error) {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return ReadManifest(path)
+	}
+	return ReadSimpleManifest(path)
+}
+
+// ManifestPathForAlgorithm inserts the algorithm name before the extension
+// of a manifest path, so manifest.csv becomes manifest.sha256.csv.
+func ManifestPathForAlgorithm(path, algorithm string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + strings.ToLower(algorithm) + ext
+}
+
+// WriteManifestsByAlgorithm splits a manifest holding results of several
+// algorithms into one manifest per algorithm, named by
+// ManifestPathForAlgorithm, for tools that only understand a single
+// algorithm. It returns the paths written.
+func WriteManifestsByAlgorithm(path string, mf []*ManifestFile) ([]string, error) {
+	byAlgorithm := map[string][]*ManifestFile{}
+	for _, m := range mf {
+		algorithm := m.Algorithm
+		if algorithm == "" {
+			algorithm = DefaultAlgorithm
+		}
+		byAlgorithm[algorithm] = append(byAlgorithm[algorithm], m)
+	}
+
+	names := make([]string, 0, len(byAlgorithm))
+	for name := range byAlgorithm {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	paths := []string{}
+	for _, name := range names {
+		p := ManifestPathForAlgorithm(path, name)
+		if err := WriteManifestFile(p, byAlgorithm[name]); err != nil {
+			return paths, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
+
+// ReadSimpleManifest parses a CSV written by WriteSimpleManifest, with the
+// columns filename, part size, algorithm, checksum and etag. The part count
+// is recovered from the -N suffix of the checksum, or of the etag for full
+// object algorithms. The CSV doesn't carry part checksums, so PartList
+// holds numbered placeholder entries without checksums, and the file size
+// isn't known. Rows of a --last-part-only run become a Partial manifest
+// holding just the last part.
+func ReadSimpleManifest(path string) ([]*ManifestFile, error) {
+	f, err := os.Open(LongPath(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 5
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, withCategory(ErrInvalidManifest, fmt.Errorf("unable to parse manifest %s: %w", path, err))
+	}
+
+	mf := []*ManifestFile{}
+	for i, row := range rows {
+		partSize, err := strconv.Atoi(row[1])
+		if err != nil {
+			return nil, withCategory(ErrInvalidManifest, fmt.Errorf("invalid part size on line %d of %s: %w", i+1, path, err))
+		}
+		partial := strings.HasPrefix(row[3], lastPartPrefix)
+		checksum, parts, err := splitPartCount(strings.TrimPrefix(row[3], lastPartPrefix))
+		if err != nil {
+			return nil, withCategory(ErrInvalidManifest, fmt.Errorf("invalid checksum on line %d of %s: %w", i+1, path, err))
+		}
+		etag, etagParts, err := splitPartCount(strings.TrimPrefix(row[4], lastPartPrefix))
+		if err != nil {
+			return nil, withCategory(ErrInvalidManifest, fmt.Errorf("invalid etag on line %d of %s: %w", i+1, path, err))
+		}
+		fullObject := parts == 0 && etagParts > 0
+		if fullObject {
+			// Full object checksums have no part count, the ETag still does
+			parts = etagParts
+		}
+
+		m := &ManifestFile{
+			Filename:  row[0],
+			PartSize:  partSize,
+			Algorithm: row[2],
+			PartList:  []*PartInfo{},
+			Partial:   partial,
+		}
+		if fullObject {
+			m.ChecksumType = ChecksumTypeFullObject
+		}
+		decodedChecksum, err := decodeChecksum(checksum)
+		if err != nil {
+			return nil, withCategory(ErrInvalidManifest, fmt.Errorf("invalid checksum on line %d of %s: %w", i+1, path, err))
+		}
+		decodedEtag, err := hex.DecodeString(etag)
+		if err != nil {
+			return nil, withCategory(ErrInvalidManifest, fmt.Errorf("invalid etag on line %d of %s: %w", i+1, path, err))
+		}
+
+		if partial {
+			// The columns hold the last part's checksums and number
+			m.PartList = append(m.PartList, &PartInfo{
+				PartNumber:  int32(parts),
+				Algorithm:   m.Algorithm,
+				Checksum:    decodedChecksum,
+				MD5Checksum: decodedEtag,
+			})
+		} else {
+			m.Checksum = decodedChecksum
+			m.Etag = decodedEtag
+			for n := 1; n <= parts; n++ {
+				m.PartList = append(m.PartList, &PartInfo{PartNumber: int32(n), Algorithm: m.Algorithm})
+			}
+		}
+		mf = append(mf, m)
+	}
+	return mf, nil
+}
+
+// splitPartCount splits a "value-N" string into the value and part count.
+func splitPartCount(s string) (string, int, error) {
+	i := strings.LastIndex(s, "-")
+	if i < 0 {
+		return s, 0, nil
+	}
+	n, err := strconv.Atoi(s[i+1:])
+	if err != nil {
+		return "", 0, err
+	}
+	return s[:i], n, nil
+}
+
+// decodeChecksum accepts a checksum printed either in hex or in base64.
+func decodeChecksum(s string) (ByteSlice, error) {
+	if lowerHexRe.MatchString(s) && len(s)%2 == 0 {
+		return hex.DecodeString(s)
+	}
+	return base64.StdEncoding.DecodeString(s)
 }
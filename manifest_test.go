@@ -0,0 +1,184 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func testManifest() *ManifestFile {
+	return &ManifestFile{
+		Filename:     "data.bin",
+		PartSize:     MIN_PART_SIZE,
+		Checksum:     ByteSlice{0x00, 0x01, 0xfe, 0xff},
+		Etag:         []byte{0xde, 0xad, 0xbe, 0xef},
+		Algorithm:    "sha256",
+		ChecksumType: ChecksumTypeComposite,
+		Size:         MIN_PART_SIZE + 10,
+		PartList: []*PartInfo{
+			{PartNumber: 1, Size: MIN_PART_SIZE, Algorithm: "sha256", Checksum: ByteSlice{0x0a, 0x0b}, MD5Checksum: ByteSlice{0x0c}},
+			{PartNumber: 2, Size: 10, Algorithm: "sha256", Checksum: ByteSlice{0xf0, 0x0f}, MD5Checksum: ByteSlice{0x00}},
+		},
+	}
+}
+
+func TestManifestRoundTrip(t *testing.T) {
+	want := testManifest()
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err := WriteManifestFile(path, []*ManifestFile{want}); err != nil {
+		t.Fatal(err)
+	}
+	mf, err := ReadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mf) != 1 {
+		t.Fatalf("read %d manifests, want 1", len(mf))
+	}
+	got := mf[0]
+	if got.Filename != want.Filename || got.PartSize != want.PartSize || got.Algorithm != want.Algorithm ||
+		got.ChecksumType != want.ChecksumType || got.Size != want.Size {
+		t.Errorf("read %+v, want %+v", got, want)
+	}
+	if !bytes.Equal(got.Checksum, want.Checksum) || !bytes.Equal(got.Etag, want.Etag) {
+		t.Errorf("checksum %x etag %x, want %x and %x", []byte(got.Checksum), got.Etag, []byte(want.Checksum), want.Etag)
+	}
+	if len(got.PartList) != len(want.PartList) {
+		t.Fatalf("read %d parts, want %d", len(got.PartList), len(want.PartList))
+	}
+	for i, p := range got.PartList {
+		w := want.PartList[i]
+		if p.PartNumber != w.PartNumber || p.Size != w.Size || p.Algorithm != w.Algorithm ||
+			!bytes.Equal(p.Checksum, w.Checksum) || !bytes.Equal(p.MD5Checksum, w.MD5Checksum) {
+			t.Errorf("part %d read as %+v, want %+v", i+1, p, w)
+		}
+	}
+}
+
+// TestSimpleManifestRoundTrip writes composite, full object and partial
+// rows with WriteSimpleManifest and reads them back. The CSV only keeps the
+// part count of a composite checksum, and a partial row only its short last
+// part.
+func TestSimpleManifestRoundTrip(t *testing.T) {
+	composite := testManifest()
+	fullObject := testManifest()
+	fullObject.Filename = "full.bin"
+	fullObject.Algorithm = "crc64nvme"
+	fullObject.ChecksumType = ChecksumTypeFullObject
+	partial := testManifest()
+	partial.Filename = "partial.bin"
+	partial.Partial = true
+
+	path := filepath.Join(t.TempDir(), "manifest.csv")
+	if err := WriteSimpleManifest(path, []*ManifestFile{composite, fullObject, partial}); err != nil {
+		t.Fatal(err)
+	}
+	mf, err := ReadSimpleManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mf) != 3 {
+		t.Fatalf("read %d rows, want 3", len(mf))
+	}
+
+	for i, want := range []*ManifestFile{composite, fullObject} {
+		got := mf[i]
+		if got.Filename != want.Filename || got.PartSize != want.PartSize || got.Algorithm != want.Algorithm || got.Partial {
+			t.Errorf("row %d read as %+v, want %+v", i+1, got, want)
+		}
+		if !bytes.Equal(got.Checksum, want.Checksum) || !bytes.Equal(got.Etag, want.Etag) {
+			t.Errorf("row %d checksum %x etag %x, want %x and %x", i+1, []byte(got.Checksum), got.Etag, []byte(want.Checksum), want.Etag)
+		}
+		if len(got.PartList) != len(want.PartList) {
+			t.Errorf("row %d has %d parts, want %d", i+1, len(got.PartList), len(want.PartList))
+		}
+	}
+	if mf[0].ChecksumType != "" || mf[1].ChecksumType != ChecksumTypeFullObject {
+		t.Errorf("checksum types %q and %q, want none and %s", mf[0].ChecksumType, mf[1].ChecksumType, ChecksumTypeFullObject)
+	}
+
+	got := mf[2]
+	if !got.Partial || len(got.Checksum) != 0 || len(got.PartList) != 1 {
+		t.Fatalf("partial row read as %+v, want only the last part", got)
+	}
+	last, want := got.PartList[0], partial.PartList[1]
+	if last.PartNumber != want.PartNumber || !bytes.Equal(last.Checksum, want.Checksum) || !bytes.Equal(last.MD5Checksum, want.MD5Checksum) {
+		t.Errorf("last part read as %+v, want %+v", last, want)
+	}
+}
+
+func TestReadManifestRejectsCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.csv")
+	if err := WriteManifestFile(path, []*ManifestFile{testManifest()}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ReadManifest(path)
+	if !errors.Is(err, ErrInvalidManifest) {
+		t.Fatalf("err = %v, want ErrInvalidManifest", err)
+	}
+}
+
+// TestManifestJSONKeys pins the JSON key names and encodings of manifests,
+// which other tools parse. Checksums, including the part MD5s, are hex.
+func TestManifestJSONKeys(t *testing.T) {
+	want := `{"filename":"data.bin","part_size":5242880,"part_list":[` +
+		`{"part_number":1,"size":5242880,"algorithm":"sha256","checksum":"0a0b","md5_checksum":"0c"},` +
+		`{"part_number":2,"size":10,"algorithm":"sha256","checksum":"f00f","md5_checksum":"00"}],` +
+		`"checksum":"0001feff","Etag":"3q2+7w==","algorithm":"sha256","checksum_type":"COMPOSITE","size":5242890}`
+	got, err := json.Marshal(testManifest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("manifest JSON\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestAppendToManifestParallel appends from many goroutines at once, as
+// parallel runs sharing a manifest do. No entry may be lost to a write
+// that raced with another.
+func TestAppendToManifestParallel(t *testing.T) {
+	const appenders = 20
+	for _, name := range []string{"manifest.json", "manifest.csv"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			var wg sync.WaitGroup
+			for i := 0; i < appenders; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					m := testManifest()
+					m.Filename = fmt.Sprintf("file-%02d.bin", i)
+					if err := AppendToManifest(path, []*ManifestFile{m}); err != nil {
+						t.Error(err)
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			mf, err := readManifestFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seen := map[string]int{}
+			for _, m := range mf {
+				seen[m.Filename]++
+			}
+			for i := 0; i < appenders; i++ {
+				if file := fmt.Sprintf("file-%02d.bin", i); seen[file] != 1 {
+					t.Errorf("%s is in the manifest %d times, want once", file, seen[file])
+				}
+			}
+			if len(mf) != appenders {
+				t.Errorf("%d entries, want %d", len(mf), appenders)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"bytes"
+	"context"
+	"hash"
+	"runtime"
+)
+
+// ChecksumBytes computes the part checksums, composite checksum and ETag of
+// data held in memory, the same as CalculateChecksum over the same bytes in
+// a file. A nil hashFun uses DefaultAlgorithm.
+func ChecksumBytes(data []byte, partSize int64, hashFun func() hash.Hash) (*ManifestFile, error) {
+	opts := MultipartFileOpts{
+		FilePath: "-",
+		FileSize: int64(len(data)),
+		PartSize: partSize,
+		HashFun:  hashFun,
+		Threads:  runtime.NumCPU(),
+		Reader:   bytes.NewReader(data),
+	}
+	if len(data) == 0 {
+		// There are no parts to size, CalculateChecksum rejects empty files
+		if err := checkRequiredArgs(&opts); err != nil {
+			return nil, err
+		}
+		opts.PartSize = MIN_PART_SIZE
+		return newMultipartFile(opts).buildManifest(nil, nil), nil
+	}
+
+	m, err := NewMultipartFile(opts)
+	if err != nil {
+		return nil, err
+	}
+	return m.CalculateChecksum(context.Background())
+}
@@ -0,0 +1,55 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// User metadata keys written by Upload with WriteChecksumMetadata. S3
+// returns them as x-amz-meta-part-size and x-amz-meta-algorithm.
+const (
+	MetadataPartSize  = "part-size"
+	MetadataAlgorithm = "algorithm"
+)
+
+// ErrNoChecksumMetadata is returned by ReadChecksumMetadata when the object
+// wasn't uploaded with WriteChecksumMetadata.
+var ErrNoChecksumMetadata = errors.New("object has no part size and algorithm metadata")
+
+// ChecksumMetadata is the part size and algorithm an object was uploaded with.
+type ChecksumMetadata struct {
+	PartSize  int64
+	Algorithm string
+}
+
+// ReadChecksumMetadata reads the part size and algorithm that Upload stored
+// on an object, so it can be verified without passing them explicitly.
+func ReadChecksumMetadata(ctx context.Context, client s3.HeadObjectAPIClient, bucket, key string) (*ChecksumMetadata, error) {
+	out, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	partSize, hasPartSize := out.Metadata[MetadataPartSize]
+	algorithm, hasAlgorithm := out.Metadata[MetadataAlgorithm]
+	if !hasPartSize || !hasAlgorithm {
+		return nil, ErrNoChecksumMetadata
+	}
+
+	md := &ChecksumMetadata{Algorithm: algorithm}
+	md.PartSize, err = strconv.ParseInt(partSize, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s metadata %q: %w", MetadataPartSize, partSize, err)
+	}
+	return md, nil
+}
@@ -0,0 +1,80 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestEndpointRoundTrip uploads a file to an S3-compatible store and
+// downloads it again. It runs when S3CHECKSUM_TEST_ENDPOINT and
+// S3CHECKSUM_TEST_BUCKET are set, e.g. against a local MinIO:
+//
+//	docker run -d -p 9000:9000 minio/minio server /data
+//	AWS_ACCESS_KEY_ID=minioadmin AWS_SECRET_ACCESS_KEY=minioadmin \
+//	S3CHECKSUM_TEST_ENDPOINT=http://localhost:9000 S3CHECKSUM_TEST_BUCKET=test \
+//	go test -run TestEndpointRoundTrip
+//
+// The bucket must already exist.
+func TestEndpointRoundTrip(t *testing.T) {
+	endpoint := os.Getenv("S3CHECKSUM_TEST_ENDPOINT")
+	bucket := os.Getenv("S3CHECKSUM_TEST_BUCKET")
+	if endpoint == "" || bucket == "" {
+		t.Skip("S3CHECKSUM_TEST_ENDPOINT and S3CHECKSUM_TEST_BUCKET aren't set")
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	client := ClientOptions{Region: region, UsePathStyle: true, EndpointURL: endpoint}
+
+	for _, tt := range []struct {
+		name string
+		size int
+	}{
+		{"single part", 1000},
+		{"multipart", 2*MIN_PART_SIZE + 100},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			path, data := writeTestFile(t, tt.size)
+			key := "s3checksum-test/" + filepath.Base(t.TempDir())
+			uploaded, err := UploadWithResult(ctx, &UploadOptions{
+				Bucket:        bucket,
+				Key:           key,
+				LocalFile:     path,
+				PartSize:      MIN_PART_SIZE,
+				ClientOptions: client,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			copyPath := filepath.Join(t.TempDir(), "copy.bin")
+			downloaded, err := Download(ctx, &DownloadOptions{
+				Bucket:        bucket,
+				Key:           key,
+				LocalFile:     copyPath,
+				ClientOptions: client,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(copyPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("downloaded %d bytes that aren't the %d uploaded", len(got), len(data))
+			}
+			if !bytes.Equal(downloaded.Checksum, uploaded.Manifest.Checksum) {
+				t.Errorf("download checksum %s, upload %s", downloaded.Checksum, uploaded.Manifest.Checksum)
+			}
+		})
+	}
+}
@@ -6,31 +6,122 @@ package s3checksum
 import (
 	"context"
 	"crypto/md5"
-	"crypto/sha256"
+	"errors"
 	"fmt"
 	"hash"
+	"hash/adler32"
 	"io"
-	"log"
-	"math"
 	"os"
-	"sort"
 	"sync"
+	"time"
 )
 
 const (
 	MIN_PART_SIZE = 5242880
+	// MAX_PARTS is the most parts S3 accepts in a multipart upload.
+	MAX_PARTS = 10000
 )
 
+// ErrWriteManifest is wrapped by the error returned when the manifest
+// can't be written to ManifestFilePath. The checksum itself succeeded and
+// the manifest is returned with it.
+var ErrWriteManifest = errors.New("error writing manifest file")
+
+// ErrPartSizeTooSmall is returned for a part size under MIN_PART_SIZE, the
+// smallest S3 accepts for every part of a multipart upload but the last.
+var ErrPartSizeTooSmall = errors.New("S3 requires parts of at least 5MB (5242880 bytes), except for the last part")
+
+// checkPartSize returns an error wrapping ErrPartSizeTooSmall when partSize
+// is under MIN_PART_SIZE.
+func checkPartSize(partSize int64) error {
+	if partSize < MIN_PART_SIZE {
+		return fmt.Errorf("part size of %d bytes is too small: %w", partSize, ErrPartSizeTooSmall)
+	}
+	return nil
+}
+
 type MultipartFileOpts struct {
-	FilePath         string
+	FilePath string
+	// ManifestName, when set, is the Filename recorded in the manifest
+	// instead of FilePath, see ManifestPath.
+	ManifestName string
+	// ManifestFilePath, when set, is where CalculateChecksum writes the
+	// manifest, as JSON or CSV depending on the extension, see
+	// WriteManifestFile. A failed write returns the manifest along with an
+	// error wrapping ErrWriteManifest.
 	ManifestFilePath string
-	FileSize         int64
-	NumberOfParts    int
-	PartSize         int64
-	NumRoutines      int
-	HashFun          func() hash.Hash
-	Threads          int
-	Algorithm        string
+	// AppendManifest merges the manifest into ManifestFilePath with
+	// AppendToManifest, so runs over different files can share it, instead
+	// of replacing the file.
+	AppendManifest bool
+	FileSize       int64
+	NumberOfParts  int
+	PartSize       int64
+	NumRoutines    int
+	HashFun        func() hash.Hash
+	Threads        int
+	Algorithm      string
+	// MaxMemoryBytes, when set, bounds the memory used by part buffers.
+	// Each running thread holds one buffer of PartSize bytes, so memory is
+	// about Threads * PartSize; Threads is lowered to fit. A single part
+	// larger than MaxMemoryBytes is an error.
+	MaxMemoryBytes int64
+	// TargetParts, when set, derives PartSize as ceil(FileSize/TargetParts)
+	// instead of using the PartSize given.
+	TargetParts int
+	// PartAlignment, when set, rounds PartSize up to a multiple of this
+	// many bytes so reads line up with the storage block size. It moves the
+	// part boundaries and so changes the composite checksum; the same
+	// alignment must be used wherever the checksum is compared.
+	PartAlignment int64
+	// FitPartLimit raises PartSize when it would split the file into more
+	// than MAX_PARTS parts, to the size the SDK upload manager picks in that
+	// case, instead of returning an error.
+	FitPartLimit bool
+	// IncludeFullObject also computes ManifestFile.FullObjectChecksum with
+	// a second, sequential pass over the file.
+	IncludeFullObject bool
+	// LastPartOnly makes CalculateChecksum hash only the final part. This
+	// is a quick check for truncation and appended data, not a full
+	// integrity check, and the manifest is marked Partial.
+	LastPartOnly bool
+	// ChecksumType ChecksumTypeFullObject computes Checksum over the whole
+	// file in a single pass, as S3 does for objects uploaded with that
+	// checksum type, instead of combining the part checksums. It has no
+	// part count. Empty is ChecksumTypeComposite, except for full object
+	// algorithms, which are always ChecksumTypeFullObject.
+	ChecksumType string
+	// SkipETag leaves out the MD5 ETag, which is hashed over the same data
+	// as the checksum and so roughly doubles the work when only the
+	// checksum is needed. PartInfo.MD5Checksum and ManifestFile.Etag are
+	// left empty.
+	SkipETag bool
+	// IncludeRollingChecksum also computes PartInfo.RollingChecksum for
+	// every part, for delta detection against a previous manifest.
+	IncludeRollingChecksum bool
+	// Progress, when set, is called by CalculateChecksum each time a part
+	// finishes, with the number of parts done and the total. Parts finish
+	// out of order but Progress is always called from a single goroutine.
+	Progress func(completed, total int)
+	// OpenOnce makes CalculateChecksum open FilePath a single time and read
+	// every part from that handle with ReadAt, instead of opening and
+	// seeking once per part. Leave it off on filesystems where concurrent
+	// ReadAt on one handle is slow or unsafe.
+	OpenOnce bool
+	// Sequential reads the file once, front to back, with a single buffer
+	// instead of reading parts concurrently at different offsets. It is
+	// faster on spinning disks, where concurrent reads make the heads seek,
+	// and gives the same checksums. Threads is ignored.
+	Sequential bool
+	// ResumeFrom is the manifest of an earlier run over the same file,
+	// usually an Incomplete one left by an interrupted run. Its part
+	// checksums are reused and only the missing parts are read. Its part
+	// size, algorithm and file size must match this run's.
+	ResumeFrom *ManifestFile
+	// Reader, when set, is read with ReadAt instead of opening FilePath.
+	// FilePath is then only the name recorded in the manifest. FileSize is
+	// taken from Stat when Reader is an *os.File, otherwise it must be set.
+	Reader io.ReaderAt
 }
 
 type MultipartFile struct {
@@ -41,18 +132,37 @@ type MultipartFile struct {
 	md5HashPool *sync.Pool
 }
 
+// NewMultipartFile resolves the part size and part count of the file in
+// options. A part size under MIN_PART_SIZE returns an error wrapping
+// ErrPartSizeTooSmall, so callers can ask for another one.
 func NewMultipartFile(options MultipartFileOpts, optFns ...func(*MultipartFileOpts)) (*MultipartFile, error) {
 
 	options = options.Copy()
 
-	checkRequiredArgs(&options)
+	if err := checkRequiredArgs(&options); err != nil {
+		return nil, err
+	}
 
 	for _, fn := range optFns {
 		fn(&options)
 	}
 
-	resolvePartSize(&options)
+	if err := resolvePartSize(&options); err != nil {
+		return nil, err
+	}
+	if err := limitMemory(&options); err != nil {
+		return nil, err
+	}
+	if err := checkResume(&options); err != nil {
+		return nil, err
+	}
 
+	return newMultipartFile(options), nil
+}
+
+// newMultipartFile sets up the buffer and hash pools for already validated
+// options.
+func newMultipartFile(options MultipartFileOpts) *MultipartFile {
 	bufferPool := &sync.Pool{
 		New: func() interface{} {
 			return make([]byte, options.PartSize)
@@ -75,7 +185,7 @@ func NewMultipartFile(options MultipartFileOpts, optFns ...func(*MultipartFileOp
 		bufferPool:        bufferPool,
 		hashPool:          hashPool,
 		md5HashPool:       md5HashPool,
-	}, nil
+	}
 }
 
 func (o MultipartFileOpts) Copy() MultipartFileOpts {
@@ -83,19 +193,78 @@ func (o MultipartFileOpts) Copy() MultipartFileOpts {
 	return to
 }
 
-func resolvePartSize(o *MultipartFileOpts) {
+func resolvePartSize(o *MultipartFileOpts) error {
 	// size option must be already defined
 	if o.FileSize == 0 {
-		log.Fatal("file size cannot be 0")
+		return fmt.Errorf("file size cannot be 0")
+	}
+
+	if o.TargetParts < 0 {
+		return fmt.Errorf("number of parts must be a positive value")
+	}
+	if o.TargetParts > 0 {
+		o.PartSize = (o.FileSize + int64(o.TargetParts) - 1) / int64(o.TargetParts)
+		if o.PartSize < MIN_PART_SIZE {
+			return fmt.Errorf("splitting %d bytes into %d parts needs %d byte parts, use fewer parts: %w", o.FileSize, o.TargetParts, o.PartSize, ErrPartSizeTooSmall)
+		}
+	}
+
+	if o.TargetParts > MAX_PARTS {
+		return fmt.Errorf("number of parts can't be more than %d", MAX_PARTS)
+	}
+
+	// A part size that keeps the file within the part limit, the same one
+	// the SDK upload manager switches to for files that don't fit
+	minPartSize := o.FileSize/MAX_PARTS + 1
+	if o.FitPartLimit {
+		o.PartSize = sdkPartSize(o.FileSize, o.PartSize)
 	}
 
-	if o.PartSize < MIN_PART_SIZE {
-		log.Fatal("part size should be larger than 5MB")
+	if o.PartAlignment < 0 {
+		return fmt.Errorf("part alignment must be a positive value")
+	}
+	if o.PartAlignment > 0 {
+		o.PartSize = (o.PartSize + o.PartAlignment - 1) / o.PartAlignment * o.PartAlignment
 	}
 
-	NumberOfParts := float64(o.FileSize) / float64(o.PartSize)
-	o.NumberOfParts = int(math.Ceil(NumberOfParts))
+	if err := checkPartSize(o.PartSize); err != nil {
+		return err
+	}
 
+	// Integer division, a float one can round a file of exactly N parts up
+	// to N+1 once the sizes are large enough to lose precision
+	o.NumberOfParts = int((o.FileSize + o.PartSize - 1) / o.PartSize)
+	if o.NumberOfParts > MAX_PARTS {
+		return fmt.Errorf("%d byte parts split %d bytes into %d parts, more than the %d S3 allows; use a part size of at least %d bytes", o.PartSize, o.FileSize, o.NumberOfParts, MAX_PARTS, minPartSize)
+	}
+
+	return nil
+}
+
+// sdkPartSize returns the part size the SDK upload manager uses for a file
+// of fileSize bytes: partSize, raised to fileSize/MAX_PARTS+1 when the file
+// would otherwise be split into MAX_PARTS parts or more.
+func sdkPartSize(fileSize, partSize int64) int64 {
+	if partSize > 0 && fileSize/partSize >= MAX_PARTS {
+		return fileSize/MAX_PARTS + 1
+	}
+	return partSize
+}
+
+// limitMemory lowers Threads so that Threads buffers of PartSize bytes fit
+// in MaxMemoryBytes.
+func limitMemory(o *MultipartFileOpts) error {
+	if o.MaxMemoryBytes <= 0 {
+		return nil
+	}
+	maxThreads := o.MaxMemoryBytes / o.PartSize
+	if maxThreads < 1 {
+		return fmt.Errorf("a %d byte part buffer doesn't fit in the %d bytes of memory allowed, use a smaller part size or allow at least %d bytes", o.PartSize, o.MaxMemoryBytes, o.PartSize)
+	}
+	if int64(o.Threads) > maxThreads {
+		o.Threads = int(maxThreads)
+	}
+	return nil
 }
 
 func (m *MultipartFile) calculateEtag(data []byte) []byte {
@@ -107,6 +276,16 @@ func (m *MultipartFile) calculateEtag(data []byte) []byte {
 }
 
 func (m *MultipartFile) CalculateChecksumForPart(ctx context.Context, partNum int32) (*PartInfo, error) {
+	return m.calculateChecksumForPart(ctx, m.Reader, partNum)
+}
+
+// calculateChecksumForPart reads the part with ReadAt from ra, or opens
+// FilePath and seeks to the part when ra is nil.
+func (m *MultipartFile) calculateChecksumForPart(ctx context.Context, ra io.ReaderAt, partNum int32) (*PartInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	partStart := time.Now()
 
 	start := (m.PartSize * int64(partNum))
 	end := start + m.PartSize
@@ -115,14 +294,20 @@ func (m *MultipartFile) CalculateChecksumForPart(ctx context.Context, partNum in
 	}
 	size := end - start
 
-	f, err := os.Open(m.FilePath)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	_, err = f.Seek(start, 0)
-	if err != nil {
-		return nil, err
+	var r io.Reader
+	if ra != nil {
+		r = io.NewSectionReader(ra, start, size)
+	} else {
+		f, err := os.Open(LongPath(m.FilePath))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		_, err = f.Seek(start, 0)
+		if err != nil {
+			return nil, err
+		}
+		r = f
 	}
 
 	// Get from the buffer pool so we're not re-allocating
@@ -131,7 +316,7 @@ func (m *MultipartFile) CalculateChecksumForPart(ctx context.Context, partNum in
 	poolData := buffer.([]byte)
 	poolData = poolData[0:size]
 
-	n, err := io.ReadFull(f, poolData)
+	n, err := io.ReadFull(r, poolData)
 	if err != nil && err != io.EOF {
 		return nil, err
 	}
@@ -141,6 +326,11 @@ func (m *MultipartFile) CalculateChecksumForPart(ctx context.Context, partNum in
 	}
 	data := poolData[:n]
 
+	return m.finishPart(m.checksumPartData(partNum, data), partStart), nil
+}
+
+// checksumPartData hashes the bytes of the zero-based part partNum.
+func (m *MultipartFile) checksumPartData(partNum int32, data []byte) *PartInfo {
 	// Calculate the user requested hash
 	h := m.hashPool.Get().(hash.Hash)
 	defer m.hashPool.Put(h)
@@ -148,87 +338,324 @@ func (m *MultipartFile) CalculateChecksumForPart(ctx context.Context, partNum in
 	h.Write(data)
 	checksum := h.Sum(nil)
 
-	md5checksum := m.calculateEtag(data)
-
-	p := &PartInfo{
-		PartNumber:  partNum + 1,
-		Size:        size,
-		Checksum:    checksum[:],
-		Algorithm:   "sha256", // allow the user to change the algorithm
-		MD5Checksum: md5checksum[:],
+	part := &PartInfo{
+		PartNumber: partNum + 1,
+		Size:       int64(len(data)),
+		Checksum:   checksum[:],
+		Algorithm:  m.Algorithm,
 	}
-	return p, nil
+	if !m.SkipETag {
+		part.MD5Checksum = m.calculateEtag(data)
+	}
+	if m.IncludeRollingChecksum {
+		part.RollingChecksum = adler32.Checksum(data)
+	}
+	return part
 }
 
+// finishPart records how long part took to read and hash since start.
+func (m *MultipartFile) finishPart(part *PartInfo, start time.Time) *PartInfo {
+	part.Duration = time.Since(start)
+	logger().Debug("checksummed part", "file", m.FilePath, "part_number", part.PartNumber, "bytes", part.Size,
+		"algorithm", m.Algorithm, "duration_ms", part.Duration.Milliseconds())
+	return part
+}
+
+// ChecksumResult is the outcome of one part. Info is never nil; when Err
+// is set it only carries the part number.
 type ChecksumResult struct {
 	Info *PartInfo
 	Err  error
 }
 
+// CalculateChecksum checksums every part of the file and returns the
+// manifest, with Elapsed set to how long it took.
 func (m *MultipartFile) CalculateChecksum(ctx context.Context) (*ManifestFile, error) {
+	start := time.Now()
+	manifest, err := m.calculateChecksum(ctx)
+	if manifest != nil {
+		manifest.Elapsed = time.Since(start)
+		logger().Debug("checksummed file", "file", m.FilePath, "bytes", manifest.BytesChecksummed(),
+			"duration_ms", manifest.Elapsed.Milliseconds(), "bytes_per_second", int64(manifest.Throughput()))
+	}
+	return manifest, err
+}
 
-	results := make(chan ChecksumResult)
-	limiter := make(chan struct{}, m.Threads)
-	partInfoList := []*PartInfo{}
+func (m *MultipartFile) calculateChecksum(ctx context.Context) (*ManifestFile, error) {
+	if m.LastPartOnly {
+		return m.calculateLastPartChecksum(ctx)
+	}
+	if m.fullObject() || m.Sequential {
+		// A full object checksum can't be combined from parts hashed in
+		// parallel, so the file is read once, front to back
+		return m.calculateSequentialChecksum(ctx)
+	}
 
-	wg := sync.WaitGroup{}
-	wg.Add(m.NumberOfParts)
+	ra := m.Reader
+	if ra == nil && m.OpenOnce {
+		f, err := os.Open(LongPath(m.FilePath))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		ra = f
+	}
+
+	// A fixed pool of Threads workers takes part numbers from partNums, so
+	// the number of goroutines doesn't grow with the number of parts
+	workers := m.Threads
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Parts are combined into the composite as they finish. A worker waits
+	// before reading a part more than 2 * workers ahead of the oldest
+	// unfinished one, so few finished parts are held waiting on a slow one.
+	// The workers stop once a part fails, which would hold the rest back.
+	parentCtx := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	acc := NewCompositeAccumulator(m.HashFun, 2*workers)
+
+	results := make(chan ChecksumResult)
+	partNums := make(chan int32)
+	// Parts are stored by number, so the list is in order without sorting
+	parts := make([]*PartInfo, m.NumberOfParts)
+	done := 0
+
+	// Parts reused from ResumeFrom aren't read again
+	resumed := m.resumedParts()
+	for i := range parts {
+		if p, ok := resumed[int32(i+1)]; ok {
+			if err := acc.Add(p); err != nil {
+				return nil, err
+			}
+			parts[i] = p
+			done++
+		}
+	}
 
 	go func() {
+		defer close(partNums)
 		for i := int32(0); i < int32(m.NumberOfParts); i++ {
-			limiter <- struct{}{}
-			go func(i int32) {
-				defer wg.Done()
-				partInfo, err := m.CalculateChecksumForPart(ctx, i)
-				if err != nil {
-					log.Fatal(err.Error())
-				}
-				<-limiter
-				results <- ChecksumResult{partInfo, err}
-			}(i)
+			if _, ok := resumed[i+1]; ok {
+				continue
+			}
+			// No new parts are started once ctx is cancelled
+			select {
+			case partNums <- i:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range partNums {
+				var partInfo *PartInfo
+				err := acc.Reserve(ctx, i+1)
+				if err == nil {
+					partInfo, err = m.calculateChecksumForPart(ctx, ra, i)
+				}
+				if err != nil {
+					// CalculateChecksumForPart returns no PartInfo on
+					// failure, keep the part number for the error
+					partInfo = &PartInfo{PartNumber: i + 1}
+					err = fmt.Errorf("unable to checksum part %d of %s: %w", i+1, m.FilePath, err)
+				}
+				results <- ChecksumResult{partInfo, err}
+			}
+		}()
+	}
 	go func() {
+		// results is closed once every worker is done, which ends the
+		// loop below
 		wg.Wait()
 		close(results)
-		close(limiter)
 	}()
 
-	for m := range results {
-		if m.Err != nil {
-			fmt.Printf("Error calculating checksum for %d\n%s", m.Info.PartNumber, m.Err.Error())
-			m.Info.Checksum = []byte("ERROR CALCULATING")
+	// Every result is drained so no worker is left blocked, and the
+	// first error is returned
+	var firstErr error
+	progress, total := m.Progress, m.NumberOfParts
+	for r := range results {
+		err := r.Err
+		if err == nil {
+			err = acc.Add(r.Info)
 		}
-		partInfoList = append(partInfoList, m.Info)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+				cancel()
+			}
+			continue
+		}
+		parts[r.Info.PartNumber-1] = r.Info
+		done++
+		if progress != nil {
+			progress(done, total)
+		}
+	}
+	if err := parentCtx.Err(); err != nil {
+		m.writeIncompleteManifest(finishedParts(parts))
+		return nil, err
+	}
+	if firstErr != nil {
+		m.writeIncompleteManifest(finishedParts(parts))
+		return nil, firstErr
 	}
 
-	sort.Slice(partInfoList, func(i, j int) bool {
-		return partInfoList[i].PartNumber < partInfoList[j].PartNumber
-	})
+	manifest := m.buildManifest(parts, acc)
 
-	var manifest *ManifestFile
-	if len(partInfoList) > 1 {
-		h := m.hashPool.Get().(hash.Hash)
-		defer m.hashPool.Put(h)
-		h.Reset()
+	if m.IncludeFullObject {
+		fullObject, err := m.CalculateFullObjectChecksum(ctx)
+		if err != nil {
+			return nil, err
+		}
+		manifest.FullObjectChecksum = fullObject
+	}
 
-		etagChecksum := m.md5HashPool.Get().(hash.Hash)
-		defer m.md5HashPool.Put(etagChecksum)
-		etagChecksum.Reset()
+	return manifest, m.writeManifest(manifest)
+}
 
-		for _, part := range partInfoList {
-			h.Write(part.Checksum)
-			etagChecksum.Write(part.MD5Checksum)
+// calculateSequentialChecksum hashes the file in a single pass, front to
+// back, for full object algorithms and Sequential, still recording the
+// parts and the multipart ETag. The full object checksum is computed in
+// the same pass.
+func (m *MultipartFile) calculateSequentialChecksum(ctx context.Context) (*ManifestFile, error) {
+	var r io.Reader
+	if m.Reader != nil {
+		r = io.NewSectionReader(m.Reader, 0, m.FileSize)
+	} else {
+		f, err := os.Open(LongPath(m.FilePath))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	manifest, err := m.checksumReader(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return manifest, m.writeManifest(manifest)
+}
+
+// calculateLastPartChecksum hashes only the final part of the file.
+func (m *MultipartFile) calculateLastPartChecksum(ctx context.Context) (*ManifestFile, error) {
+	part, err := m.CalculateChecksumForPart(ctx, int32(m.NumberOfParts-1))
+	if err != nil {
+		return nil, err
+	}
+	manifest := &ManifestFile{
+		Filename:  m.manifestName(),
+		PartSize:  int(m.PartSize),
+		PartList:  []*PartInfo{part},
+		Algorithm: m.Algorithm,
+		Size:      m.FileSize,
+		Partial:   true,
+	}
+	return manifest, m.writeManifest(manifest)
+}
+
+func (m *MultipartFile) writeManifest(manifest *ManifestFile) error {
+	if m.ManifestFilePath == "" {
+		return nil
+	}
+	mf := []*ManifestFile{manifest}
+	var err error
+	if m.AppendManifest {
+		err = AppendToManifest(m.ManifestFilePath, mf)
+	} else {
+		err = WriteManifestFile(m.ManifestFilePath, mf)
+	}
+	if err != nil {
+		return fmt.Errorf("%w %s: %w", ErrWriteManifest, m.ManifestFilePath, err)
+	}
+	return nil
+}
+
+// CalculateFullObjectChecksum hashes the whole file in a single sequential
+// pass, independent of part boundaries.
+func (m *MultipartFile) CalculateFullObjectChecksum(ctx context.Context) (ByteSlice, error) {
+	var r io.Reader
+	if m.Reader != nil {
+		r = io.NewSectionReader(m.Reader, 0, m.FileSize)
+	} else {
+		f, err := os.Open(LongPath(m.FilePath))
+		if err != nil {
+			return nil, err
 		}
-		checksum := ByteSlice(h.Sum(nil))
-		etag := etagChecksum.Sum(nil)
+		defer f.Close()
+		r = f
+	}
+
+	buffer := m.bufferPool.Get()
+	defer m.bufferPool.Put(buffer)
 
+	h := m.HashFun()
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := r.Read(buffer.([]byte))
+		h.Write(buffer.([]byte)[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return h.Sum(nil), nil
+}
+
+// finishedParts returns the parts that were checksummed, leaving out the
+// gaps of an interrupted run.
+func finishedParts(parts []*PartInfo) []*PartInfo {
+	finished := []*PartInfo{}
+	for _, p := range parts {
+		if p != nil {
+			finished = append(finished, p)
+		}
+	}
+	return finished
+}
+
+// buildManifest records the parts, in part number order, and their
+// composite checksum and ETag from acc. A nil acc combines partInfoList.
+func (m *MultipartFile) buildManifest(partInfoList []*PartInfo, acc *CompositeAccumulator) *ManifestFile {
+	var manifest *ManifestFile
+	if len(partInfoList) == 0 {
+		// An empty object has the checksums of zero bytes
+		manifest = &ManifestFile{
+			Checksum: m.HashFun().Sum(nil),
+		}
+		if !m.SkipETag {
+			manifest.Etag = m.calculateEtag(nil)
+		}
+	} else if len(partInfoList) > 1 {
 		manifest = &ManifestFile{
 			PartList: partInfoList,
-			// Algorithm: m.ChecksumAlgorithm, TODO,
-			Etag:     etag,
-			Checksum: checksum,
+		}
+		if acc == nil {
+			acc = NewCompositeAccumulator(m.HashFun, 0)
+			for _, part := range partInfoList {
+				acc.Add(part)
+			}
+		}
+		// The parts of an interrupted run can have gaps, its incomplete
+		// manifest has no composite
+		if checksum, etag, err := acc.Sum(); err == nil {
+			manifest.Checksum = checksum
+			if !m.SkipETag {
+				manifest.Etag = etag
+			}
 		}
 	} else {
 		manifest = &ManifestFile{
@@ -236,35 +663,88 @@ func (m *MultipartFile) CalculateChecksum(ctx context.Context) (*ManifestFile, e
 			Checksum: partInfoList[0].Checksum,
 		}
 	}
-	manifest.Filename = m.FilePath
+	manifest.Filename = m.manifestName()
 	manifest.PartSize = int(m.PartSize)
 	manifest.Algorithm = m.Algorithm
-
-	var err error
-	if m.ManifestFilePath != "" {
-		mf := []*ManifestFile{manifest}
-		err = WriteSimpleManifest(m.ManifestFilePath, mf)
-		if err != nil {
-			log.Printf("error writing manifest file\n%s", err.Error())
+	if m.fullObject() {
+		manifest.ChecksumType = ChecksumTypeFullObject
+	}
+	manifest.Size = m.FileSize
+	if manifest.Size == 0 {
+		// Streams don't know their size up front
+		for _, part := range partInfoList {
+			manifest.Size += part.Size
 		}
 	}
 
-	return manifest, err
+	return manifest
 }
 
-func checkRequiredArgs(o *MultipartFileOpts) {
-	if o.FilePath == "" {
-		log.Fatal("FilePath is a required parameter")
+// manifestName is the Filename the file is recorded with.
+func (m *MultipartFile) manifestName() string {
+	if m.ManifestName != "" {
+		return m.ManifestName
 	}
+	return m.FilePath
+}
 
-	fileInfo, err := os.Stat(o.FilePath)
-	if err != nil {
-		log.Fatal(err.Error())
+func checkRequiredArgs(o *MultipartFileOpts) error {
+	if o.Reader != nil {
+		if f, ok := o.Reader.(*os.File); ok {
+			fileInfo, err := f.Stat()
+			if err != nil {
+				return err
+			}
+			o.FileSize = fileInfo.Size()
+		}
+	} else {
+		if o.FilePath == "" {
+			return fmt.Errorf("FilePath is a required parameter")
+		}
+
+		fileInfo, err := os.Stat(LongPath(o.FilePath))
+		if err != nil {
+			return err
+		}
+		o.FileSize = fileInfo.Size()
 	}
-	o.FileSize = fileInfo.Size()
 	o.NumRoutines = 16
+	return resolveHashFun(o)
+}
 
+// resolveHashFun sets HashFun from Algorithm, DefaultAlgorithm when empty,
+// unless it is already set, and validates ChecksumType against it.
+func resolveHashFun(o *MultipartFileOpts) error {
 	if o.HashFun == nil {
-		o.HashFun = sha256.New
+		if o.Algorithm == "" {
+			o.Algorithm = DefaultAlgorithm
+		}
+		a, err := LookupAlgorithm(o.Algorithm)
+		if err != nil {
+			return err
+		}
+		o.HashFun = a.HashFun
+		o.Algorithm = a.Name
+	}
+	return checkChecksumType(o)
+}
+
+// checkChecksumType validates o.ChecksumType against the algorithm.
+func checkChecksumType(o *MultipartFileOpts) error {
+	switch o.ChecksumType {
+	case "", ChecksumTypeFullObject:
+		return nil
+	case ChecksumTypeComposite:
+		if isFullObjectAlgorithm(o.Algorithm) {
+			return fmt.Errorf("%s is a full object algorithm and has no composite checksum", o.Algorithm)
+		}
+		return nil
 	}
+	return fmt.Errorf("unknown checksum type %q, expected %s or %s", o.ChecksumType, ChecksumTypeComposite, ChecksumTypeFullObject)
+}
+
+// fullObject reports whether the checksum is computed over the whole file
+// instead of being combined from the parts.
+func (o *MultipartFileOpts) fullObject() bool {
+	return o.ChecksumType == ChecksumTypeFullObject || isFullObjectAlgorithm(o.Algorithm)
 }
@@ -0,0 +1,310 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// writeTestFile writes size bytes of seeded random data to a file in a
+// test directory and returns its path and content.
+func writeTestFile(t testing.TB, size int) (string, []byte) {
+	t.Helper()
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path, data
+}
+
+// BenchmarkCalculateChecksum compares the read strategies of
+// CalculateChecksum: opening the file and seeking once per part, against
+// ReadAt on a single handle, opened by CalculateChecksum with OpenOnce or
+// passed in as Reader. The opens/op and seeks/op metrics are the open and
+// lseek syscalls each strategy makes per checksum.
+func BenchmarkCalculateChecksum(b *testing.B) {
+	const fileSize = 64 << 20
+	path, _ := writeTestFile(b, fileSize)
+
+	strategies := []struct {
+		name string
+		set  func(o *MultipartFileOpts, f *os.File)
+		// opens and seeks return the syscalls made for a file of parts
+		opens, seeks func(parts int) int
+	}{
+		{"open-seek", func(o *MultipartFileOpts, f *os.File) {}, func(parts int) int { return parts }, func(parts int) int { return parts }},
+		{"open-once", func(o *MultipartFileOpts, f *os.File) { o.OpenOnce = true }, func(int) int { return 1 }, func(int) int { return 0 }},
+		{"reader", func(o *MultipartFileOpts, f *os.File) { o.Reader = f }, func(int) int { return 0 }, func(int) int { return 0 }},
+	}
+	for _, partSize := range []int64{MIN_PART_SIZE, 16 << 20} {
+		for _, threads := range []int{1, 4, 16} {
+			for _, s := range strategies {
+				b.Run(fmt.Sprintf("part=%dMB/threads=%d/%s", partSize>>20, threads, s.name), func(b *testing.B) {
+					f, err := os.Open(path)
+					if err != nil {
+						b.Fatal(err)
+					}
+					defer f.Close()
+					b.SetBytes(fileSize)
+					parts := 0
+					for i := 0; i < b.N; i++ {
+						opts := MultipartFileOpts{FilePath: path, PartSize: partSize, Threads: threads}
+						s.set(&opts, f)
+						m, err := NewMultipartFile(opts)
+						if err != nil {
+							b.Fatal(err)
+						}
+						if _, err := m.CalculateChecksum(context.Background()); err != nil {
+							b.Fatal(err)
+						}
+						parts = m.NumberOfParts
+					}
+					b.ReportMetric(float64(s.opens(parts)), "opens/op")
+					b.ReportMetric(float64(s.seeks(parts)), "seeks/op")
+				})
+			}
+		}
+	}
+}
+
+// BenchmarkSequential compares the single front to back read of Sequential
+// with the concurrent reads at different offsets. Run it with -benchtime
+// on the disk to compare, the gain of Sequential is on spinning disks,
+// where concurrent reads make the heads seek.
+func BenchmarkSequential(b *testing.B) {
+	const fileSize = 64 << 20
+	path, _ := writeTestFile(b, fileSize)
+
+	modes := []struct {
+		name string
+		set  func(o *MultipartFileOpts)
+	}{
+		{"sequential", func(o *MultipartFileOpts) { o.Sequential = true }},
+		{"concurrent/threads=4", func(o *MultipartFileOpts) { o.Threads = 4 }},
+		{"concurrent/threads=16", func(o *MultipartFileOpts) { o.Threads = 16 }},
+	}
+	for _, mode := range modes {
+		b.Run(mode.name, func(b *testing.B) {
+			b.SetBytes(fileSize)
+			for i := 0; i < b.N; i++ {
+				opts := MultipartFileOpts{FilePath: path, PartSize: MIN_PART_SIZE}
+				mode.set(&opts)
+				m, err := NewMultipartFile(opts)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := m.CalculateChecksum(context.Background()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestSequentialMatchesConcurrent(t *testing.T) {
+	for _, size := range []int{100, MIN_PART_SIZE, 2 * MIN_PART_SIZE, 2*MIN_PART_SIZE + 1} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			path, _ := writeTestFile(t, size)
+			manifests := map[bool]*ManifestFile{}
+			for _, sequential := range []bool{false, true} {
+				m, err := NewMultipartFile(MultipartFileOpts{FilePath: path, PartSize: MIN_PART_SIZE, Threads: 4, Sequential: sequential})
+				if err != nil {
+					t.Fatal(err)
+				}
+				manifest, err := m.CalculateChecksum(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				manifests[sequential] = manifest
+			}
+			concurrent, err := json.Marshal(manifests[false])
+			if err != nil {
+				t.Fatal(err)
+			}
+			sequential, err := json.Marshal(manifests[true])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(concurrent, sequential) {
+				t.Errorf("sequential manifest\n%s\ndiffers from the concurrent one\n%s", sequential, concurrent)
+			}
+		})
+	}
+}
+
+func TestResolvePartSize(t *testing.T) {
+	tests := []struct {
+		name         string
+		fileSize     int64
+		partSize     int64
+		fitPartLimit bool
+		wantPartSize int64
+		parts        int
+		wantErr      bool
+	}{
+		{"smaller than a part", 1, MIN_PART_SIZE, false, MIN_PART_SIZE, 1, false},
+		{"one part", MIN_PART_SIZE, MIN_PART_SIZE, false, MIN_PART_SIZE, 1, false},
+		{"two parts", 2 * MIN_PART_SIZE, MIN_PART_SIZE, false, MIN_PART_SIZE, 2, false},
+		{"one byte over two parts", 2*MIN_PART_SIZE + 1, MIN_PART_SIZE, false, MIN_PART_SIZE, 3, false},
+		{"at the part limit", MAX_PARTS * MIN_PART_SIZE, MIN_PART_SIZE, false, MIN_PART_SIZE, MAX_PARTS, false},
+		{"one byte over the part limit", MAX_PARTS*MIN_PART_SIZE + 1, MIN_PART_SIZE, false, 0, 0, true},
+		// The SDK upload manager already raises the part size of a file of
+		// exactly MAX_PARTS parts
+		{"fit at the part limit", MAX_PARTS * MIN_PART_SIZE, MIN_PART_SIZE, true, MIN_PART_SIZE + 1, MAX_PARTS, false},
+		{"fit one byte over the part limit", MAX_PARTS*MIN_PART_SIZE + 1, MIN_PART_SIZE, true, MIN_PART_SIZE + 1, MAX_PARTS, false},
+		{"fit below the part limit", MAX_PARTS*MIN_PART_SIZE - 1, MIN_PART_SIZE, true, MIN_PART_SIZE, MAX_PARTS, false},
+		// Sizes a float64 can't hold exactly
+		{"beyond float precision", 3 * (1<<53 + 1), 1<<53 + 1, false, 1<<53 + 1, 3, false},
+		{"too small a part size", MIN_PART_SIZE, MIN_PART_SIZE - 1, false, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &MultipartFileOpts{FileSize: tt.fileSize, PartSize: tt.partSize, FitPartLimit: tt.fitPartLimit}
+			err := resolvePartSize(o)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error: %v", err, tt.wantErr)
+			}
+			if err == nil && o.PartSize != tt.wantPartSize {
+				t.Errorf("part size = %d, want %d", o.PartSize, tt.wantPartSize)
+			}
+			if err == nil && o.NumberOfParts != tt.parts {
+				t.Errorf("%d bytes in %d byte parts is %d parts, want %d", tt.fileSize, o.PartSize, o.NumberOfParts, tt.parts)
+			}
+		})
+	}
+}
+
+func TestCalculateChecksumManifestWriteError(t *testing.T) {
+	path, _ := writeTestFile(t, 1024)
+	m, err := NewMultipartFile(MultipartFileOpts{
+		FilePath:         path,
+		PartSize:         MIN_PART_SIZE,
+		ManifestFilePath: filepath.Join(t.TempDir(), "missing", "manifest.json"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := m.CalculateChecksum(context.Background())
+	if !errors.Is(err, ErrWriteManifest) {
+		t.Fatalf("err = %v, want ErrWriteManifest", err)
+	}
+	if manifest == nil || len(manifest.Checksum) == 0 {
+		t.Error("the manifest isn't returned along with the write error")
+	}
+}
+
+// TestCalculateChecksumCancel checks that a cancelled checksum of a large
+// sparse file stops promptly with ctx.Err() and leaves no goroutines behind.
+func TestCalculateChecksumCancel(t *testing.T) {
+	const fileSize = 4 << 30
+	path := filepath.Join(t.TempDir(), "sparse")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(fileSize); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	tests := []struct {
+		name string
+		// cancelAfter is the number of parts done before ctx is cancelled,
+		// 0 cancels it before CalculateChecksum is called
+		cancelAfter int
+	}{
+		{"cancelled before the start", 0},
+		{"cancelled while running", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelAfter == 0 {
+				cancel()
+			}
+			done := 0
+			m, err := NewMultipartFile(MultipartFileOpts{
+				FilePath: path,
+				PartSize: MIN_PART_SIZE,
+				Threads:  4,
+				Progress: func(completed, total int) {
+					done = completed
+					if completed == tt.cancelAfter {
+						cancel()
+					}
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+			_, err = m.CalculateChecksum(ctx)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("err = %v, want context.Canceled", err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("returned %v after the cancel", elapsed)
+			}
+			if done >= m.NumberOfParts {
+				t.Errorf("all %d parts were read after the cancel", done)
+			}
+
+			// The part producer notices the cancel asynchronously
+			deadline := time.Now().Add(time.Second)
+			for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if after := runtime.NumGoroutine(); after > before {
+				t.Errorf("%d goroutines before the checksum, %d after", before, after)
+			}
+		})
+	}
+}
+
+// TestCalculateChecksumWorkerCap checks that the goroutines of a checksum
+// are bounded by Threads, not by the number of parts. One byte parts get
+// past the part size and count limits to make many parts cheap.
+func TestCalculateChecksumWorkerCap(t *testing.T) {
+	const parts, threads = 50000, 2
+	path, _ := writeTestFile(t, parts)
+	opts := MultipartFileOpts{FilePath: path, FileSize: parts, PartSize: 1, NumberOfParts: parts, Threads: threads}
+	if err := resolveHashFun(&opts); err != nil {
+		t.Fatal(err)
+	}
+	before := runtime.NumGoroutine()
+	maxGoroutines := 0
+	opts.Progress = func(completed, total int) {
+		if n := runtime.NumGoroutine(); n > maxGoroutines {
+			maxGoroutines = n
+		}
+	}
+
+	manifest, err := newMultipartFile(opts).CalculateChecksum(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.PartList) != parts {
+		t.Errorf("%d parts in the manifest, want %d", len(manifest.PartList), parts)
+	}
+	// The workers, the goroutine handing out part numbers and the one
+	// closing the results
+	if limit := before + threads + 2; maxGoroutines > limit {
+		t.Errorf("%d goroutines while checksumming %d parts with %d threads, want at most %d", maxGoroutines, parts, threads, limit)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// errStreamStopped unblocks the copy into a stream whose checksum failed.
+var errStreamStopped = errors.New("checksum stream stopped")
+
+// ChecksumStreams is ChecksumStream of r with each of opts, which usually
+// differ in Algorithm, reading r only once: the data is copied to one
+// ChecksumStream per options, running concurrently. The manifests are
+// returned in the order of opts, ready for WriteManifestsByAlgorithm. The
+// first stream to fail stops all of them.
+func ChecksumStreams(ctx context.Context, r io.Reader, opts []MultipartFileOpts) ([]*ManifestFile, error) {
+	results := make([]*ManifestFile, len(opts))
+	errs := make([]error, len(opts))
+	writers := make([]*io.PipeWriter, len(opts))
+	multi := make([]io.Writer, len(opts))
+
+	wg := sync.WaitGroup{}
+	for i, o := range opts {
+		pr, pw := io.Pipe()
+		writers[i], multi[i] = pw, pw
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = ChecksumStream(ctx, pr, o)
+			// A stream that stopped early no longer reads, the copy would
+			// block on it
+			pr.CloseWithError(errStreamStopped)
+		}()
+	}
+
+	_, copyErr := io.Copy(io.MultiWriter(multi...), r)
+	for _, pw := range writers {
+		// A nil copyErr is the EOF of every stream
+		pw.CloseWithError(copyErr)
+	}
+	wg.Wait()
+
+	// The streams stopped by another one's failure report errStreamStopped,
+	// the cause is the other error
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, errStreamStopped) {
+			return nil, err
+		}
+	}
+	if copyErr != nil && !errors.Is(copyErr, errStreamStopped) {
+		return nil, copyErr
+	}
+	return results, nil
+}
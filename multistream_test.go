@@ -0,0 +1,61 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChecksumStreamsMatchesSingleStreams(t *testing.T) {
+	_, data := writeTestFile(t, 2*MIN_PART_SIZE+100)
+	names := AlgorithmNames()
+	opts := make([]MultipartFileOpts, len(names))
+	for i, name := range names {
+		opts[i] = MultipartFileOpts{FilePath: "data", PartSize: MIN_PART_SIZE, Algorithm: name}
+	}
+
+	got, err := ChecksumStreams(context.Background(), bytes.NewReader(data), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, o := range opts {
+		want, err := ChecksumStream(context.Background(), bytes.NewReader(data), o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got[i].Algorithm != o.Algorithm || !bytes.Equal(got[i].Checksum, want.Checksum) || !bytes.Equal(got[i].Etag, want.Etag) {
+			t.Errorf("%s: got %s %s, want %s %s", o.Algorithm, got[i].Algorithm, got[i].Checksum, want.Algorithm, want.Checksum)
+		}
+	}
+
+	dir := t.TempDir()
+	paths, err := WriteManifestsByAlgorithm(filepath.Join(dir, "manifest.json"), got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != len(names) {
+		t.Fatalf("wrote %d manifests, want %d", len(paths), len(names))
+	}
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(dir, "manifest."+name+".json")); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestChecksumStreamsStopsOnError(t *testing.T) {
+	_, data := writeTestFile(t, 2*MIN_PART_SIZE)
+	opts := []MultipartFileOpts{
+		{FilePath: "data", PartSize: MIN_PART_SIZE, Algorithm: "sha256"},
+		{FilePath: "data", PartSize: 1024, Algorithm: "sha256"},
+	}
+	_, err := ChecksumStreams(context.Background(), bytes.NewReader(data), opts)
+	if err == nil {
+		t.Fatal("a part size under the minimum was accepted")
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// Path modes of ManifestPath, for how a file is named in a manifest.
+const (
+	PathModeAbsolute = "absolute"
+	PathModeRelative = "relative"
+	PathModeBasename = "basename"
+)
+
+// ManifestPath returns path the way mode records it in a manifest:
+// absolute, relative to baseDir, or only its base name. An empty mode
+// keeps path as given. Relative paths stay portable when the tree under
+// baseDir moves to another machine, and may start with .. for files
+// outside baseDir.
+func ManifestPath(path, mode, baseDir string) (string, error) {
+	switch mode {
+	case "":
+		return path, nil
+	case PathModeAbsolute:
+		return filepath.Abs(path)
+	case PathModeRelative:
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", err
+		}
+		base, err := filepath.Abs(baseDir)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Rel(base, abs)
+	case PathModeBasename:
+		return filepath.Base(path), nil
+	}
+	return "", fmt.Errorf("unknown path mode %q, expected %s, %s or %s", mode, PathModeAbsolute, PathModeRelative, PathModeBasename)
+}
@@ -0,0 +1,107 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// DefaultProbePartSizes are the part sizes common upload tools use, in
+// bytes: the 5MB minimum and the 8MB AWS CLI default, then 16, 64 and
+// 128MB.
+var DefaultProbePartSizes = []int64{
+	5 * 1024 * 1024,
+	8 * 1024 * 1024,
+	16 * 1024 * 1024,
+	64 * 1024 * 1024,
+	128 * 1024 * 1024,
+}
+
+// ErrPartSizeNotFound is returned by ProbePartSize when none of the
+// candidate part sizes reproduce the ETag.
+var ErrPartSizeNotFound = errors.New("no candidate part size matches the ETag")
+
+// ProbePartSize finds the part size an object was uploaded with by
+// computing the multipart ETag of a local copy for each candidate part size
+// and comparing it with etag, in the hexmd5-N form S3 reports. Only
+// candidates that split the file into N parts are hashed. A nil candidates
+// uses DefaultProbePartSizes.
+func ProbePartSize(ctx context.Context, path, etag string, candidates []int64) (int64, error) {
+	etag = strings.Trim(etag, `"`)
+	digest, parts, err := splitPartCount(etag)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ETag %q: %w", etag, err)
+	}
+	if parts == 0 {
+		return 0, fmt.Errorf("ETag %q has no part count, the object wasn't uploaded in parts", etag)
+	}
+	want, err := hex.DecodeString(digest)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ETag %q: %w", etag, err)
+	}
+	if candidates == nil {
+		candidates = DefaultProbePartSizes
+	}
+
+	fileInfo, err := os.Stat(LongPath(path))
+	if err != nil {
+		return 0, err
+	}
+	size := fileInfo.Size()
+
+	for _, partSize := range candidates {
+		if partSize <= 0 {
+			return 0, fmt.Errorf("invalid candidate part size %d", partSize)
+		}
+		if (size+partSize-1)/partSize != int64(parts) {
+			continue
+		}
+		got, err := multipartEtag(ctx, path, partSize)
+		if err != nil {
+			return 0, err
+		}
+		if bytes.Equal(got, want) {
+			return partSize, nil
+		}
+	}
+	return 0, ErrPartSizeNotFound
+}
+
+// multipartEtag computes the ETag S3 gives a multipart upload of the file
+// with the given part size, without the -N suffix.
+func multipartEtag(ctx context.Context, path string, partSize int64) ([]byte, error) {
+	f, err := os.Open(LongPath(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	etag := md5.New()
+	h := md5.New()
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		h.Reset()
+		n, err := io.CopyN(h, f, partSize)
+		if n > 0 {
+			etag.Write(h.Sum(nil))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return etag.Sum(nil), nil
+}
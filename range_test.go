@@ -0,0 +1,123 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// fakeRangeClient serves ranged GetObject requests from object, failing
+// with err when it is set.
+type fakeRangeClient struct {
+	object []byte
+	err    error
+	ranges []string
+}
+
+func (c *fakeRangeClient) GetObject(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	c.ranges = append(c.ranges, *in.Range)
+	if c.err != nil {
+		return nil, c.err
+	}
+	var start, end int
+	if _, err := fmt.Sscanf(*in.Range, "bytes=%d-%d", &start, &end); err != nil {
+		return nil, err
+	}
+	end = min(end+1, len(c.object))
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(c.object[start:end]))}, nil
+}
+
+func TestChecksumFileRange(t *testing.T) {
+	path, data := writeTestFile(t, 1000)
+
+	got, err := ChecksumFileRange(path, 100, 300, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sha256.Sum256(data[100:400])
+	if !bytes.Equal(got, want[:]) {
+		t.Errorf("SHA256 %s, want %s", got, ByteSlice(want[:]))
+	}
+
+	got, err = ChecksumFileRange(path, 0, 1000, md5.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := md5.Sum(data); !bytes.Equal(got, want[:]) {
+		t.Errorf("MD5 %s, want %s", got, ByteSlice(want[:]))
+	}
+
+	if _, err := ChecksumFileRange(path, 900, 200, nil); err == nil {
+		t.Error("no error for a range past the end of the file")
+	}
+}
+
+func TestVerifyRange(t *testing.T) {
+	path, data := writeTestFile(t, 1000)
+	changed := bytes.Clone(data)
+	changed[600] ^= 0xff
+	denied := testAPIError("AccessDenied")
+
+	tests := []struct {
+		name      string
+		client    *fakeRangeClient
+		start     int64
+		length    int64
+		match     bool
+		wantRange string
+		wantErr   bool
+	}{
+		{"same bytes", &fakeRangeClient{object: data}, 500, 200, true, "bytes=500-699", false},
+		{"changed byte in the range", &fakeRangeClient{object: changed}, 500, 200, false, "bytes=500-699", false},
+		{"changed byte outside the range", &fakeRangeClient{object: changed}, 0, 200, true, "bytes=0-199", false},
+		{"short object", &fakeRangeClient{object: data[:800]}, 700, 200, false, "bytes=700-899", true},
+		{"GetObject error", &fakeRangeClient{object: data, err: denied}, 0, 200, false, "bytes=0-199", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check, err := VerifyRange(context.Background(), tt.client, "bucket", "key", path, tt.start, tt.length, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error: %v", err, tt.wantErr)
+			}
+			if len(tt.client.ranges) != 1 || tt.client.ranges[0] != tt.wantRange {
+				t.Errorf("requested %v, want %s", tt.client.ranges, tt.wantRange)
+			}
+			if err != nil {
+				if tt.client.err != nil && !errors.Is(err, tt.client.err) {
+					t.Errorf("err = %v, want %v", err, tt.client.err)
+				}
+				return
+			}
+			if check.Match() != tt.match {
+				t.Errorf("Match() = %v, want %v", check.Match(), tt.match)
+			}
+			local := sha256.Sum256(data[tt.start : tt.start+tt.length])
+			if !bytes.Equal(check.Local, local[:]) || check.Start != tt.start || check.Length != tt.length {
+				t.Errorf("check %+v, want local %s", check, ByteSlice(local[:]))
+			}
+		})
+	}
+}
+
+func TestVerifyRangeInvalid(t *testing.T) {
+	path, _ := writeTestFile(t, 1000)
+	for _, r := range [][2]int64{{-1, 10}, {0, 0}, {0, -5}} {
+		client := &fakeRangeClient{}
+		if _, err := VerifyRange(context.Background(), client, "bucket", "key", path, r[0], r[1], nil); err == nil {
+			t.Errorf("no error for start %d length %d", r[0], r[1])
+		}
+		if len(client.ranges) != 0 {
+			t.Errorf("start %d length %d requested %v", r[0], r[1], client.ranges)
+		}
+	}
+}
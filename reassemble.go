@@ -0,0 +1,157 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+var partNumberRe = regexp.MustCompile(`[0-9]+`)
+
+// ListPartFiles returns the files in dir ordered by part number. The part
+// number is the first run of digits in each file name, so part-00001,
+// 00001.bin and 1 are all part 1. The parts must be numbered 1 to N with
+// no gaps or duplicates.
+func ListPartFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(LongPath(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	byNumber := map[int]string{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		digits := partNumberRe.FindString(e.Name())
+		if digits == "" {
+			return nil, fmt.Errorf("%s: file name has no part number", filepath.Join(dir, e.Name()))
+		}
+		n, err := strconv.Atoi(digits)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid part number: %w", filepath.Join(dir, e.Name()), err)
+		}
+		if prev, ok := byNumber[n]; ok {
+			return nil, fmt.Errorf("%s and %s are both part %d", prev, e.Name(), n)
+		}
+		byNumber[n] = e.Name()
+	}
+	if len(byNumber) == 0 {
+		return nil, fmt.Errorf("%s has no part files", dir)
+	}
+
+	numbers := make([]int, 0, len(byNumber))
+	for n := range byNumber {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	paths := make([]string, 0, len(numbers))
+	for i, n := range numbers {
+		if n != i+1 {
+			return nil, fmt.Errorf("%s is missing part %d", dir, i+1)
+		}
+		paths = append(paths, filepath.Join(dir, byNumber[n]))
+	}
+	return paths, nil
+}
+
+// ChecksumPartFiles hashes each part file in dir, as ordered by
+// ListPartFiles, and combines them into the manifest S3 would report for an
+// object uploaded with that part layout.
+func ChecksumPartFiles(dir, algorithm string) (*ManifestFile, error) {
+	if algorithm == "" {
+		algorithm = DefaultAlgorithm
+	}
+	a, err := LookupAlgorithm(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := ListPartFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &ManifestFile{
+		Filename:  dir,
+		Algorithm: a.Name,
+		PartList:  []*PartInfo{},
+	}
+	for i, p := range paths {
+		part, err := checksumPartFile(p, int32(i+1), a)
+		if err != nil {
+			return nil, err
+		}
+		manifest.PartList = append(manifest.PartList, part)
+		manifest.Size += part.Size
+	}
+	manifest.PartSize = int(manifest.PartList[0].Size)
+
+	if manifest.Checksum, err = ComputeCompositeChecksum(manifest.PartList, a.HashFun); err != nil {
+		return nil, err
+	}
+	if len(manifest.PartList) == 1 {
+		manifest.Etag = manifest.PartList[0].MD5Checksum
+	} else {
+		manifest.Etag = combineChecksums(manifest.PartList, md5.New, func(p *PartInfo) []byte { return p.MD5Checksum })
+	}
+	return manifest, nil
+}
+
+func checksumPartFile(path string, partNumber int32, a *Algorithm) (*PartInfo, error) {
+	f, err := os.Open(LongPath(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := a.HashFun()
+	mh := md5.New()
+	n, err := io.Copy(io.MultiWriter(h, mh), f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &PartInfo{
+		PartNumber:  partNumber,
+		Size:        n,
+		Algorithm:   a.Name,
+		Checksum:    h.Sum(nil),
+		MD5Checksum: mh.Sum(nil),
+	}, nil
+}
+
+// VerifyReassembly checks that the part files in dir recombine to expected,
+// a composite checksum in the checksum-N form S3 reports, before the parts
+// are concatenated to restore the object. The computed manifest is returned
+// along with any mismatch.
+func VerifyReassembly(dir, algorithm, expected string) (*ManifestFile, error) {
+	checksum, parts, err := splitPartCount(expected)
+	if err != nil {
+		return nil, fmt.Errorf("invalid composite checksum %q: %w", expected, err)
+	}
+	want, err := decodeChecksum(checksum)
+	if err != nil {
+		return nil, fmt.Errorf("invalid composite checksum %q: %w", expected, err)
+	}
+
+	manifest, err := ChecksumPartFiles(dir, algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if parts > 0 && parts != len(manifest.PartList) {
+		return manifest, fmt.Errorf("%s has %d parts, expected %d", dir, len(manifest.PartList), parts)
+	}
+	if !bytes.Equal(want, manifest.Checksum) {
+		return manifest, withCategory(ErrChecksumMismatch, fmt.Errorf("%s recombines to %s-%d, expected %s", dir, manifest.Checksum, len(manifest.PartList), expected))
+	}
+	return manifest, nil
+}
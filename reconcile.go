@@ -0,0 +1,201 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultInventorySchema is the column layout of an S3 Inventory CSV report
+// that was configured with only the Size and ETag optional fields.
+const DefaultInventorySchema = "Bucket, Key, Size, ETag"
+
+// InventoryEntry is a single object listed in an S3 Inventory report.
+type InventoryEntry struct {
+	Bucket string
+	Key    string
+	Size   int64
+	ETag   string
+}
+
+// ReadInventoryCSV reads an S3 Inventory CSV data file, optionally gzipped.
+// schema is the fileSchema from the inventory's manifest.json; it must
+// include the Bucket, Key and ETag columns.
+func ReadInventoryCSV(inventoryPath string, schema string) ([]*InventoryEntry, error) {
+	columns := map[string]int{}
+	for i, c := range strings.Split(schema, ",") {
+		columns[strings.TrimSpace(c)] = i
+	}
+	for _, c := range []string{"Bucket", "Key", "ETag"} {
+		if _, ok := columns[c]; !ok {
+			return nil, fmt.Errorf("inventory schema %q has no %s column", schema, c)
+		}
+	}
+
+	f, err := os.Open(LongPath(inventoryPath))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(inventoryPath, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(columns)
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse inventory %s: %w", inventoryPath, err)
+	}
+
+	entries := []*InventoryEntry{}
+	for i, row := range rows {
+		// Inventory reports URL-encode the object keys
+		key, err := url.QueryUnescape(row[columns["Key"]])
+		if err != nil {
+			return nil, fmt.Errorf("invalid key on line %d of %s: %w", i+1, inventoryPath, err)
+		}
+		e := &InventoryEntry{
+			Bucket: row[columns["Bucket"]],
+			Key:    key,
+			ETag:   strings.Trim(row[columns["ETag"]], `"`),
+		}
+		if c, ok := columns["Size"]; ok && row[c] != "" {
+			if e.Size, err = strconv.ParseInt(row[c], 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid size on line %d of %s: %w", i+1, inventoryPath, err)
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// ReconcileMismatch is an object whose inventory ETag differs from the
+// ETag computed locally.
+type ReconcileMismatch struct {
+	Key           string
+	Filename      string
+	LocalETag     string
+	InventoryETag string
+}
+
+// ReconcileReport is the outcome of comparing a local manifest with an S3
+// Inventory report.
+type ReconcileReport struct {
+	Matched            []string
+	Mismatched         []ReconcileMismatch
+	MissingInInventory []string
+	MissingLocally     []string
+}
+
+// OK reports whether every object was found on both sides with equal ETags.
+func (r *ReconcileReport) OK() bool {
+	return len(r.Mismatched) == 0 && len(r.MissingInInventory) == 0 && len(r.MissingLocally) == 0
+}
+
+// Reconcile matches manifest entries to inventory objects by key and compares
+// their ETags. The key of a manifest entry is its Filename relative to root
+// (when root is set) joined onto prefix. Inventory objects outside prefix
+// are ignored.
+func Reconcile(mf []*ManifestFile, inventory []*InventoryEntry, prefix, root string) (*ReconcileReport, error) {
+	report := &ReconcileReport{}
+
+	objects := map[string]*InventoryEntry{}
+	for _, e := range inventory {
+		if strings.HasPrefix(e.Key, prefix) {
+			objects[e.Key] = e
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, m := range mf {
+		key, err := manifestKey(prefix, root, m.Filename)
+		if err != nil {
+			return nil, err
+		}
+		seen[key] = true
+
+		obj, ok := objects[key]
+		if !ok {
+			report.MissingInInventory = append(report.MissingInInventory, key)
+			continue
+		}
+		local := formatEtag(m.Etag, len(m.PartList))
+		if !strings.EqualFold(local, obj.ETag) {
+			report.Mismatched = append(report.Mismatched, ReconcileMismatch{
+				Key:           key,
+				Filename:      m.Filename,
+				LocalETag:     local,
+				InventoryETag: obj.ETag,
+			})
+			continue
+		}
+		report.Matched = append(report.Matched, key)
+	}
+
+	for key := range objects {
+		if !seen[key] {
+			report.MissingLocally = append(report.MissingLocally, key)
+		}
+	}
+
+	sort.Strings(report.Matched)
+	sort.Strings(report.MissingInInventory)
+	sort.Strings(report.MissingLocally)
+	sort.Slice(report.Mismatched, func(i, j int) bool {
+		return report.Mismatched[i].Key < report.Mismatched[j].Key
+	})
+	return report, nil
+}
+
+// ReconcileInventory loads a manifest written by WriteManifestFile and an
+// S3 Inventory CSV and reconciles them with Reconcile.
+func ReconcileInventory(manifestPath, inventoryPath, schema, prefix, root string) (*ReconcileReport, error) {
+	mf, err := readManifestFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	inventory, err := ReadInventoryCSV(inventoryPath, schema)
+	if err != nil {
+		return nil, err
+	}
+	return Reconcile(mf, inventory, prefix, root)
+}
+
+func manifestKey(prefix, root, filename string) (string, error) {
+	if root != "" {
+		return KeyForPath(prefix, root, filename)
+	}
+	key := strings.TrimPrefix(path.Clean(filepath.ToSlash(filename)), "/")
+	if prefix == "" {
+		return key, nil
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + key, nil
+}
+
+// formatEtag renders an ETag the way S3 does, with a -N suffix for
+// multipart objects. A missing ETag is empty.
+func formatEtag(etag []byte, parts int) string {
+	if len(etag) == 0 {
+		return ""
+	}
+	return withPartCount(fmt.Sprintf("%x", etag), parts)
+}
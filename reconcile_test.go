@@ -0,0 +1,104 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testInventory = `"bucket","backup/dir/my+file%2B1.txt","1024","""0123456789abcdef0123456789abcdef-2"""
+"bucket","backup/plain.bin","10","0123456789abcdef0123456789abcdef"
+`
+
+func TestReadInventoryCSV(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "inventory.csv")
+	if err := os.WriteFile(plain, []byte(testInventory), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gzipped := filepath.Join(dir, "inventory.csv.gz")
+	f, err := os.Create(gzipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	if _, err := gz.Write([]byte(testInventory)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	want := []*InventoryEntry{
+		{Bucket: "bucket", Key: "backup/dir/my file+1.txt", Size: 1024, ETag: "0123456789abcdef0123456789abcdef-2"},
+		{Bucket: "bucket", Key: "backup/plain.bin", Size: 10, ETag: "0123456789abcdef0123456789abcdef"},
+	}
+	for _, path := range []string{plain, gzipped} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			got, err := ReadInventoryCSV(path, DefaultInventorySchema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				for _, e := range got {
+					t.Logf("%+v", e)
+				}
+				t.Errorf("read %d entries that differ from the report", len(got))
+			}
+		})
+	}
+
+	if _, err := ReadInventoryCSV(plain, "Bucket, Key, Size"); err == nil {
+		t.Error("no error for a schema without ETag")
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	etag := []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+	twoParts := []*PartInfo{{PartNumber: 1}, {PartNumber: 2}}
+	mf := []*ManifestFile{
+		// A multipart ETag has the -N part count
+		{Filename: "/data/dir/my file+1.txt", Etag: etag, PartList: twoParts},
+		// A single PUT ETag has none
+		{Filename: "/data/plain.bin", Etag: etag},
+		// The inventory ETag of changed.bin is of another object
+		{Filename: "/data/changed.bin", Etag: etag, PartList: twoParts},
+		{Filename: "/data/new.bin", Etag: etag},
+	}
+	inventory := []*InventoryEntry{
+		{Key: "backup/dir/my file+1.txt", ETag: "0123456789ABCDEF0123456789ABCDEF-2"},
+		{Key: "backup/plain.bin", ETag: "0123456789abcdef0123456789abcdef"},
+		{Key: "backup/changed.bin", ETag: "0123456789abcdef0123456789abcdef"},
+		{Key: "backup/deleted.bin", ETag: "0123456789abcdef0123456789abcdef"},
+		// Outside the prefix, so ignored
+		{Key: "other/plain.bin", ETag: "ffff"},
+	}
+
+	report, err := Reconcile(mf, inventory, "backup", "/data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ReconcileReport{
+		Matched: []string{"backup/dir/my file+1.txt", "backup/plain.bin"},
+		Mismatched: []ReconcileMismatch{{
+			Key:           "backup/changed.bin",
+			Filename:      "/data/changed.bin",
+			LocalETag:     "0123456789abcdef0123456789abcdef-2",
+			InventoryETag: "0123456789abcdef0123456789abcdef",
+		}},
+		MissingInInventory: []string{"backup/new.bin"},
+		MissingLocally:     []string{"backup/deleted.bin"},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("report %+v, want %+v", report, want)
+	}
+	if report.OK() {
+		t.Error("OK() with mismatched and missing objects")
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Reasons a RemoteCheck can fail with.
+const (
+	// MismatchSize means the local file and the object differ in size.
+	MismatchSize = "size"
+	// MismatchPartSize means the object's parts can't be reproduced from
+	// the local file with a single part size, so its checksum can't be
+	// compared even though the content may be identical.
+	MismatchPartSize = "part-size"
+	// MismatchContent means the sizes and part layout agree but the
+	// checksums don't, so the data differs.
+	MismatchContent = "content"
+)
+
+// VerifyRemoteAPIClient is the S3 client methods VerifyRemote needs.
+type VerifyRemoteAPIClient interface {
+	GetObjectAttributesAPIClient
+	s3.HeadObjectAPIClient
+}
+
+// RemoteCheck is the outcome of VerifyRemote.
+type RemoteCheck struct {
+	Bucket    string
+	Key       string
+	LocalFile string
+	// Algorithm is the object's checksum algorithm, or "etag" when the
+	// object has no stored checksum and its ETag was compared instead.
+	Algorithm string
+	PartSize  int64
+	Parts     int
+	// Local and Remote are the checksums or ETags in the form S3 reports
+	// them, with the -N part count for multipart objects.
+	Local  string
+	Remote string
+	// Mismatch is one of the Mismatch constants, empty when the file
+	// matches, and Reason describes it.
+	Mismatch string
+	Reason   string
+	// MismatchedParts lists the parts whose checksums differ, when the
+	// object has part checksums to compare with.
+	MismatchedParts []int32
+	// StorageClass is the object's storage class, empty for STANDARD.
+	StorageClass string
+}
+
+// OK reports whether the local file matches the object.
+func (c *RemoteCheck) OK() bool {
+	return c.Mismatch == ""
+}
+
+// VerifyRemote checks that a local file has the same content as an S3
+// object without downloading it. The local checksum is computed with the
+// algorithm and part size of the object's stored checksum, read with
+// GetObjectAttributes. Objects without a stored checksum are compared on
+// their ETag instead, with the part size from the metadata written by
+// Upload with WriteChecksumMetadata, see ReadChecksumMetadata, or else from
+// a HeadObject of part 1; for SSE-KMS and SSE-C objects that returns
+// ErrEtagNotMD5. Neither reads
+// the object's data, so archived objects are verified without a restore. A
+// file that doesn't match isn't an error, see RemoteCheck.Mismatch.
+func VerifyRemote(ctx context.Context, client VerifyRemoteAPIClient, bucket, key, localPath string, threads int) (*RemoteCheck, error) {
+	if threads <= 0 {
+		threads = 16
+	}
+	fileInfo, err := os.Stat(LongPath(localPath))
+	if err != nil {
+		return nil, err
+	}
+	check := &RemoteCheck{Bucket: bucket, Key: key, LocalFile: localPath}
+
+	remote, err := FetchObjectAttributes(ctx, client, bucket, key)
+	if errors.Is(err, ErrNoObjectChecksum) {
+		// The part size Upload stored in the metadata saves looking it up
+		md, err := ReadChecksumMetadata(ctx, client, bucket, key)
+		if err != nil && !errors.Is(err, ErrNoChecksumMetadata) {
+			return nil, err
+		}
+		logger().Info("object has no stored checksum, comparing the ETag", "bucket", bucket, "key", key)
+		return check, verifyRemoteEtag(ctx, client, check, fileInfo.Size(), md)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	check.Algorithm = remote.Algorithm
+	check.StorageClass = remote.StorageClass
+	check.Parts = remote.Parts
+	check.Remote = remote.Checksum.String()
+	if remote.Parts > 0 {
+		check.Remote = withPartCount(check.Remote, remote.Parts)
+	}
+	if fileInfo.Size() != remote.Size {
+		check.Mismatch = MismatchSize
+		check.Reason = fmt.Sprintf("%s is %d bytes, the object is %d bytes", localPath, fileInfo.Size(), remote.Size)
+		return check, nil
+	}
+	if check.PartSize, err = remote.InferPartSize(); err != nil {
+		if !errors.Is(err, ErrIrregularParts) {
+			return nil, err
+		}
+		check.Mismatch = MismatchPartSize
+		check.Reason = err.Error()
+		return check, nil
+	}
+
+	local, err := checksumLocalCopy(ctx, localPath, threads, remote)
+	if err != nil {
+		return nil, err
+	}
+	check.Local = local.Checksum.String()
+	if remote.Parts > 0 {
+		check.Local = withPartCount(check.Local, len(local.PartList))
+	}
+	if remote.Parts > 0 && len(local.PartList) != remote.Parts {
+		check.Mismatch = MismatchPartSize
+		check.Reason = fmt.Sprintf("%s splits into %d parts of %d bytes, the object has %d", localPath, len(local.PartList), check.PartSize, remote.Parts)
+		return check, nil
+	}
+	if bytes.Equal(local.Checksum, remote.Checksum) {
+		return check, nil
+	}
+
+	check.Mismatch = MismatchContent
+	check.Reason = "checksums differ"
+	for i, want := range remote.PartList {
+		if len(want.Checksum) > 0 && i < len(local.PartList) && !bytes.Equal(want.Checksum, local.PartList[i].Checksum) {
+			check.MismatchedParts = append(check.MismatchedParts, want.PartNumber)
+		}
+	}
+	if len(check.MismatchedParts) > 0 {
+		check.Reason = fmt.Sprintf("%d of %d parts differ", len(check.MismatchedParts), remote.Parts)
+	}
+	return check, nil
+}
+
+// verifyRemoteEtag fills in check by comparing the object's ETag with the
+// one computed from the local file, for objects without a stored checksum.
+// Multipart objects take their part size from md, when the object has
+// checksum metadata, or from a second HeadObject of part 1.
+func verifyRemoteEtag(ctx context.Context, client s3.HeadObjectAPIClient, check *RemoteCheck, size int64, md *ChecksumMetadata) error {
+	check.Algorithm = "etag"
+	out, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: &check.Bucket,
+		Key:    &check.Key,
+	})
+	if err != nil {
+		return err
+	}
+	if out.ETag == nil {
+		return fmt.Errorf("s3://%s/%s has no ETag", check.Bucket, check.Key)
+	}
+	switch out.ServerSideEncryption {
+	case types.ServerSideEncryptionAwsKms, types.ServerSideEncryptionAwsKmsDsse:
+		return ErrEtagNotMD5
+	}
+	if out.SSECustomerAlgorithm != nil {
+		return ErrEtagNotMD5
+	}
+
+	etag, parts, err := splitPartCount(strings.Trim(*out.ETag, `"`))
+	if err != nil {
+		return fmt.Errorf("invalid ETag %q: %w", *out.ETag, err)
+	}
+	remote, err := hex.DecodeString(etag)
+	if err != nil {
+		return fmt.Errorf("invalid ETag %q: %w", *out.ETag, err)
+	}
+	check.StorageClass = string(out.StorageClass)
+	check.Parts = parts
+	check.Remote = formatEtag(remote, parts)
+	if objectSize := aws.ToInt64(out.ContentLength); size != objectSize {
+		check.Mismatch = MismatchSize
+		check.Reason = fmt.Sprintf("%s is %d bytes, the object is %d bytes", check.LocalFile, size, objectSize)
+		return nil
+	}
+
+	var local []byte
+	if parts == 0 {
+		check.PartSize = size
+		local, err = fileMD5(check.LocalFile)
+	} else {
+		if md != nil {
+			check.PartSize = md.PartSize
+		} else {
+			var part *s3.HeadObjectOutput
+			part, err = client.HeadObject(ctx, &s3.HeadObjectInput{
+				Bucket:     &check.Bucket,
+				Key:        &check.Key,
+				PartNumber: aws.Int32(1),
+			})
+			if err != nil {
+				return err
+			}
+			check.PartSize = aws.ToInt64(part.ContentLength)
+		}
+		if check.PartSize <= 0 {
+			return fmt.Errorf("s3://%s/%s has %d parts but no size for part 1", check.Bucket, check.Key, parts)
+		}
+		if localParts := int((size + check.PartSize - 1) / check.PartSize); localParts != parts {
+			check.Mismatch = MismatchPartSize
+			check.Reason = fmt.Sprintf("%s splits into %d parts of %d bytes, the object has %d", check.LocalFile, localParts, check.PartSize, parts)
+			return nil
+		}
+		local, err = multipartEtag(ctx, check.LocalFile, check.PartSize)
+	}
+	if err != nil {
+		return err
+	}
+	check.Local = formatEtag(local, parts)
+	if !bytes.Equal(local, remote) {
+		check.Mismatch = MismatchContent
+		check.Reason = "ETags differ"
+		if parts > 0 {
+			check.Reason = fmt.Sprintf("ETags differ, the data differs or the parts weren't all %d bytes", check.PartSize)
+		}
+	}
+	return nil
+}
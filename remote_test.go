@@ -0,0 +1,129 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// testEtag is the ETag S3 gives data uploaded in parts of partSize.
+func testEtag(data []byte, partSize int) string {
+	h := md5.New()
+	parts := 0
+	for off := 0; off < len(data); off += partSize {
+		sum := md5.Sum(data[off:min(off+partSize, len(data))])
+		h.Write(sum[:])
+		parts++
+	}
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(h.Sum(nil)), parts)
+}
+
+// fakeRemoteClient serves an object without a stored checksum, with an
+// ETag, optional user metadata, and part 1 of partSize bytes.
+type fakeRemoteClient struct {
+	etag     string
+	size     int64
+	partSize int64
+	metadata map[string]string
+	heads    []*s3.HeadObjectInput
+}
+
+func (c *fakeRemoteClient) GetObjectAttributes(ctx context.Context, in *s3.GetObjectAttributesInput, _ ...func(*s3.Options)) (*s3.GetObjectAttributesOutput, error) {
+	return &s3.GetObjectAttributesOutput{
+		ETag:        aws.String(c.etag),
+		ObjectSize:  aws.Int64(c.size),
+		ObjectParts: &types.GetObjectAttributesParts{TotalPartsCount: aws.Int32(2)},
+	}, nil
+}
+
+func (c *fakeRemoteClient) HeadObject(ctx context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	c.heads = append(c.heads, in)
+	if in.PartNumber != nil {
+		return &s3.HeadObjectOutput{ContentLength: aws.Int64(c.partSize), ETag: aws.String(c.etag)}, nil
+	}
+	return &s3.HeadObjectOutput{ContentLength: aws.Int64(c.size), ETag: aws.String(`"` + c.etag + `"`), Metadata: c.metadata}, nil
+}
+
+func TestReadChecksumMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		want     *ChecksumMetadata
+		wantErr  error
+	}{
+		{"both keys", map[string]string{MetadataPartSize: "8388608", MetadataAlgorithm: "sha256"}, &ChecksumMetadata{PartSize: 8388608, Algorithm: "sha256"}, nil},
+		{"no metadata", nil, nil, ErrNoChecksumMetadata},
+		{"part size only", map[string]string{MetadataPartSize: "8388608"}, nil, ErrNoChecksumMetadata},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeRemoteClient{metadata: tt.metadata}
+			got, err := ReadChecksumMetadata(context.Background(), client, "bucket", "key")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.want != nil && *got != *tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	client := &fakeRemoteClient{metadata: map[string]string{MetadataPartSize: "8MB", MetadataAlgorithm: "sha256"}}
+	if _, err := ReadChecksumMetadata(context.Background(), client, "bucket", "key"); err == nil {
+		t.Error("invalid part size metadata was accepted")
+	}
+}
+
+func TestVerifyRemoteEtagPartSize(t *testing.T) {
+	const partSize = MIN_PART_SIZE
+	path, data := writeTestFile(t, partSize+1024)
+
+	tests := []struct {
+		name      string
+		metadata  map[string]string
+		partHeads int
+	}{
+		// The metadata gives the part size, part 1 isn't looked up
+		{"from metadata", map[string]string{MetadataPartSize: fmt.Sprint(partSize), MetadataAlgorithm: "sha256"}, 0},
+		{"from part 1", nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeRemoteClient{
+				etag:     testEtag(data, partSize),
+				size:     int64(len(data)),
+				partSize: partSize,
+				metadata: tt.metadata,
+			}
+			check, err := VerifyRemote(context.Background(), client, "bucket", "key", path, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !check.OK() {
+				t.Fatalf("%s mismatch: %s", check.Mismatch, check.Reason)
+			}
+			if check.PartSize != partSize {
+				t.Errorf("part size %d, want %d", check.PartSize, partSize)
+			}
+			partHeads := 0
+			for _, in := range client.heads {
+				if in.PartNumber != nil {
+					partHeads++
+				}
+			}
+			if partHeads != tt.partHeads {
+				t.Errorf("%d HeadObject calls of part 1, want %d", partHeads, tt.partHeads)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// VerifyRemoteAllOptions configures VerifyRemoteAll.
+type VerifyRemoteAllOptions struct {
+	Bucket string
+	// Files are the local files to verify.
+	Files []string
+	// KeyPrefix and Root derive the key of every file the way
+	// UploadAllOptions does, so the objects UploadAll wrote are found.
+	KeyPrefix string
+	Root      string
+	// Concurrency is how many files are verified at a time, 4 by default.
+	// Each reads its local file with Threads threads.
+	Concurrency int
+	Threads     int
+	// S3Concurrency caps the S3 requests in flight across every file,
+	// separately from the local reads bounded by Concurrency. 0 leaves
+	// the S3 requests bounded by Concurrency alone.
+	S3Concurrency int
+}
+
+// VerifyRemoteAllResult is the outcome of VerifyRemoteAll.
+type VerifyRemoteAllResult struct {
+	// Checks are in the order of Files, nil for the files that failed.
+	Checks []*RemoteCheck
+	// Throttled counts the S3 requests that were throttled, including the
+	// ones that later succeeded when the SDK retried them. A high count
+	// means S3Concurrency should be lowered.
+	Throttled int64
+}
+
+// VerifyRemoteAll runs VerifyRemote for every file in opts.Files,
+// Concurrency files at a time, while holding at most S3Concurrency S3
+// requests in flight. A failed file doesn't stop the others; all failures
+// are returned together.
+func VerifyRemoteAll(ctx context.Context, client VerifyRemoteAPIClient, opts *VerifyRemoteAllOptions) (*VerifyRemoteAllResult, error) {
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("bucket is a required parameter")
+	}
+	if opts.S3Concurrency < 0 {
+		return nil, fmt.Errorf("S3 concurrency must be a positive value")
+	}
+	keys, err := uploadKeys(&UploadAllOptions{Files: opts.Files, KeyPrefix: opts.KeyPrefix, Root: opts.Root})
+	if err != nil {
+		return nil, err
+	}
+
+	limited := &limitedRemoteClient{client: client}
+	if opts.S3Concurrency > 0 {
+		limited.sem = make(chan struct{}, opts.S3Concurrency)
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	limiter := make(chan struct{}, concurrency)
+	result := &VerifyRemoteAllResult{Checks: make([]*RemoteCheck, len(opts.Files))}
+	errs := make([]error, len(opts.Files))
+
+	wg := sync.WaitGroup{}
+	for i, file := range opts.Files {
+		limiter <- struct{}{}
+		wg.Add(1)
+		go func(i int, file string) {
+			defer wg.Done()
+			defer func() { <-limiter }()
+
+			check, err := VerifyRemote(ctx, limited, opts.Bucket, keys[i], file, opts.Threads)
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", file, err)
+				return
+			}
+			result.Checks[i] = check
+		}(i, file)
+	}
+	wg.Wait()
+
+	result.Throttled = limited.throttled.Load()
+	return result, errors.Join(errs...)
+}
+
+// limitedRemoteClient holds a slot of sem for every request it passes on
+// to client, and counts the attempts S3 throttled.
+type limitedRemoteClient struct {
+	client VerifyRemoteAPIClient
+	// sem is nil when the requests aren't limited
+	sem       chan struct{}
+	throttled atomic.Int64
+}
+
+func (c *limitedRemoteClient) GetObjectAttributes(ctx context.Context, in *s3.GetObjectAttributesInput, optFns ...func(*s3.Options)) (*s3.GetObjectAttributesOutput, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.release()
+	return c.client.GetObjectAttributes(ctx, in, append(optFns, c.countThrottles(*in.Key))...)
+}
+
+func (c *limitedRemoteClient) HeadObject(ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.release()
+	return c.client.HeadObject(ctx, in, append(optFns, c.countThrottles(*in.Key))...)
+}
+
+func (c *limitedRemoteClient) acquire(ctx context.Context) error {
+	if c.sem == nil {
+		return nil
+	}
+	select {
+	case c.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *limitedRemoteClient) release() {
+	if c.sem != nil {
+		<-c.sem
+	}
+}
+
+// countThrottles wraps the retryer of a request so every throttled
+// attempt is counted and logged, including the ones the SDK retries.
+func (c *limitedRemoteClient) countThrottles(key string) func(*s3.Options) {
+	return func(o *s3.Options) {
+		if r, ok := o.Retryer.(aws.RetryerV2); ok {
+			o.Retryer = &throttleCountingRetryer{RetryerV2: r, client: c, key: key}
+		}
+	}
+}
+
+// throttleCountingRetryer is the retryer of a request, counting the
+// attempts that fail with a throttling error.
+type throttleCountingRetryer struct {
+	aws.RetryerV2
+	client *limitedRemoteClient
+	key    string
+}
+
+func (r *throttleCountingRetryer) IsErrorRetryable(err error) bool {
+	if retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary {
+		n := r.client.throttled.Add(1)
+		logger().Warn("S3 throttled a request", "key", r.key, "throttled", n, "error", err)
+	}
+	return r.RetryerV2.IsErrorRetryable(err)
+}
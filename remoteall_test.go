@@ -0,0 +1,147 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// testAPIError is an S3 error response with an error code.
+type testAPIError string
+
+func (e testAPIError) Error() string     { return string(e) }
+func (e testAPIError) ErrorCode() string { return string(e) }
+
+// fakeAttributesClient serves single part objects with the SHA256 of
+// objects, tracking how many requests are in flight. Requests for the keys
+// in throttle have a first attempt throttled, as the SDK's retry loop
+// would see it.
+type fakeAttributesClient struct {
+	objects  map[string][]byte
+	throttle map[string]bool
+
+	inFlight    atomic.Int32
+	maxInFlight atomic.Int32
+}
+
+func (c *fakeAttributesClient) GetObjectAttributes(ctx context.Context, in *s3.GetObjectAttributesInput, optFns ...func(*s3.Options)) (*s3.GetObjectAttributesOutput, error) {
+	n := c.inFlight.Add(1)
+	defer c.inFlight.Add(-1)
+	for {
+		max := c.maxInFlight.Load()
+		if n <= max || c.maxInFlight.CompareAndSwap(max, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if c.throttle[*in.Key] {
+		o := s3.Options{Retryer: retry.NewStandard()}
+		for _, fn := range optFns {
+			fn(&o)
+		}
+		if !o.Retryer.IsErrorRetryable(testAPIError("SlowDown")) {
+			return nil, fmt.Errorf("SlowDown isn't retried")
+		}
+	}
+	data, ok := c.objects[*in.Key]
+	if !ok {
+		return nil, testAPIError("NoSuchKey")
+	}
+	sum := sha256.Sum256(data)
+	return &s3.GetObjectAttributesOutput{
+		ObjectSize: aws.Int64(int64(len(data))),
+		Checksum:   &types.Checksum{ChecksumSHA256: aws.String(base64.StdEncoding.EncodeToString(sum[:]))},
+	}, nil
+}
+
+func (c *fakeAttributesClient) HeadObject(ctx context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	return nil, fmt.Errorf("unexpected HeadObject of %s", *in.Key)
+}
+
+func TestVerifyRemoteAll(t *testing.T) {
+	root := t.TempDir()
+	client := &fakeAttributesClient{objects: map[string][]byte{}, throttle: map[string]bool{}}
+	files := []string{}
+	for i := 0; i < 20; i++ {
+		data := []byte(fmt.Sprintf("file %d", i))
+		path := filepath.Join(root, fmt.Sprintf("f%02d", i))
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+		key := fmt.Sprintf("backups/f%02d", i)
+		switch i {
+		case 3:
+			data = []byte("changed")
+		case 4:
+			continue
+		case 5, 6:
+			client.throttle[key] = true
+		}
+		client.objects[key] = data
+	}
+
+	result, err := VerifyRemoteAll(context.Background(), client, &VerifyRemoteAllOptions{
+		Bucket:        "bucket",
+		Files:         files,
+		KeyPrefix:     "backups",
+		Root:          root,
+		Concurrency:   8,
+		S3Concurrency: 2,
+	})
+	if err == nil || !errors.Is(err, testAPIError("NoSuchKey")) {
+		t.Errorf("err = %v, want the missing object's error", err)
+	}
+	if got := client.maxInFlight.Load(); got > 2 {
+		t.Errorf("%d requests were in flight, S3Concurrency is 2", got)
+	}
+	if result.Throttled != 2 {
+		t.Errorf("Throttled = %d, want 2", result.Throttled)
+	}
+	for i, check := range result.Checks {
+		switch {
+		case i == 4:
+			if check != nil {
+				t.Errorf("file %d has no object but was checked: %+v", i, check)
+			}
+		case check == nil:
+			t.Errorf("file %d wasn't checked", i)
+		case check.OK() != (i != 3):
+			t.Errorf("file %d: OK() = %v, mismatch %q", i, check.OK(), check.Mismatch)
+		}
+	}
+}
+
+func TestLimitedRemoteClientCancel(t *testing.T) {
+	c := &limitedRemoteClient{client: &fakeAttributesClient{}, sem: make(chan struct{}, 1)}
+	c.sem <- struct{}{}
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := c.GetObjectAttributes(ctx, &s3.GetObjectAttributesInput{Key: aws.String("key")})
+		if err != context.Canceled {
+			t.Errorf("err = %v, want context.Canceled while waiting for a slot", err)
+		}
+	}()
+	cancel()
+	wg.Wait()
+}
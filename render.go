@@ -0,0 +1,468 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Renderer writes a set of manifests to w in a specific output format.
+type Renderer interface {
+	Render(w io.Writer, mf []*ManifestFile) error
+}
+
+// RendererFunc adapts an ordinary function to the Renderer interface.
+type RendererFunc func(w io.Writer, mf []*ManifestFile) error
+
+func (f RendererFunc) Render(w io.Writer, mf []*ManifestFile) error {
+	return f(w, mf)
+}
+
+// EncodingRendererFunc is a Renderer for formats that print checksums as
+// text, in the Encoding chosen with NewRendererWithEncoding. Used as a
+// plain Renderer it prints them with ByteSlice.String.
+type EncodingRendererFunc func(w io.Writer, mf []*ManifestFile, enc Encoding) error
+
+func (f EncodingRendererFunc) Render(w io.Writer, mf []*ManifestFile) error {
+	return f(w, mf, "")
+}
+
+// PartsRendererFunc is a Renderer for formats that print a line per part,
+// which NewRendererWithOptions can send to a separate writer from the rest
+// of the output. Used as a plain Renderer it prints everything to w, with
+// checksums in ByteSlice.String.
+type PartsRendererFunc func(w, partsW io.Writer, mf []*ManifestFile, enc Encoding) error
+
+func (f PartsRendererFunc) Render(w io.Writer, mf []*ManifestFile) error {
+	return f(w, w, mf, "")
+}
+
+// RenderOptions are the settings NewRendererWithOptions applies to the
+// formats that support them.
+type RenderOptions struct {
+	// Encoding is how formats that print checksums as text encode them.
+	Encoding Encoding
+	// PartsWriter, when set, gets the per-part lines of the formats that
+	// print them, instead of the writer passed to Render. Pass io.Discard
+	// to leave the parts out.
+	PartsWriter io.Writer
+}
+
+// renderersMu guards renderers, which RegisterRenderer can change while
+// output is being rendered.
+var renderersMu sync.RWMutex
+
+var renderers = map[string]Renderer{
+	"text":    PartsRendererFunc(RenderText),
+	"summary": EncodingRendererFunc(renderSummary),
+	"json":    RendererFunc(renderJSON),
+	"jsonl":   RendererFunc(renderJSONLines),
+	"csv":     EncodingRendererFunc(renderCSV),
+	// json-both carries every checksum in both encodings so consumers
+	// don't have to re-encode
+	"json-both": RendererFunc(renderJSONBothEncodings),
+	"in-toto":   RendererFunc(renderInToto),
+	"oci":       RendererFunc(renderOCIDigest),
+}
+
+// fullObjectFormats are the formats that print the full object checksum
+// rather than the S3 composite.
+var fullObjectFormats = map[string]bool{
+	"in-toto": true,
+	"oci":     true,
+}
+
+// NeedsFullObject reports whether format prints the full object checksum,
+// so MultipartFileOpts.IncludeFullObject must be set to render it.
+func NeedsFullObject(format string) bool {
+	return fullObjectFormats[format]
+}
+
+// RegisterRenderer makes a Renderer available under the given format name.
+// Registering a name that already exists replaces the previous renderer.
+func RegisterRenderer(format string, r Renderer) {
+	renderersMu.Lock()
+	defer renderersMu.Unlock()
+	renderers[format] = r
+}
+
+// NewRenderer returns the Renderer registered for format.
+func NewRenderer(format string) (Renderer, error) {
+	renderersMu.RLock()
+	r, ok := renderers[format]
+	renderersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown output format %q, expected one of: %s", format, strings.Join(Formats(), ", "))
+	}
+	return r, nil
+}
+
+// NewRendererWithEncoding returns the Renderer registered for format,
+// printing checksums in enc. Formats with a fixed checksum encoding, such
+// as json, ignore enc.
+func NewRendererWithEncoding(format string, enc Encoding) (Renderer, error) {
+	return NewRendererWithOptions(format, RenderOptions{Encoding: enc})
+}
+
+// NewRendererWithOptions returns the Renderer registered for format with
+// opts applied. Options a format doesn't support are ignored.
+func NewRendererWithOptions(format string, opts RenderOptions) (Renderer, error) {
+	r, err := NewRenderer(format)
+	if err != nil {
+		return nil, err
+	}
+	switch f := r.(type) {
+	case EncodingRendererFunc:
+		return RendererFunc(func(w io.Writer, mf []*ManifestFile) error {
+			return f(w, mf, opts.Encoding)
+		}), nil
+	case PartsRendererFunc:
+		return RendererFunc(func(w io.Writer, mf []*ManifestFile) error {
+			partsW := opts.PartsWriter
+			if partsW == nil {
+				partsW = w
+			}
+			return f(w, partsW, mf, opts.Encoding)
+		}), nil
+	}
+	return r, nil
+}
+
+// Formats returns the sorted names of all registered output formats.
+func Formats() []string {
+	renderersMu.RLock()
+	names := make([]string, 0, len(renderers))
+	for k := range renderers {
+		names = append(names, k)
+	}
+	renderersMu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// withPartCount appends the -N part count S3 adds to the checksum and ETag
+// of multipart objects. Objects uploaded with a single PUT have none.
+func withPartCount(s string, parts int) string {
+	if parts > 0 {
+		return fmt.Sprintf("%s-%d", s, parts)
+	}
+	return s
+}
+
+// algorithmLabel is the upper-cased algorithm name used in human readable output.
+func algorithmLabel(algorithm string) string {
+	if algorithm == "" {
+		return "SHA256"
+	}
+	return strings.ToUpper(algorithm)
+}
+
+// RenderText writes the text format with the per-part lines going to
+// partsW and the file names and summaries to w, so redirecting w captures
+// only the summaries. Pass io.Discard as partsW to leave the parts out.
+// Checksums are printed in enc, see ByteSlice.Encode.
+func RenderText(w, partsW io.Writer, mf []*ManifestFile, enc Encoding) error {
+	for _, v := range mf {
+		if len(mf) > 1 {
+			if _, err := fmt.Fprintf(w, "File: %s\n", v.Filename); err != nil {
+				return err
+			}
+		}
+		for _, part := range v.PartList {
+			if _, err := fmt.Fprintf(partsW, "Part: %05d\t\t%s\n", part.PartNumber, part.Checksum.Encode(enc)); err != nil {
+				return err
+			}
+		}
+		if err := writeSummary(w, v, enc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderSummary(w io.Writer, mf []*ManifestFile, enc Encoding) error {
+	for _, v := range mf {
+		if len(mf) > 1 {
+			if _, err := fmt.Fprintf(w, "File: %s\n", v.Filename); err != nil {
+				return err
+			}
+		}
+		if err := writeSummary(w, v, enc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeSummary(w io.Writer, v *ManifestFile, enc Encoding) error {
+	if v.Partial {
+		_, err := fmt.Fprintf(w, "File size:\t%d\nLast part only, this is not a full integrity check\n", v.Size)
+		return err
+	}
+	label := "Amazon S3 " + algorithmLabel(v.Algorithm)
+	if !isS3Compatible(v.Algorithm) {
+		label = algorithmLabel(v.Algorithm) + " (not comparable to Amazon S3)"
+	}
+	if v.isFullObject() {
+		// Full object checksums have no part count
+		if _, err := fmt.Fprintf(w, "%s:\t%s\n", label, v.Checksum.Encode(enc)); err != nil {
+			return err
+		}
+	} else if _, err := fmt.Fprintf(w, "%s:\t%s\n", label, withPartCount(v.Checksum.Encode(enc), len(v.PartList))); err != nil {
+		return err
+	}
+	if len(v.FullObjectChecksum) > 0 {
+		if _, err := fmt.Fprintf(w, "Full object %s:\t%s\n", algorithmLabel(v.Algorithm), v.FullObjectChecksum.Encode(enc)); err != nil {
+			return err
+		}
+	}
+	if len(v.Etag) == 0 {
+		// Skipped with SkipETag
+		return nil
+	}
+	_, err := fmt.Fprintf(w, "Amazon S3 Etag:\t%s\n", formatEtag(v.Etag, len(v.PartList)))
+	return err
+}
+
+func renderJSON(w io.Writer, mf []*ManifestFile) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(mf)
+}
+
+func renderJSONLines(w io.Writer, mf []*ManifestFile) error {
+	enc := json.NewEncoder(w)
+	for _, v := range mf {
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncodedChecksum is a checksum in both of the encodings S3 tooling uses.
+type EncodedChecksum struct {
+	Hex    string `json:"hex"`
+	Base64 string `json:"base64"`
+}
+
+func encodeBoth(b []byte) EncodedChecksum {
+	return EncodedChecksum{
+		Hex:    hex.EncodeToString(b),
+		Base64: base64.StdEncoding.EncodeToString(b),
+	}
+}
+
+type encodedPartInfo struct {
+	PartNumber  int32           `json:"part_number"`
+	Size        int64           `json:"size"`
+	Algorithm   string          `json:"algorithm"`
+	Checksum    EncodedChecksum `json:"checksum"`
+	MD5Checksum EncodedChecksum `json:"md5_checksum"`
+	// RollingChecksum is the Adler-32 value, it has no byte encoding
+	RollingChecksum uint32 `json:"rolling_checksum,omitempty"`
+}
+
+type encodedManifestFile struct {
+	Filename  string             `json:"filename"`
+	PartSize  int                `json:"part_size"`
+	PartList  []*encodedPartInfo `json:"part_list"`
+	Checksum  EncodedChecksum    `json:"checksum"`
+	Etag      EncodedChecksum    `json:"etag"`
+	Algorithm string             `json:"algorithm"`
+	// ChecksumType and FullObjectChecksum are as in ManifestFile
+	ChecksumType       string           `json:"checksum_type,omitempty"`
+	FullObjectChecksum *EncodedChecksum `json:"full_object_checksum,omitempty"`
+	Size               int64            `json:"size,omitempty"`
+	Partial            bool             `json:"partial,omitempty"`
+}
+
+func renderJSONBothEncodings(w io.Writer, mf []*ManifestFile) error {
+	out := make([]*encodedManifestFile, 0, len(mf))
+	for _, v := range mf {
+		e := &encodedManifestFile{
+			Filename:     v.Filename,
+			PartSize:     v.PartSize,
+			PartList:     make([]*encodedPartInfo, 0, len(v.PartList)),
+			Checksum:     encodeBoth(v.Checksum),
+			Etag:         encodeBoth(v.Etag),
+			Algorithm:    v.Algorithm,
+			ChecksumType: v.ChecksumType,
+			Size:         v.Size,
+			Partial:      v.Partial,
+		}
+		if len(v.FullObjectChecksum) > 0 {
+			fullObject := encodeBoth(v.FullObjectChecksum)
+			e.FullObjectChecksum = &fullObject
+		}
+		for _, p := range v.PartList {
+			e.PartList = append(e.PartList, &encodedPartInfo{
+				PartNumber:      p.PartNumber,
+				Size:            p.Size,
+				Algorithm:       p.Algorithm,
+				Checksum:        encodeBoth(p.Checksum),
+				MD5Checksum:     encodeBoth(p.MD5Checksum),
+				RollingChecksum: p.RollingChecksum,
+			})
+		}
+		out = append(out, e)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// lastPartPrefix marks the checksum and etag columns of a Partial manifest
+// in the CSV format.
+const lastPartPrefix = "last-part:"
+
+// renderCSV writes the same columns as WriteSimpleManifest.
+func renderCSV(w io.Writer, mf []*ManifestFile, enc Encoding) error {
+	rows := [][]string{}
+	for _, v := range mf {
+		partSize := fmt.Sprintf("%d", v.PartSize)
+		checksumOfChecksums := withPartCount(v.Checksum.Encode(enc), len(v.PartList))
+		etag := formatEtag(v.Etag, len(v.PartList))
+		if v.isFullObject() {
+			checksumOfChecksums = v.Checksum.Encode(enc)
+		}
+		if v.Partial && len(v.PartList) > 0 {
+			// Only the last part was hashed, so record its checksums and
+			// number instead of a composite
+			last := v.PartList[len(v.PartList)-1]
+			checksumOfChecksums = fmt.Sprintf("%s%s-%d", lastPartPrefix, last.Checksum.Encode(enc), last.PartNumber)
+			etag = ""
+			if len(last.MD5Checksum) > 0 {
+				etag = fmt.Sprintf("%s%x-%d", lastPartPrefix, []byte(last.MD5Checksum), last.PartNumber)
+			}
+		}
+
+		rows = append(rows, []string{
+			v.Filename,
+			partSize,
+			v.Algorithm,
+			checksumOfChecksums,
+			etag,
+		})
+	}
+
+	return csv.NewWriter(w).WriteAll(rows)
+}
+
+// fullObjectDigest returns the checksum of the whole file, which is the
+// manifest checksum itself for full object algorithms.
+func fullObjectDigest(v *ManifestFile) ByteSlice {
+	if v.isFullObject() {
+		return v.Checksum
+	}
+	return v.FullObjectChecksum
+}
+
+// resourceDescriptor is the subset of an in-toto ResourceDescriptor that
+// identifies an artifact by name and digest.
+type resourceDescriptor struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// inTotoDigestNames maps algorithm names to the digest names of the in-toto
+// DigestSet. Algorithms without one, such as the CRCs, can't be used in a
+// ResourceDescriptor.
+var inTotoDigestNames = map[string]string{
+	"sha1":       "sha1",
+	"sha224":     "sha224",
+	"sha256":     "sha256",
+	"sha384":     "sha384",
+	"sha512":     "sha512",
+	"sha512/224": "sha512_224",
+	"sha512/256": "sha512_256",
+	"sha3-224":   "sha3_224",
+	"sha3-256":   "sha3_256",
+	"sha3-384":   "sha3_384",
+	"sha3-512":   "sha3_512",
+	"blake2b":    "blake2b",
+	"blake2s":    "blake2s",
+	"md5":        "md5",
+}
+
+// inTotoDigestName returns the in-toto digest name of algorithm, which
+// defaults to DefaultAlgorithm.
+func inTotoDigestName(algorithm string) (string, error) {
+	if algorithm == "" {
+		algorithm = DefaultAlgorithm
+	}
+	key := strings.ToLower(algorithm)
+	if name, ok := inTotoDigestNames[key]; ok {
+		return name, nil
+	}
+	// The in-toto spelling of a name is accepted as well
+	for _, name := range inTotoDigestNames {
+		if name == key {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("%s has no in-toto digest name", algorithm)
+}
+
+// renderInToto writes the full object digests as a list of in-toto
+// ResourceDescriptors, ready to use as the subject of an attestation. The
+// S3 composite isn't a digest of the file, so it's left out.
+func renderInToto(w io.Writer, mf []*ManifestFile) error {
+	out := make([]*resourceDescriptor, 0, len(mf))
+	for _, v := range mf {
+		digest := fullObjectDigest(v)
+		if len(digest) == 0 {
+			return fmt.Errorf("%s: in-toto output needs the full object checksum", v.Filename)
+		}
+		name, err := inTotoDigestName(v.Algorithm)
+		if err != nil {
+			return fmt.Errorf("%s: %w", v.Filename, err)
+		}
+		out = append(out, &resourceDescriptor{
+			Name:   v.Filename,
+			Digest: map[string]string{name: hex.EncodeToString(digest)},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// renderOCIDigest prints the full object checksum of each file as an OCI
+// content digest, algorithm:hex, labelled so it isn't mistaken for the S3
+// composite.
+func renderOCIDigest(w io.Writer, mf []*ManifestFile) error {
+	for _, v := range mf {
+		digest := fullObjectDigest(v)
+		if len(digest) == 0 {
+			return fmt.Errorf("%s: OCI digest output needs the full object checksum", v.Filename)
+		}
+		if len(mf) > 1 {
+			if _, err := fmt.Fprintf(w, "File: %s\n", v.Filename); err != nil {
+				return err
+			}
+		}
+		algorithm := strings.ToLower(v.Algorithm)
+		if algorithm == "" {
+			algorithm = DefaultAlgorithm
+		}
+		if algorithm != "sha256" && algorithm != "sha512" {
+			return fmt.Errorf("%s: OCI digests are sha256 or sha512, not %s", v.Filename, algorithm)
+		}
+		if _, err := fmt.Fprintf(w, "OCI digest:\t%s:%x\n", algorithm, []byte(digest)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
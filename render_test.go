@@ -0,0 +1,229 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestRenderers(t *testing.T) {
+	tests := []struct {
+		format string
+		opts   RenderOptions
+		want   string
+		// wantParts is what goes to RenderOptions.PartsWriter
+		wantParts string
+	}{
+		{
+			format: "text",
+			opts:   RenderOptions{Encoding: EncodingHex},
+			want:   "Part: 00001\t\t0a0b\nPart: 00002\t\tf00f\nAmazon S3 SHA256:\t0001feff-2\nAmazon S3 Etag:\tdeadbeef-2\n",
+		},
+		{
+			format:    "text",
+			opts:      RenderOptions{Encoding: EncodingHex, PartsWriter: &bytes.Buffer{}},
+			want:      "Amazon S3 SHA256:\t0001feff-2\nAmazon S3 Etag:\tdeadbeef-2\n",
+			wantParts: "Part: 00001\t\t0a0b\nPart: 00002\t\tf00f\n",
+		},
+		{
+			format: "text",
+			opts:   RenderOptions{Encoding: EncodingBase64, PartsWriter: io.Discard},
+			want:   "Amazon S3 SHA256:\tAAH+/w==-2\nAmazon S3 Etag:\tdeadbeef-2\n",
+		},
+		{
+			format: "summary",
+			opts:   RenderOptions{Encoding: EncodingHex, PartsWriter: &bytes.Buffer{}},
+			want:   "Amazon S3 SHA256:\t0001feff-2\nAmazon S3 Etag:\tdeadbeef-2\n",
+		},
+		{
+			format: "csv",
+			opts:   RenderOptions{Encoding: EncodingHex},
+			want:   "data.bin,5242880,sha256,0001feff-2,deadbeef-2\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			r, err := NewRendererWithOptions(tt.format, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := &bytes.Buffer{}
+			if err := r.Render(out, []*ManifestFile{testManifest()}); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("output\n%q\nwant\n%q", out, tt.want)
+			}
+			if parts, ok := tt.opts.PartsWriter.(*bytes.Buffer); ok && parts.String() != tt.wantParts {
+				t.Errorf("parts\n%q\nwant\n%q", parts, tt.wantParts)
+			}
+		})
+	}
+}
+
+func TestNewRendererUnknownFormat(t *testing.T) {
+	if _, err := NewRenderer("yaml"); err == nil {
+		t.Error("no error for an unregistered format")
+	}
+}
+
+// TestRegisterRendererConcurrent registers renderers while others are
+// looked up, which the race detector flags without the registry's lock.
+func TestRegisterRendererConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		format := fmt.Sprintf("test-%d", i)
+		go func() {
+			defer wg.Done()
+			RegisterRenderer(format, RendererFunc(renderJSON))
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := NewRenderer("json"); err != nil {
+				t.Error(err)
+			}
+			Formats()
+		}()
+	}
+	wg.Wait()
+
+	renderersMu.Lock()
+	for i := 0; i < 8; i++ {
+		delete(renderers, fmt.Sprintf("test-%d", i))
+	}
+	renderersMu.Unlock()
+}
+
+func TestRenderJSONBothEncodings(t *testing.T) {
+	mf := testManifest()
+	mf.ChecksumType = ChecksumTypeFullObject
+	mf.FullObjectChecksum = ByteSlice{0xab, 0xcd}
+
+	out := &bytes.Buffer{}
+	if err := renderJSONBothEncodings(out, []*ManifestFile{mf}); err != nil {
+		t.Fatal(err)
+	}
+	var got []struct {
+		Checksum           EncodedChecksum  `json:"checksum"`
+		ChecksumType       string           `json:"checksum_type"`
+		FullObjectChecksum *EncodedChecksum `json:"full_object_checksum"`
+		PartList           []struct {
+			MD5Checksum EncodedChecksum `json:"md5_checksum"`
+		} `json:"part_list"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("%d manifests, want 1", len(got))
+	}
+	if got[0].Checksum != (EncodedChecksum{Hex: "0001feff", Base64: "AAH+/w=="}) {
+		t.Errorf("checksum %+v", got[0].Checksum)
+	}
+	if got[0].ChecksumType != ChecksumTypeFullObject {
+		t.Errorf("checksum_type %q, want %q", got[0].ChecksumType, ChecksumTypeFullObject)
+	}
+	if got[0].FullObjectChecksum == nil || *got[0].FullObjectChecksum != (EncodedChecksum{Hex: "abcd", Base64: "q80="}) {
+		t.Errorf("full_object_checksum %+v, want abcd", got[0].FullObjectChecksum)
+	}
+	if len(got[0].PartList) != 2 || got[0].PartList[1].MD5Checksum.Hex != "00" {
+		t.Errorf("part list %+v", got[0].PartList)
+	}
+}
+
+func TestRenderInToto(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		want      string
+		wantErr   bool
+	}{
+		{"sha256", "sha256", `[{"name":"data.bin","digest":{"sha256":"abcd"}}]`, false},
+		{"default algorithm", "", `[{"name":"data.bin","digest":{"sha256":"abcd"}}]`, false},
+		{"renamed digest", "SHA3-256", `[{"name":"data.bin","digest":{"sha3_256":"abcd"}}]`, false},
+		{"in-toto spelling", "sha512_256", `[{"name":"data.bin","digest":{"sha512_256":"abcd"}}]`, false},
+		{"crc", "crc32c", "", true},
+		{"no in-toto name", "blake3", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mf := testManifest()
+			mf.Algorithm = tt.algorithm
+			mf.FullObjectChecksum = ByteSlice{0xab, 0xcd}
+
+			out := &bytes.Buffer{}
+			err := renderInToto(out, []*ManifestFile{mf})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error: %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var got, want any
+			if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+				t.Fatalf("%v in %s", err, out)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("statement\n%s\nwant\n%s", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderInTotoNeedsFullObject(t *testing.T) {
+	if err := renderInToto(io.Discard, []*ManifestFile{testManifest()}); err == nil {
+		t.Error("no error for a manifest without a full object checksum")
+	}
+}
+
+func TestRenderOCIDigest(t *testing.T) {
+	second := testManifest()
+	second.Filename = "other.bin"
+	second.Algorithm = "SHA512"
+	second.FullObjectChecksum = ByteSlice{0x01, 0x02}
+
+	tests := []struct {
+		name      string
+		algorithm string
+		files     int
+		want      string
+		wantErr   bool
+	}{
+		{"sha256", "sha256", 1, "OCI digest:\tsha256:abcd\n", false},
+		{"default algorithm", "", 1, "OCI digest:\tsha256:abcd\n", false},
+		{"several files", "sha256", 2, "File: data.bin\nOCI digest:\tsha256:abcd\nFile: other.bin\nOCI digest:\tsha512:0102\n", false},
+		{"sha1", "sha1", 1, "", true},
+		{"crc", "crc64nvme", 1, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mf := testManifest()
+			mf.Algorithm = tt.algorithm
+			mf.FullObjectChecksum = ByteSlice{0xab, 0xcd}
+			if mf.isFullObject() {
+				mf.Checksum = mf.FullObjectChecksum
+			}
+			files := []*ManifestFile{mf, second}[:tt.files]
+
+			out := &bytes.Buffer{}
+			err := renderOCIDigest(out, files)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error: %v", err, tt.wantErr)
+			}
+			if err == nil && out.String() != tt.want {
+				t.Errorf("output\n%q\nwant\n%q", out, tt.want)
+			}
+		})
+	}
+}
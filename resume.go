@@ -0,0 +1,101 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// checkResume validates o.ResumeFrom against the part size, algorithm and
+// file size of this run, so parts computed with other settings are never
+// mixed into the composite.
+func checkResume(o *MultipartFileOpts) error {
+	prev := o.ResumeFrom
+	if prev == nil {
+		return nil
+	}
+	if o.LastPartOnly || o.Sequential || o.fullObject() {
+		return fmt.Errorf("a run can't be resumed with LastPartOnly, Sequential or a full object checksum")
+	}
+	algorithm := prev.Algorithm
+	if algorithm == "" {
+		algorithm = DefaultAlgorithm
+	}
+	if !strings.EqualFold(algorithm, o.Algorithm) {
+		return fmt.Errorf("the manifest to resume from uses %s, this run uses %s", algorithm, o.Algorithm)
+	}
+	if int64(prev.PartSize) != o.PartSize {
+		return fmt.Errorf("the manifest to resume from has %d byte parts, this run uses %d", prev.PartSize, o.PartSize)
+	}
+	if prev.Size != 0 && prev.Size != o.FileSize {
+		return fmt.Errorf("the manifest to resume from is of a %d byte file, %s is %d bytes", prev.Size, o.FilePath, o.FileSize)
+	}
+
+	for _, p := range prev.PartList {
+		if p.PartNumber < 1 || int(p.PartNumber) > o.NumberOfParts {
+			return fmt.Errorf("the manifest to resume from has part %d, the file has %d parts", p.PartNumber, o.NumberOfParts)
+		}
+		if want := partLength(o.FileSize, o.PartSize, p.PartNumber); p.Size != want {
+			return fmt.Errorf("part %d of the manifest to resume from is %d bytes, expected %d", p.PartNumber, p.Size, want)
+		}
+	}
+	return nil
+}
+
+// partLength is the size of part partNum, counting from 1, of a file of
+// fileSize bytes split into partSize parts.
+func partLength(fileSize, partSize int64, partNum int32) int64 {
+	start := int64(partNum-1) * partSize
+	if fileSize-start < partSize {
+		return fileSize - start
+	}
+	return partSize
+}
+
+// resumedParts returns the parts of ResumeFrom that can be reused, by part
+// number. Parts without the checksums this run needs are computed again.
+func (m *MultipartFile) resumedParts() map[int32]*PartInfo {
+	done := map[int32]*PartInfo{}
+	if m.ResumeFrom == nil {
+		return done
+	}
+	for _, p := range m.ResumeFrom.PartList {
+		if len(p.Checksum) == 0 || (len(p.MD5Checksum) == 0 && !m.SkipETag) {
+			continue
+		}
+		if m.IncludeRollingChecksum && p.RollingChecksum == 0 {
+			continue
+		}
+		part := *p
+		part.Algorithm = m.Algorithm
+		done[p.PartNumber] = &part
+	}
+	return done
+}
+
+// writeIncompleteManifest writes the parts finished before a run failed,
+// so the run can be resumed from them. Only JSON manifests hold the part
+// checksums, so nothing is written for CSV ones.
+func (m *MultipartFile) writeIncompleteManifest(partInfoList []*PartInfo) {
+	if m.ManifestFilePath == "" || len(partInfoList) == 0 || !strings.EqualFold(filepath.Ext(m.ManifestFilePath), ".json") {
+		return
+	}
+	manifest := m.buildManifest(partInfoList, nil)
+	manifest.Checksum = nil
+	manifest.Etag = nil
+	manifest.PartList = partInfoList
+	manifest.Size = m.FileSize
+	manifest.Incomplete = true
+	write := WriteManifest
+	if m.AppendManifest {
+		write = AppendToManifest
+	}
+	if err := write(m.ManifestFilePath, []*ManifestFile{manifest}); err != nil {
+		logger().Error("error writing incomplete manifest file", "path", m.ManifestFilePath, "error", err)
+		return
+	}
+	logger().Info("wrote the parts finished, resume from the manifest to skip them", "path", m.ManifestFilePath, "parts", len(partInfoList), "total_parts", m.NumberOfParts)
+}
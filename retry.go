@@ -0,0 +1,55 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// maxRetryDelay caps the delay between retries however many attempts were
+// made.
+const maxRetryDelay = 20 * time.Second
+
+// exponentialBackoff waits a random time up to base * 2^(attempt-1), capped
+// at maxRetryDelay.
+type exponentialBackoff struct {
+	base time.Duration
+}
+
+func (b exponentialBackoff) BackoffDelay(attempt int, err error) (time.Duration, error) {
+	return b.delay(attempt), nil
+}
+
+func (b exponentialBackoff) delay(attempt int) time.Duration {
+	d := maxRetryDelay
+	if attempt < 32 {
+		if exp := b.base << (attempt - 1); exp > 0 && exp < d {
+			d = exp
+		}
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// retryLoadOptions configures the SDK retryer of a client: maxRetries
+// retries of each request after the first attempt, waiting with an
+// exponential backoff from baseDelay. Zero values keep the SDK defaults.
+func retryLoadOptions(maxRetries int, baseDelay time.Duration) []func(*config.LoadOptions) error {
+	var optFns []func(*config.LoadOptions) error
+	if baseDelay > 0 {
+		optFns = append(optFns, config.WithRetryer(func() aws.Retryer {
+			return retry.NewStandard(func(o *retry.StandardOptions) {
+				o.Backoff = exponentialBackoff{base: baseDelay}
+			})
+		}))
+	}
+	if maxRetries > 0 {
+		optFns = append(optFns, config.WithRetryMaxAttempts(maxRetries+1))
+	}
+	return optFns
+}
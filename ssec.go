@@ -0,0 +1,59 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"os"
+)
+
+// sseCustomerKeySize is the size of the AES-256 keys SSE-C takes.
+const sseCustomerKeySize = 32
+
+// sseCustomerKey holds the SSE-C request headers for a customer provided
+// key: the algorithm, the base64 key and the base64 MD5 of the key.
+type sseCustomerKey struct {
+	Algorithm string
+	Key       string
+	KeyMD5    string
+}
+
+// newSSECustomerKey checks a base64 encoded AES-256 key and computes the
+// key MD5 S3 requires with it.
+func newSSECustomerKey(encoded string) (*sseCustomerKey, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("the SSE-C key isn't valid base64: %w", err)
+	}
+	if len(key) != sseCustomerKeySize {
+		return nil, fmt.Errorf("the SSE-C key must be %d bytes for AES-256, got %d", sseCustomerKeySize, len(key))
+	}
+	sum := md5.Sum(key)
+	return &sseCustomerKey{
+		Algorithm: "AES256",
+		Key:       encoded,
+		KeyMD5:    base64.StdEncoding.EncodeToString(sum[:]),
+	}, nil
+}
+
+// ReadSSECustomerKey reads an SSE-C key from a file holding either the 32
+// raw key bytes or their base64 encoding, and returns it base64 encoded
+// for UploadOptions.SSECustomerKey and DownloadOptions.SSECustomerKey.
+func ReadSSECustomerKey(path string) (string, error) {
+	b, err := os.ReadFile(LongPath(path))
+	if err != nil {
+		return "", err
+	}
+	if len(b) == sseCustomerKeySize {
+		return base64.StdEncoding.EncodeToString(b), nil
+	}
+	encoded := string(bytes.TrimSpace(b))
+	if _, err := newSSECustomerKey(encoded); err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return encoded, nil
+}
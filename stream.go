@@ -0,0 +1,117 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ChecksumStream hashes data read front to back from r, such as stdin,
+// whose size isn't known until EOF. Each PartSize bytes are buffered and
+// hashed as a part, so the result is the same as CalculateChecksum over the
+// same bytes on disk. TargetParts, FitPartLimit and LastPartOnly need the
+// size up front and aren't supported. FilePath is only the name recorded in the manifest.
+func ChecksumStream(ctx context.Context, r io.Reader, opts MultipartFileOpts) (*ManifestFile, error) {
+	if opts.TargetParts != 0 || opts.FitPartLimit || opts.LastPartOnly {
+		return nil, fmt.Errorf("the size of a stream isn't known up front, set PartSize instead of TargetParts, FitPartLimit or LastPartOnly")
+	}
+	if opts.ResumeFrom != nil {
+		return nil, fmt.Errorf("a stream can't be read again, so it can't be resumed")
+	}
+	if opts.PartAlignment < 0 {
+		return nil, fmt.Errorf("part alignment must be a positive value")
+	}
+	if opts.PartAlignment > 0 {
+		opts.PartSize = (opts.PartSize + opts.PartAlignment - 1) / opts.PartAlignment * opts.PartAlignment
+	}
+	if err := checkPartSize(opts.PartSize); err != nil {
+		return nil, err
+	}
+	if err := resolveHashFun(&opts); err != nil {
+		return nil, err
+	}
+	opts.FileSize = 0
+	opts.NumberOfParts = 0
+
+	m := newMultipartFile(opts)
+	start := time.Now()
+	manifest, err := m.checksumReader(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	manifest.Elapsed = time.Since(start)
+	return manifest, m.writeManifest(manifest)
+}
+
+// checksumReader checksums r front to back, along with the full object
+// checksum when it is the object checksum or IncludeFullObject asks for it.
+func (m *MultipartFile) checksumReader(ctx context.Context, r io.Reader) (*ManifestFile, error) {
+	if m.IncludeFullObject || m.fullObject() {
+		return m.calculateChecksumFromReaderFullObject(ctx, r)
+	}
+	return m.calculateChecksumFromReader(ctx, r)
+}
+
+// calculateChecksumFromReader reads r front to back, checksumming every
+// PartSize bytes as a part, until EOF. The result matches CalculateChecksum
+// over the same bytes on disk.
+func (m *MultipartFile) calculateChecksumFromReader(ctx context.Context, r io.Reader) (*ManifestFile, error) {
+	buffer := m.bufferPool.Get()
+	defer m.bufferPool.Put(buffer)
+	data := buffer.([]byte)
+
+	// Parts arrive in order and are combined as they are read
+	acc := NewCompositeAccumulator(m.HashFun, 0)
+	partInfoList := []*PartInfo{}
+	for partNum := int32(0); ; partNum++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		partStart := time.Now()
+		n, err := io.ReadFull(r, data)
+		if n > 0 && partNum == MAX_PARTS {
+			return nil, fmt.Errorf("the data needs more than the %d parts S3 allows with %d byte parts, use a larger part size", MAX_PARTS, m.PartSize)
+		}
+		if n > 0 {
+			part := m.finishPart(m.checksumPartData(partNum, data[:n]), partStart)
+			if err := acc.Add(part); err != nil {
+				return nil, err
+			}
+			partInfoList = append(partInfoList, part)
+			if m.Progress != nil && m.NumberOfParts > 0 {
+				m.Progress(len(partInfoList), m.NumberOfParts)
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m.buildManifest(partInfoList, acc), nil
+}
+
+// calculateChecksumFromReaderFullObject is calculateChecksumFromReader that
+// also hashes the whole stream in the same pass. For full object
+// checksums that hash is the object checksum, otherwise it is stored as
+// FullObjectChecksum next to the composite.
+func (m *MultipartFile) calculateChecksumFromReaderFullObject(ctx context.Context, r io.Reader) (*ManifestFile, error) {
+	h := m.HashFun()
+	manifest, err := m.calculateChecksumFromReader(ctx, io.TeeReader(r, h))
+	if err != nil {
+		return nil, err
+	}
+	if m.fullObject() {
+		manifest.Checksum = h.Sum(nil)
+	} else {
+		manifest.FullObjectChecksum = h.Sum(nil)
+	}
+	return manifest, nil
+}
@@ -0,0 +1,79 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"testing/iotest"
+)
+
+// TestChecksumStreamMatchesCalculateChecksum checksums the same bytes as a
+// stream and as a file. The stream is read in short reads, so parts are
+// filled across several of them.
+func TestChecksumStreamMatchesCalculateChecksum(t *testing.T) {
+	sizes := []struct {
+		name string
+		size int
+	}{
+		{"smaller than a part", 100},
+		{"whole parts", 2 * MIN_PART_SIZE},
+		{"short final part", 2*MIN_PART_SIZE + 100},
+	}
+	algorithms := []struct {
+		algorithm         string
+		includeFullObject bool
+	}{
+		{"sha256", false},
+		{"sha256", true},
+		{"crc64nvme", false},
+	}
+	for _, sz := range sizes {
+		path, data := writeTestFile(t, sz.size)
+		for _, a := range algorithms {
+			t.Run(fmt.Sprintf("%s/%s/full=%v", sz.name, a.algorithm, a.includeFullObject), func(t *testing.T) {
+				opts := MultipartFileOpts{
+					FilePath:          path,
+					PartSize:          MIN_PART_SIZE,
+					Algorithm:         a.algorithm,
+					IncludeFullObject: a.includeFullObject,
+				}
+				m, err := NewMultipartFile(opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want, err := m.CalculateChecksum(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := ChecksumStream(context.Background(), iotest.HalfReader(bytes.NewReader(data)), opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(got.Checksum, want.Checksum) || !bytes.Equal(got.Etag, want.Etag) {
+					t.Errorf("checksum %s etag %x, want %s and %x", got.Checksum, got.Etag, want.Checksum, want.Etag)
+				}
+				if !bytes.Equal(got.FullObjectChecksum, want.FullObjectChecksum) {
+					t.Errorf("full object checksum %s, want %s", got.FullObjectChecksum, want.FullObjectChecksum)
+				}
+				if got.Size != want.Size || got.PartSize != want.PartSize {
+					t.Errorf("size %d part size %d, want %d and %d", got.Size, got.PartSize, want.Size, want.PartSize)
+				}
+				if len(got.PartList) != len(want.PartList) {
+					t.Fatalf("%d parts, want %d", len(got.PartList), len(want.PartList))
+				}
+				for i, p := range got.PartList {
+					w := want.PartList[i]
+					if p.PartNumber != w.PartNumber || p.Size != w.Size ||
+						!bytes.Equal(p.Checksum, w.Checksum) || !bytes.Equal(p.MD5Checksum, w.MD5Checksum) {
+						t.Errorf("part %d is %+v, want %+v", i+1, p, w)
+					}
+				}
+			})
+		}
+	}
+}
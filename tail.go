@@ -0,0 +1,148 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"time"
+)
+
+// errTruncated is returned by tailReader when the file shrinks below the
+// number of bytes already read, meaning the writer started over.
+var errTruncated = errors.New("file was truncated while being read")
+
+// TailOpts configures ChecksumGrowingFile.
+type TailOpts struct {
+	FilePath  string
+	PartSize  int64
+	HashFun   func() hash.Hash
+	Algorithm string
+	// ExpectedSize marks the file complete once this many bytes were read.
+	ExpectedSize int64
+	// DoneFile marks the file complete once it exists and every byte
+	// written so far was read.
+	DoneFile string
+	// PollInterval is how long to wait for more data at the end of the
+	// file. Defaults to one second.
+	PollInterval time.Duration
+	// IncludeFullObject also computes ManifestFile.FullObjectChecksum from
+	// the same pass over the file.
+	IncludeFullObject bool
+}
+
+// ChecksumGrowingFile hashes a file while another process is still writing
+// it, like tail -f, and returns once the file is complete according to
+// ExpectedSize or DoneFile. When the writer truncates the file the
+// computation starts over from the beginning.
+func ChecksumGrowingFile(ctx context.Context, opts TailOpts) (*ManifestFile, error) {
+	if opts.FilePath == "" {
+		return nil, fmt.Errorf("FilePath is a required parameter")
+	}
+	if opts.ExpectedSize <= 0 && opts.DoneFile == "" {
+		return nil, fmt.Errorf("either ExpectedSize or DoneFile is required to know when the file is complete")
+	}
+	if err := checkPartSize(opts.PartSize); err != nil {
+		return nil, err
+	}
+	if opts.HashFun == nil {
+		if opts.Algorithm == "" {
+			opts.Algorithm = DefaultAlgorithm
+		}
+		a, err := LookupAlgorithm(opts.Algorithm)
+		if err != nil {
+			return nil, err
+		}
+		opts.HashFun = a.HashFun
+		opts.Algorithm = a.Name
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+
+	m := newMultipartFile(MultipartFileOpts{
+		FilePath:  opts.FilePath,
+		PartSize:  opts.PartSize,
+		HashFun:   opts.HashFun,
+		Algorithm: opts.Algorithm,
+	})
+
+	for {
+		manifest, err := m.checksumGrowingFile(ctx, opts)
+		if err == errTruncated {
+			logger().Warn("file was truncated, restarting checksum", "file", opts.FilePath)
+			continue
+		}
+		return manifest, err
+	}
+}
+
+func (m *MultipartFile) checksumGrowingFile(ctx context.Context, opts TailOpts) (*ManifestFile, error) {
+	f, err := os.Open(LongPath(opts.FilePath))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := &tailReader{ctx: ctx, f: f, opts: opts}
+	if opts.IncludeFullObject || m.fullObject() {
+		return m.calculateChecksumFromReaderFullObject(ctx, r)
+	}
+	return m.calculateChecksumFromReader(ctx, r)
+}
+
+// tailReader reads a file that is still being written, waiting at the end
+// of the file until more data arrives or the file is complete.
+type tailReader struct {
+	ctx    context.Context
+	f      *os.File
+	offset int64
+	opts   TailOpts
+}
+
+func (t *tailReader) Read(p []byte) (int, error) {
+	if t.opts.ExpectedSize > 0 && int64(len(p)) > t.opts.ExpectedSize-t.offset {
+		p = p[:t.opts.ExpectedSize-t.offset]
+	}
+	for {
+		if len(p) == 0 {
+			return 0, io.EOF
+		}
+
+		n, err := t.f.Read(p)
+		t.offset += int64(n)
+		if n > 0 {
+			return n, nil
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		fileInfo, err := t.f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		if fileInfo.Size() < t.offset {
+			return 0, errTruncated
+		}
+		if fileInfo.Size() > t.offset {
+			continue
+		}
+		if t.opts.DoneFile != "" {
+			if _, err := os.Stat(LongPath(t.opts.DoneFile)); err == nil {
+				return 0, io.EOF
+			}
+		}
+
+		select {
+		case <-t.ctx.Done():
+			return 0, t.ctx.Err()
+		case <-time.After(t.opts.PollInterval):
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// growFile runs before, then appends data to path in chunks, pausing
+// between them like a slow writer, and creates doneFile once it is done.
+func growFile(path, doneFile string, data []byte, before func(path string) error) error {
+	if before != nil {
+		if err := before(path); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	const chunks = 8
+	size := (len(data) + chunks - 1) / chunks
+	for len(data) > 0 {
+		n := min(size, len(data))
+		if _, err := f.Write(data[:n]); err != nil {
+			return err
+		}
+		data = data[n:]
+		time.Sleep(10 * time.Millisecond)
+	}
+	return os.WriteFile(doneFile, nil, 0o644)
+}
+
+func TestChecksumGrowingFile(t *testing.T) {
+	final, data := writeTestFile(t, MIN_PART_SIZE+1000)
+	m, err := NewMultipartFile(MultipartFileOpts{FilePath: final, PartSize: MIN_PART_SIZE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := m.CalculateChecksum(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		// done marks the file complete, given the TailOpts to set and the
+		// paths of the file and its done file
+		done func(o *TailOpts, doneFile string)
+		// before runs ahead of the writer, given the growing file
+		before func(path string) error
+	}{
+		{
+			name: "expected size",
+			done: func(o *TailOpts, doneFile string) { o.ExpectedSize = int64(len(data)) },
+		},
+		{
+			name: "done file",
+			done: func(o *TailOpts, doneFile string) { o.DoneFile = doneFile },
+		},
+		{
+			// The writer starts over, the checksum must restart with it
+			name: "truncated",
+			done: func(o *TailOpts, doneFile string) { o.DoneFile = doneFile },
+			before: func(path string) error {
+				if err := os.WriteFile(path, bytes.Repeat([]byte("stale"), 200), 0o644); err != nil {
+					return err
+				}
+				time.Sleep(50 * time.Millisecond)
+				if err := os.Truncate(path, 0); err != nil {
+					return err
+				}
+				time.Sleep(50 * time.Millisecond)
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "growing")
+			doneFile := filepath.Join(dir, "growing.done")
+			if err := os.WriteFile(path, nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			opts := TailOpts{FilePath: path, PartSize: MIN_PART_SIZE, PollInterval: 5 * time.Millisecond}
+			tt.done(&opts, doneFile)
+
+			errs := make(chan error, 1)
+			go func() {
+				errs <- growFile(path, doneFile, data, tt.before)
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			got, err := ChecksumGrowingFile(ctx, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := <-errs; err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Checksum, want.Checksum) || !bytes.Equal(got.Etag, want.Etag) || len(got.PartList) != len(want.PartList) {
+				t.Errorf("checksum %s with %d parts, want %s with %d like CalculateChecksum", got.Checksum, len(got.PartList), want.Checksum, len(want.PartList))
+			}
+		})
+	}
+}
+
+func TestChecksumGrowingFileNeedsCompletion(t *testing.T) {
+	path, _ := writeTestFile(t, 100)
+	if _, err := ChecksumGrowingFile(context.Background(), TailOpts{FilePath: path, PartSize: MIN_PART_SIZE}); err == nil {
+		t.Error("no error without ExpectedSize or DoneFile")
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+)
+
+// ChecksumTarMember checksums a single member of a tar archive, optionally
+// gzipped, without extracting it. The archive is streamed up to the member
+// and only the member's bytes are hashed, with the algorithm, part size and
+// checksum options of opts, as CalculateChecksum would hash the extracted
+// file. FilePath and FileSize are taken from the member. The member is read
+// once, front to back, so LastPartOnly and ResumeFrom aren't supported.
+func ChecksumTarMember(ctx context.Context, archivePath, memberName string, opts MultipartFileOpts) (*ManifestFile, error) {
+	if opts.LastPartOnly || opts.ResumeFrom != nil {
+		return nil, fmt.Errorf("a tar member is read front to back, LastPartOnly and ResumeFrom aren't supported")
+	}
+	if err := resolveHashFun(&opts); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(LongPath(archivePath))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = bufio.NewReader(f)
+	if magic, err := r.(*bufio.Reader).Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s has no member %s", archivePath, memberName)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %w", archivePath, err)
+		}
+		if path.Clean(hdr.Name) != path.Clean(memberName) {
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("%s in %s is not a regular file", memberName, archivePath)
+		}
+
+		opts.FilePath = memberName
+		opts.FileSize = hdr.Size
+		if hdr.Size > 0 {
+			err = resolvePartSize(&opts)
+		} else {
+			// An empty member has no parts to size
+			err = checkPartSize(opts.PartSize)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		m := newMultipartFile(opts)
+		manifest, err := m.checksumReader(ctx, tr)
+		if err != nil {
+			return nil, err
+		}
+		return manifest, m.writeManifest(manifest)
+	}
+}
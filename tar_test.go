@@ -0,0 +1,81 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChecksumTarMemberOptions(t *testing.T) {
+	path, data := writeTestFile(t, 2*MIN_PART_SIZE+100)
+
+	archive := filepath.Join(t.TempDir(), "a.tar.gz")
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, body := range map[string][]byte{"other": []byte("other"), "dir/data": data} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(archive, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts MultipartFileOpts
+	}{
+		{"default", MultipartFileOpts{PartSize: MIN_PART_SIZE}},
+		{"crc64nvme", MultipartFileOpts{PartSize: MIN_PART_SIZE, Algorithm: "crc64nvme"}},
+		{"target parts", MultipartFileOpts{PartSize: MIN_PART_SIZE, TargetParts: 2}},
+		{"full object", MultipartFileOpts{PartSize: MIN_PART_SIZE, Algorithm: "crc32c", ChecksumType: ChecksumTypeFullObject}},
+		{"skip etag", MultipartFileOpts{PartSize: MIN_PART_SIZE, SkipETag: true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ChecksumTarMember(context.Background(), archive, "dir/data", tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts := tc.opts
+			opts.FilePath = path
+			m, err := NewMultipartFile(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := m.CalculateChecksum(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Algorithm != want.Algorithm || got.PartSize != want.PartSize || len(got.PartList) != len(want.PartList) ||
+				!bytes.Equal(got.Checksum, want.Checksum) || !bytes.Equal(got.Etag, want.Etag) || got.ChecksumType != want.ChecksumType {
+				t.Errorf("tar member %s %d %s %s %d parts, extracted file %s %d %s %s %d parts",
+					got.Algorithm, got.PartSize, got.Checksum, got.Etag, len(got.PartList),
+					want.Algorithm, want.PartSize, want.Checksum, want.Etag, len(want.PartList))
+			}
+		})
+	}
+
+	if _, err := ChecksumTarMember(context.Background(), archive, "dir/data", MultipartFileOpts{PartSize: MIN_PART_SIZE, LastPartOnly: true}); err == nil {
+		t.Error("LastPartOnly was accepted")
+	}
+	if _, err := ChecksumTarMember(context.Background(), archive, "missing", MultipartFileOpts{PartSize: MIN_PART_SIZE}); err == nil {
+		t.Error("a missing member was checksummed")
+	}
+}
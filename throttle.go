@@ -0,0 +1,80 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+)
+
+// bandwidthLimiter spaces out reads so that all the readers sharing it
+// together stay under bytesPerSecond.
+type bandwidthLimiter struct {
+	bytesPerSecond int64
+
+	mu sync.Mutex
+	// next is when the bytes handed out so far have been read at the limit
+	next time.Time
+}
+
+// newBandwidthLimiter returns a limiter for bytesPerSecond, or nil when it
+// isn't positive.
+func newBandwidthLimiter(bytesPerSecond int64) *bandwidthLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &bandwidthLimiter{bytesPerSecond: bytesPerSecond}
+}
+
+// wait blocks until n more bytes can be read without going over the limit.
+func (l *bandwidthLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		// The limit wasn't used for a while, that doesn't allow a burst
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / float64(l.bytesPerSecond) * float64(time.Second)))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// throttledFile reads a file at the rate of a shared bandwidthLimiter. It
+// keeps the io.ReaderAt and io.Seeker of the file, so the upload manager
+// still reads the parts concurrently, and the limit applies to all of them
+// together.
+type throttledFile struct {
+	ctx     context.Context
+	f       *os.File
+	limiter *bandwidthLimiter
+}
+
+func (t *throttledFile) Read(p []byte) (int, error) {
+	if err := t.limiter.wait(t.ctx, len(p)); err != nil {
+		return 0, err
+	}
+	return t.f.Read(p)
+}
+
+func (t *throttledFile) ReadAt(p []byte, off int64) (int, error) {
+	if err := t.limiter.wait(t.ctx, len(p)); err != nil {
+		return 0, err
+	}
+	return t.f.ReadAt(p, off)
+}
+
+func (t *throttledFile) Seek(offset int64, whence int) (int64, error) {
+	return t.f.Seek(offset, whence)
+}
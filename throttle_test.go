@@ -0,0 +1,77 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestThrottledFileRate reads a file through a shared limiter from several
+// goroutines, which together may not go faster than the limit.
+func TestThrottledFileRate(t *testing.T) {
+	const (
+		size        = 256 * 1024
+		chunk       = 16 * 1024
+		readers     = 4
+		rate        = 1024 * 1024
+		tolerance   = 10 * time.Millisecond
+		wantAtLeast = time.Duration(float64(size) / rate * float64(time.Second))
+	)
+	path, data := writeTestFile(t, size)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tf := &throttledFile{ctx: context.Background(), f: f, limiter: newBandwidthLimiter(rate)}
+
+	offsets := make(chan int64)
+	go func() {
+		defer close(offsets)
+		for off := int64(0); off < size; off += chunk {
+			offsets <- off
+		}
+	}()
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, chunk)
+			for off := range offsets {
+				n, err := tf.ReadAt(buf, off)
+				if err != nil || n != chunk || !bytes.Equal(buf, data[off:off+chunk]) {
+					t.Errorf("read %d bytes at %d: %v", n, off, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if elapsed := time.Since(start); elapsed < wantAtLeast-tolerance {
+		t.Errorf("read %d bytes in %v, at %d bytes per second it takes at least %v", size, elapsed, rate, wantAtLeast)
+	}
+}
+
+func TestThrottledFileCancel(t *testing.T) {
+	path, _ := writeTestFile(t, 1024)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// A second's worth of bytes would wait a second without the cancel
+	tf := &throttledFile{ctx: ctx, f: f, limiter: newBandwidthLimiter(1024)}
+	if _, err := tf.Read(make([]byte, 1024)); err != context.Canceled {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
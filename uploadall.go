@@ -0,0 +1,145 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// UploadAllOptions configures UploadAll. The embedded UploadOptions are the
+// template of every file; their Key and LocalFile are ignored.
+type UploadAllOptions struct {
+	UploadOptions
+	// Files are the local files to upload.
+	Files []string
+	// KeyPrefix is prepended to the key of every file.
+	KeyPrefix string
+	// Root, when set, derives each key from the file's path below Root,
+	// see KeyForPath. Otherwise the key is the file's base name.
+	Root string
+	// Concurrency is how many files are uploaded at a time, 4 by default.
+	// Each file uses up to NumRoutines connections, so at most
+	// Concurrency * NumRoutines requests are in flight.
+	Concurrency int
+}
+
+// UploadAll uploads every file in opts.Files, Concurrency files at a time,
+// each with the multipart upload manager. The keys are checked for
+// collisions before anything is uploaded. It returns a manifest entry for
+// every uploaded object, in the order of opts.Files, and writes them to
+// ManifestFile when it is set. A failed file doesn't stop the others; all
+// failures are returned together.
+func UploadAll(ctx context.Context, opts *UploadAllOptions) ([]*ManifestFile, error) {
+	if err := validateUploadOptions(&opts.UploadOptions); err != nil {
+		return nil, err
+	}
+	keys, err := uploadKeys(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := uploadClient(ctx, &opts.UploadOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	bandwidth := newBandwidthLimiter(opts.MaxBandwidth)
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	limiter := make(chan struct{}, concurrency)
+	results := make([]*ManifestFile, len(opts.Files))
+	errs := make([]error, len(opts.Files))
+
+	wg := sync.WaitGroup{}
+	for i, file := range opts.Files {
+		limiter <- struct{}{}
+		wg.Add(1)
+		go func(i int, file string) {
+			defer wg.Done()
+			defer func() { <-limiter }()
+
+			o := opts.UploadOptions
+			o.LocalFile = file
+			o.Key = keys[i]
+			logger().Info("uploading", "file", file, "bucket", o.Bucket, "key", o.Key)
+			m, err := uploadFile(ctx, client, &o, bandwidth)
+			if err == nil && o.VerifyETag {
+				_, err = verifyUploadEtag(ctx, client, &o)
+			}
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", file, err)
+				return
+			}
+			results[i] = m
+		}(i, file)
+	}
+	wg.Wait()
+
+	uploaded := []*ManifestFile{}
+	for _, m := range results {
+		if m != nil {
+			uploaded = append(uploaded, m)
+		}
+	}
+	if opts.ManifestFile != "" && len(uploaded) > 0 {
+		if err := WriteManifestFile(opts.ManifestFile, uploaded); err != nil {
+			logger().Error("failed writing manifest", "path", opts.ManifestFile, "error", err)
+		}
+	}
+	return uploaded, errors.Join(errs...)
+}
+
+// uploadKeys derives the key of every file and fails if two files would be
+// uploaded to the same key.
+func uploadKeys(opts *UploadAllOptions) ([]string, error) {
+	if opts.Root != "" {
+		collisions, err := FindKeyCollisions(opts.KeyPrefix, opts.Root, opts.Files, false)
+		if err != nil {
+			return nil, err
+		}
+		if len(collisions) > 0 {
+			return nil, &KeyCollisionError{Collisions: collisions}
+		}
+	}
+
+	keys := make([]string, len(opts.Files))
+	byKey := map[string][]string{}
+	for i, f := range opts.Files {
+		if opts.Root != "" {
+			key, err := KeyForPath(opts.KeyPrefix, opts.Root, f)
+			if err != nil {
+				return nil, err
+			}
+			keys[i] = key
+			continue
+		}
+		key := filepath.Base(f)
+		if opts.KeyPrefix != "" {
+			key = path.Join(strings.TrimSuffix(opts.KeyPrefix, "/"), key)
+		}
+		keys[i] = key
+		byKey[key] = append(byKey[key], f)
+	}
+
+	collisions := []KeyCollision{}
+	for key, paths := range byKey {
+		if len(paths) > 1 {
+			collisions = append(collisions, KeyCollision{Key: key, Paths: paths})
+		}
+	}
+	if len(collisions) > 0 {
+		sort.Slice(collisions, func(i, j int) bool { return collisions[i].Key < collisions[j].Key })
+		return nil, &KeyCollisionError{Collisions: collisions}
+	}
+	return keys, nil
+}
@@ -6,16 +6,32 @@ package s3checksum
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"net/url"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+// UploadAPIClient is the S3 client methods Upload and UploadAll use: the
+// upload manager's, and HeadObject for VerifyETag. *s3.Client implements
+// it.
+type UploadAPIClient interface {
+	manager.UploadAPIClient
+	s3.HeadObjectAPIClient
+}
+
 type UploadOptions struct {
 	Bucket       string
 	Key          string
@@ -23,95 +39,465 @@ type UploadOptions struct {
 	ManifestFile string
 	NumRoutines  int
 	PartSize     int64
-	Region       string
-	AWSProfile   string
-	UsePathStyle bool
+	ClientOptions
+	// Client, when set, is used instead of a client built from
+	// ClientOptions, e.g. to record the requests of an upload in a test.
+	// ClientOptions and the SDK retry settings of MaxRetries and
+	// RetryBaseDelay are then ignored; the whole upload is still retried.
+	Client UploadAPIClient
+	// WriteChecksumMetadata stores the part size and checksum algorithm as
+	// user metadata so the object can be verified without knowing them.
+	WriteChecksumMetadata bool
+	// LeavePartsOnError keeps the uploaded parts of a failed multipart
+	// upload instead of aborting it, so a later run can resume from them.
+	// Parts left behind are billed as storage until the upload is
+	// completed or aborted.
+	LeavePartsOnError bool
+	// MaxRetries is how many times a failed request, such as a part upload,
+	// is retried by the SDK, and how many times the whole upload is retried
+	// when it still fails. Zero keeps the SDK default and doesn't retry the
+	// upload.
+	MaxRetries int
+	// RetryBaseDelay is the base of the exponential backoff between
+	// retries. Zero keeps the SDK default backoff for requests and waits
+	// one second between upload attempts.
+	RetryBaseDelay time.Duration
+	// ServerSideEncryption is the x-amz-server-side-encryption algorithm,
+	// e.g. AES256 or aws:kms. It defaults to aws:kms when SSEKMSKeyID is
+	// set and is otherwise left to the bucket default.
+	ServerSideEncryption string
+	// SSEKMSKeyID is the KMS key the object is encrypted with.
+	SSEKMSKeyID string
+	// SSECustomerKey is the base64 encoded AES-256 key of SSE-C, see
+	// ReadSSECustomerKey. The key MD5 S3 requires is computed from it.
+	SSECustomerKey string
+	// StorageClass is the storage class the object is written to, e.g.
+	// GLACIER_IR or INTELLIGENT_TIERING. Empty means STANDARD.
+	StorageClass string
+	// ContentType is the object's Content-Type. When empty it is detected
+	// from each file, see DetectContentType.
+	ContentType string
+	// Metadata is stored as x-amz-meta-* user metadata, next to the
+	// WriteChecksumMetadata keys.
+	Metadata map[string]string
+	// Tags are set as S3 object tags.
+	Tags map[string]string
+	// VerifyETag re-reads the local file after the upload and compares its
+	// MD5 based ETag with the one S3 stored, see VerifyObjectEtag. It is
+	// skipped for SSE-KMS and SSE-C objects, whose ETag isn't an MD5.
+	VerifyETag bool
+	// Timeout, when set, limits how long the upload of a file may take.
+	// A multipart upload that times out is aborted, unless
+	// LeavePartsOnError is set, so no parts are left behind.
+	Timeout time.Duration
+	// Quiet leaves out the per-part checksum lines, which are otherwise
+	// printed to stderr.
+	Quiet bool
+	// ChecksumType ChecksumTypeFullObject uploads the file with a single
+	// PutObject, whatever PartSize is, so the stored SHA256 is computed
+	// over the whole object and has no part count. S3 only takes full
+	// object checksums of multipart uploads for CRC algorithms, so files
+	// over MaxSinglePutSize can't be uploaded this way.
+	ChecksumType string
+	// MaxBandwidth, when set, caps the rate the file is read at for the
+	// upload, in bytes per second, across all its concurrent parts.
+	// UploadAll shares the cap between the files it uploads at a time.
+	// Without TLS the SDK reads each part a second time to sign it, which
+	// counts against the cap as well.
+	MaxBandwidth int64
+}
+
+// MaxSinglePutSize is the largest object a single PutObject can upload.
+const MaxSinglePutSize = 5 * 1024 * 1024 * 1024
+
+// tagPattern matches the characters S3 accepts in tag keys and values:
+// letters, numbers, spaces and + - = . _ : / @
+var tagPattern = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}+\-=._:/@]*$`)
+
+// validateTags checks tags against the S3 limits: at most 10 tags, keys of
+// 1 to 128 characters and values of up to 256, using only the characters
+// S3 accepts.
+func validateTags(tags map[string]string) error {
+	if len(tags) > 10 {
+		return fmt.Errorf("an object can have at most 10 tags, got %d", len(tags))
+	}
+	for k, v := range tags {
+		if k == "" || utf8.RuneCountInString(k) > 128 {
+			return fmt.Errorf("tag key %q must be 1 to 128 characters", k)
+		}
+		if utf8.RuneCountInString(v) > 256 {
+			return fmt.Errorf("tag %s value must be at most 256 characters", k)
+		}
+		if strings.HasPrefix(strings.ToLower(k), "aws:") {
+			return fmt.Errorf("tag key %q uses the reserved aws: prefix", k)
+		}
+		if !tagPattern.MatchString(k) || !tagPattern.MatchString(v) {
+			return fmt.Errorf("tag %s=%s contains characters S3 doesn't allow, use letters, numbers, spaces and + - = . _ : / @", k, v)
+		}
+	}
+	return nil
 }
 
+// validateStorageClass returns an error naming the valid storage classes
+// when class isn't one of them.
+func validateStorageClass(class string) error {
+	valid := types.StorageClass("").Values()
+	names := make([]string, len(valid))
+	for i, v := range valid {
+		if string(v) == class {
+			return nil
+		}
+		names[i] = string(v)
+	}
+	return fmt.Errorf("unknown storage class %q, expected one of %s", class, strings.Join(names, ", "))
+}
+
+// Upload uploads opts.LocalFile with UploadWithResult and prints the part
+// checksums to stderr, unless Quiet is set, and the object's checksum and
+// ETag.
 func Upload(ctx context.Context, opts *UploadOptions) error {
-	optFns := []func(*config.LoadOptions) error{
-		config.WithRegion(opts.Region),
+	result, err := UploadWithResult(ctx, opts)
+	if result == nil {
+		return err
+	}
+
+	// The summary moves to stderr when stdout has the manifest
+	out := io.Writer(os.Stdout)
+	if opts.ManifestFile == ManifestStdout {
+		out = os.Stderr
+	}
+	// Per-part lines go to stderr
+	partsW := io.Writer(os.Stderr)
+	if opts.Quiet {
+		partsW = io.Discard
+	}
+	renderer, renderErr := NewRendererWithOptions("text", RenderOptions{PartsWriter: partsW})
+	if renderErr != nil {
+		return renderErr
+	}
+	if renderErr := renderer.Render(out, []*ManifestFile{result.Manifest}); renderErr != nil {
+		return renderErr
+	}
+	if result.EtagVerified {
+		fmt.Fprintln(out, "Amazon S3 Etag verified")
+	}
+	return err
+}
+
+// UploadResult is the outcome of UploadWithResult.
+type UploadResult struct {
+	// Manifest is the manifest of the object S3 reported.
+	Manifest *ManifestFile
+	// EtagVerified is set when VerifyETag compared the ETag S3 stored
+	// with the local file. It stays false when the object's encryption
+	// makes the ETag something other than an MD5.
+	EtagVerified bool
+}
+
+// UploadWithResult is Upload without the output: it uploads the file,
+// writes opts.ManifestFile and verifies the ETag with VerifyETag. When the
+// upload succeeded but the ETag doesn't match, the result is returned
+// along with the error.
+func UploadWithResult(ctx context.Context, opts *UploadOptions) (*UploadResult, error) {
+	if err := validateUploadOptions(opts); err != nil {
+		return nil, err
 	}
-	if opts.AWSProfile != "" {
-		optFns = append(optFns, config.WithSharedConfigProfile(opts.AWSProfile))
 
+	client, err := uploadClient(ctx, opts)
+	if err != nil {
+		return nil, err
 	}
-	cfg, err := config.LoadDefaultConfig(context.TODO(), optFns...)
+
+	logger().Info("beginning upload", "file", opts.LocalFile, "bucket", opts.Bucket, "key", opts.Key)
+	m, err := uploadFile(ctx, client, opts, newBandwidthLimiter(opts.MaxBandwidth))
 	if err != nil {
-		log.Fatal(err.Error())
+		return nil, err
 	}
 
-	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
-		o.UsePathStyle = opts.UsePathStyle
-	})
+	if opts.ManifestFile != "" {
+		mf := []*ManifestFile{m}
+		if err := WriteManifestFile(opts.ManifestFile, mf); err != nil {
+			logger().Error("failed writing manifest", "path", opts.ManifestFile, "error", err)
+		}
+	}
+
+	result := &UploadResult{Manifest: m}
+	if opts.VerifyETag {
+		result.EtagVerified, err = verifyUploadEtag(ctx, client, opts)
+	}
+	return result, err
+}
+
+// uploadClient returns opts.Client, or builds one from opts.ClientOptions
+// with the retry settings of opts.
+func uploadClient(ctx context.Context, opts *UploadOptions) (UploadAPIClient, error) {
+	if opts.Client != nil {
+		return opts.Client, nil
+	}
+	client, err := newS3Client(ctx, opts.ClientOptions, retryLoadOptions(opts.MaxRetries, opts.RetryBaseDelay)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS config: %w", err)
+	}
+	return client, nil
+}
+
+// validateUploadOptions checks the options S3 would only reject after the
+// data was sent.
+func validateUploadOptions(opts *UploadOptions) error {
+	if opts.StorageClass != "" {
+		if err := validateStorageClass(opts.StorageClass); err != nil {
+			return err
+		}
+	}
+	switch opts.ChecksumType {
+	case "", ChecksumTypeComposite, ChecksumTypeFullObject:
+	default:
+		return fmt.Errorf("unknown checksum type %q, expected %s or %s", opts.ChecksumType, ChecksumTypeComposite, ChecksumTypeFullObject)
+	}
+	if opts.SSECustomerKey != "" {
+		if opts.ServerSideEncryption != "" || opts.SSEKMSKeyID != "" {
+			return fmt.Errorf("an SSE-C key can't be combined with another server-side encryption")
+		}
+		if _, err := newSSECustomerKey(opts.SSECustomerKey); err != nil {
+			return err
+		}
+	}
+	return validateTags(opts.Tags)
+}
 
-	f, err := os.Open(opts.LocalFile)
+// uploadFile uploads opts.LocalFile to opts.Bucket and opts.Key and returns
+// the manifest of the object S3 reported. The file is read at the rate of
+// limiter, when it isn't nil.
+func uploadFile(ctx context.Context, client UploadAPIClient, opts *UploadOptions, limiter *bandwidthLimiter) (*ManifestFile, error) {
+	f, err := os.Open(LongPath(opts.LocalFile))
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	defer f.Close()
 
 	if opts.NumRoutines == 0 {
 		opts.NumRoutines = 16
 	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
 
+	fileInfo, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	// partSize is the part size the upload really uses, which is recorded
+	// in the metadata and the manifest so the parts can be rebuilt
+	partSize := sdkPartSize(fileInfo.Size(), opts.PartSize)
+	if opts.ChecksumType == ChecksumTypeFullObject {
+		if fileInfo.Size() > MaxSinglePutSize {
+			return nil, fmt.Errorf("%s is %d bytes, a full object SHA256 can only be stored for objects up to %d bytes uploaded with a single PutObject", opts.LocalFile, fileInfo.Size(), int64(MaxSinglePutSize))
+		}
+		// The upload manager uses a single PutObject when the file fits
+		// in one part
+		partSize = max(partSize, fileInfo.Size())
+	}
 	uploader := manager.NewUploader(client, func(u *manager.Uploader) {
-		u.PartSize = opts.PartSize
+		u.PartSize = partSize
 		u.Concurrency = opts.NumRoutines
+		u.LeavePartsOnError = opts.LeavePartsOnError
 	})
 
-	log.Println("Beginning upload...")
-	uploadOutput, err := uploader.Upload(ctx, &s3.PutObjectInput{
+	input := &s3.PutObjectInput{
 		ChecksumAlgorithm: types.ChecksumAlgorithmSha256, // Trailing Checksum
 		Bucket:            &opts.Bucket,
 		Key:               &opts.Key,
 		Body:              f,
-	})
+	}
+	if limiter != nil {
+		input.Body = &throttledFile{ctx: ctx, f: f, limiter: limiter}
+	}
+	if opts.SSEKMSKeyID != "" {
+		input.SSEKMSKeyId = &opts.SSEKMSKeyID
+		if opts.ServerSideEncryption == "" {
+			opts.ServerSideEncryption = string(types.ServerSideEncryptionAwsKms)
+		}
+	}
+	if opts.SSECustomerKey != "" {
+		sseC, err := newSSECustomerKey(opts.SSECustomerKey)
+		if err != nil {
+			return nil, err
+		}
+		input.SSECustomerAlgorithm = &sseC.Algorithm
+		input.SSECustomerKey = &sseC.Key
+		input.SSECustomerKeyMD5 = &sseC.KeyMD5
+	}
+	if opts.StorageClass != "" {
+		input.StorageClass = types.StorageClass(opts.StorageClass)
+	}
+	contentType := opts.ContentType
+	if contentType == "" {
+		if contentType, err = DetectContentType(opts.LocalFile, f); err != nil {
+			return nil, err
+		}
+	}
+	input.ContentType = &contentType
+	if opts.ServerSideEncryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(opts.ServerSideEncryption)
+	}
+	if len(opts.Metadata) > 0 || opts.WriteChecksumMetadata {
+		input.Metadata = map[string]string{}
+		for k, v := range opts.Metadata {
+			input.Metadata[k] = v
+		}
+	}
+	if opts.WriteChecksumMetadata {
+		input.Metadata[MetadataPartSize] = strconv.FormatInt(partSize, 10)
+		input.Metadata[MetadataAlgorithm] = "sha256"
+	}
+	if len(opts.Tags) > 0 {
+		tags := url.Values{}
+		for k, v := range opts.Tags {
+			tags.Set(k, v)
+		}
+		tagging := tags.Encode()
+		input.Tagging = &tagging
+	}
+
+	uploadOutput, err := uploadWithRetry(ctx, uploader, input, f, opts)
 
 	if err != nil {
-		return err
+		if ctx.Err() != nil && !opts.LeavePartsOnError {
+			abortCancelledUpload(client, opts, err)
+		}
+		return nil, err
 	}
 
 	parts := []*PartInfo{}
 	for _, p := range uploadOutput.CompletedParts {
-		c, err := base64.StdEncoding.DecodeString(*p.ChecksumSHA256)
+		checksum := aws.ToString(p.ChecksumSHA256)
+		if checksum == "" {
+			return nil, fmt.Errorf("upload of s3://%s/%s returned no SHA256 checksum for part %d", opts.Bucket, opts.Key, aws.ToInt32(p.PartNumber))
+		}
+		c, err := base64.StdEncoding.DecodeString(checksum)
 		if err != nil {
-			log.Printf("unable to decode checksum")
+			logger().Warn("unable to decode part checksum", "part_number", aws.ToInt32(p.PartNumber), "error", err)
 		}
 		pi := &PartInfo{
-			PartNumber: *p.PartNumber,
+			PartNumber: aws.ToInt32(p.PartNumber),
 			Checksum:   ByteSlice(c),
 			Algorithm:  "sha256",
 		}
-		fmt.Printf("Part: %05d\t\t%s\n", pi.PartNumber, pi.Checksum)
 		parts = append(parts, pi)
 	}
 
-	etag, err := convertS3EtagToBytes(*uploadOutput.ETag)
+	s3Etag := aws.ToString(uploadOutput.ETag)
+	if s3Etag == "" {
+		return nil, fmt.Errorf("upload of s3://%s/%s returned no ETag", opts.Bucket, opts.Key)
+	}
+	etag, err := convertS3EtagToBytes(s3Etag)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if opts.ManifestFile != "" {
-		m := &ManifestFile{
-			PartList:  parts,
-			Algorithm: "sha256",
-			Etag:      etag,
+	m := &ManifestFile{
+		Filename:  opts.LocalFile,
+		PartSize:  int(partSize),
+		PartList:  parts,
+		Algorithm: "sha256",
+		Etag:      etag,
+	}
+	if opts.ChecksumType == ChecksumTypeFullObject {
+		m.ChecksumType = ChecksumTypeFullObject
+	}
+	// A single part upload has no CompletedParts, its checksum is only in
+	// the PutObject response
+	if uploadOutput.ChecksumSHA256 != nil {
+		checksum, _, err := splitPartCount(*uploadOutput.ChecksumSHA256)
+		if err != nil {
+			return nil, err
 		}
-		mf := []*ManifestFile{m}
-		if err := WriteSimpleManifest(opts.ManifestFile, mf); err != nil {
-			log.Printf("failed writing manifest at: %s", opts.ManifestFile)
+		if m.Checksum, err = decodeChecksum(checksum); err != nil {
+			return nil, err
 		}
+	} else {
+		logger().Warn("upload response has no SHA256 checksum, the manifest has none", "bucket", opts.Bucket, "key", opts.Key)
 	}
-	fmt.Printf("Amazon S3 SHA256:\t%s\n", *uploadOutput.ChecksumSHA256)
+	m.Size = fileInfo.Size()
+	return m, nil
+}
+
+// abortTimeout bounds the AbortMultipartUpload call of abortCancelledUpload.
+const abortTimeout = 30 * time.Second
 
-	etagstr := fmt.Sprintf("%x", etag)
-	if len(parts) > 0 {
-		etagstr = fmt.Sprintf("%s-%d", etagstr, len(parts))
+// abortCancelledUpload aborts the multipart upload that failed with
+// uploadErr. The upload manager aborts failed uploads itself, but with the
+// upload's context, which can't send the request once it is cancelled or
+// timed out.
+func abortCancelledUpload(client UploadAPIClient, opts *UploadOptions, uploadErr error) {
+	var failure manager.MultiUploadFailure
+	if !errors.As(uploadErr, &failure) {
+		return
+	}
+	uploadID := failure.UploadID()
+	ctx, cancel := context.WithTimeout(context.Background(), abortTimeout)
+	defer cancel()
+	_, err := client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   &opts.Bucket,
+		Key:      &opts.Key,
+		UploadId: &uploadID,
+	})
+	if err != nil {
+		logger().Error("unable to abort multipart upload, its parts are still stored", "upload_id", uploadID, "bucket", opts.Bucket, "key", opts.Key, "error", err)
 	}
+}
 
-	fmt.Printf("Amazon S3 Etag:\t%s\n", etagstr)
+// verifyUploadEtag runs VerifyObjectEtag for an uploaded file. It reports
+// false without an error when the ETag can't be verified because of the
+// object's encryption.
+func verifyUploadEtag(ctx context.Context, client UploadAPIClient, opts *UploadOptions) (bool, error) {
+	if opts.SSECustomerKey != "" {
+		logger().Info("skipping ETag verification", "bucket", opts.Bucket, "key", opts.Key, "reason", ErrEtagNotMD5)
+		return false, nil
+	}
+	err := VerifyObjectEtag(ctx, client, opts.Bucket, opts.Key, opts.LocalFile)
+	if errors.Is(err, ErrEtagNotMD5) {
+		logger().Info("skipping ETag verification", "bucket", opts.Bucket, "key", opts.Key, "reason", err)
+		return false, nil
+	}
+	return err == nil, err
+}
 
-	return nil
+// uploadWithRetry retries an upload that failed as a whole with a
+// transient error, after the SDK gave up retrying its individual requests,
+// starting again from the beginning of f.
+func uploadWithRetry(ctx context.Context, uploader *manager.Uploader, input *s3.PutObjectInput, f *os.File, opts *UploadOptions) (*manager.UploadOutput, error) {
+	baseDelay := opts.RetryBaseDelay
+	if baseDelay <= 0 {
+		baseDelay = time.Second
+	}
+	backoff := exponentialBackoff{base: baseDelay}
+
+	for attempt := 1; ; attempt++ {
+		output, err := uploader.Upload(ctx, input)
+		if err == nil || attempt > opts.MaxRetries || ctx.Err() != nil || !isUploadRetryable(err) {
+			return output, err
+		}
+
+		delay := backoff.delay(attempt)
+		logger().Warn("upload attempt failed, retrying", "attempt", attempt, "delay_ms", delay.Milliseconds(), "error", err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+}
 
+// isUploadRetryable reports whether an upload that failed with err may
+// succeed when started again: the errors the SDK retries, such as 5xx
+// responses, throttling and dropped connections. Errors such as
+// AccessDenied or NoSuchBucket would only fail again.
+func isUploadRetryable(err error) bool {
+	return retry.IsErrorRetryables(retry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary
 }
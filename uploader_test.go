@@ -0,0 +1,354 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// fakeUploadClient takes single part uploads with PutObject, failing the
+// first len(errs) attempts with those errors. It answers like S3, with the
+// ETag and the SHA256 of the body.
+type fakeUploadClient struct {
+	errs   []error
+	bodies [][]byte
+}
+
+func (c *fakeUploadClient) PutObject(ctx context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	body, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.bodies = append(c.bodies, body)
+	if len(c.bodies) <= len(c.errs) {
+		return nil, c.errs[len(c.bodies)-1]
+	}
+	etag := md5.Sum(body)
+	sum := sha256.Sum256(body)
+	return &s3.PutObjectOutput{
+		ETag:           aws.String(`"` + hex.EncodeToString(etag[:]) + `"`),
+		ChecksumSHA256: aws.String(base64.StdEncoding.EncodeToString(sum[:])),
+	}, nil
+}
+
+func (c *fakeUploadClient) UploadPart(context.Context, *s3.UploadPartInput, ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	return nil, fmt.Errorf("unexpected UploadPart")
+}
+
+func (c *fakeUploadClient) CreateMultipartUpload(context.Context, *s3.CreateMultipartUploadInput, ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	return nil, fmt.Errorf("unexpected CreateMultipartUpload")
+}
+
+func (c *fakeUploadClient) CompleteMultipartUpload(context.Context, *s3.CompleteMultipartUploadInput, ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	return nil, fmt.Errorf("unexpected CompleteMultipartUpload")
+}
+
+func (c *fakeUploadClient) AbortMultipartUpload(context.Context, *s3.AbortMultipartUploadInput, ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	return nil, fmt.Errorf("unexpected AbortMultipartUpload")
+}
+
+func (c *fakeUploadClient) HeadObject(context.Context, *s3.HeadObjectInput, ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	return nil, fmt.Errorf("unexpected HeadObject")
+}
+
+func TestUploadRetry(t *testing.T) {
+	path, data := writeTestFile(t, 1000)
+	transient := testAPIError("RequestTimeout")
+	denied := testAPIError("AccessDenied")
+
+	tests := []struct {
+		name     string
+		errs     []error
+		wantErr  error
+		attempts int
+	}{
+		{"transient errors are retried", []error{transient, transient}, nil, 3},
+		{"retries run out", []error{transient, transient, transient, transient}, transient, 4},
+		{"access denied isn't retried", []error{denied}, denied, 1},
+		{"cancelled context isn't retried", []error{context.Canceled}, context.Canceled, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeUploadClient{errs: tt.errs}
+			opts := &UploadOptions{
+				Bucket:         "bucket",
+				Key:            "key",
+				LocalFile:      path,
+				PartSize:       MIN_PART_SIZE,
+				MaxRetries:     3,
+				RetryBaseDelay: time.Millisecond,
+				ContentType:    "application/octet-stream",
+			}
+			m, err := uploadFile(context.Background(), client, opts, nil)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil) != (err == nil) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if len(client.bodies) != tt.attempts {
+				t.Errorf("%d attempts, want %d", len(client.bodies), tt.attempts)
+			}
+			for i, body := range client.bodies {
+				if !bytes.Equal(body, data) {
+					t.Errorf("attempt %d sent %d bytes that aren't the file", i+1, len(body))
+				}
+			}
+			if err == nil && m == nil {
+				t.Error("no manifest for the upload")
+			}
+		})
+	}
+}
+
+// TestUploadSinglePartManifest checks the manifest of a file small enough
+// for a single PutObject, which has no CompletedParts to take the checksum
+// from.
+func TestUploadSinglePartManifest(t *testing.T) {
+	path, data := writeTestFile(t, 1000)
+	client := &fakeUploadClient{}
+	opts := &UploadOptions{
+		Bucket:      "bucket",
+		Key:         "key",
+		LocalFile:   path,
+		PartSize:    MIN_PART_SIZE,
+		ContentType: "application/octet-stream",
+	}
+	m, err := uploadFile(context.Background(), client, opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(data)
+	etag := md5.Sum(data)
+	if !bytes.Equal(m.Checksum, sum[:]) {
+		t.Errorf("Checksum = %s, want the SHA256 PutObject returned %s", m.Checksum, ByteSlice(sum[:]))
+	}
+	if !bytes.Equal(m.Etag, etag[:]) {
+		t.Errorf("Etag = %x, want %x", []byte(m.Etag), etag)
+	}
+	if len(m.PartList) != 0 || m.Size != int64(len(data)) || m.Algorithm != "sha256" {
+		t.Errorf("manifest %+v, want no parts, %d bytes and sha256", m, len(data))
+	}
+}
+
+// fakeMultipartClient takes multipart uploads without reading the parts, so
+// a large sparse file uploads quickly. It records the metadata of the upload
+// and the size of every part.
+type fakeMultipartClient struct {
+	fakeUploadClient
+	mu        sync.Mutex
+	metadata  map[string]string
+	partSizes map[int32]int64
+}
+
+func (c *fakeMultipartClient) CreateMultipartUpload(ctx context.Context, in *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	c.metadata = in.Metadata
+	c.partSizes = map[int32]int64{}
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload")}, nil
+}
+
+func (c *fakeMultipartClient) UploadPart(ctx context.Context, in *s3.UploadPartInput, _ ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	size, err := in.Body.(io.Seeker).Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.partSizes[aws.ToInt32(in.PartNumber)] = size
+	c.mu.Unlock()
+	return &s3.UploadPartOutput{
+		ETag:           aws.String(`"d41d8cd98f00b204e9800998ecf8427e"`),
+		ChecksumSHA256: aws.String("47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="),
+	}, nil
+}
+
+func (c *fakeMultipartClient) CompleteMultipartUpload(ctx context.Context, in *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	return &s3.CompleteMultipartUploadOutput{
+		ETag:           aws.String(fmt.Sprintf(`"d41d8cd98f00b204e9800998ecf8427e-%d"`, len(in.MultipartUpload.Parts))),
+		ChecksumSHA256: aws.String(fmt.Sprintf("47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=-%d", len(in.MultipartUpload.Parts))),
+	}, nil
+}
+
+// TestUploadRecordsActualPartSize uploads a file that would take MAX_PARTS
+// parts of the requested size, so the upload manager raises the part size.
+// The metadata and the manifest must record the part size of the upload.
+func TestUploadRecordsActualPartSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sparse.bin")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := int64(MAX_PARTS) * MIN_PART_SIZE
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		t.Skipf("can't create a %d byte sparse file: %v", size, err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &fakeMultipartClient{}
+	opts := &UploadOptions{
+		Bucket:                "bucket",
+		Key:                   "key",
+		LocalFile:             path,
+		PartSize:              MIN_PART_SIZE,
+		ContentType:           "application/octet-stream",
+		WriteChecksumMetadata: true,
+	}
+	m, err := uploadFile(context.Background(), client, opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := size/MAX_PARTS + 1
+	if got := client.partSizes[1]; got != want {
+		t.Fatalf("part 1 is %d bytes, want the upload manager to raise it to %d", got, want)
+	}
+	if got := client.metadata[MetadataPartSize]; got != strconv.FormatInt(want, 10) {
+		t.Errorf("metadata part size = %s, want %d", got, want)
+	}
+	if int64(m.PartSize) != want {
+		t.Errorf("manifest PartSize = %d, want %d", m.PartSize, want)
+	}
+	if len(m.PartList) != len(client.partSizes) {
+		t.Errorf("manifest has %d parts, the upload %d", len(m.PartList), len(client.partSizes))
+	}
+	if m.Size != size {
+		t.Errorf("manifest Size = %d, want %d", m.Size, size)
+	}
+}
+
+// noEtagClient answers PutObject without an ETag, as some S3-compatible
+// stores can.
+type noEtagClient struct {
+	fakeUploadClient
+}
+
+func (c *noEtagClient) PutObject(ctx context.Context, in *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	out, err := c.fakeUploadClient.PutObject(ctx, in, optFns...)
+	if out != nil {
+		out.ETag = nil
+	}
+	return out, err
+}
+
+func TestUploadMissingEtag(t *testing.T) {
+	path, _ := writeTestFile(t, 1000)
+	opts := &UploadOptions{
+		Bucket:      "bucket",
+		Key:         "key",
+		LocalFile:   path,
+		PartSize:    MIN_PART_SIZE,
+		ContentType: "application/octet-stream",
+	}
+	if _, err := uploadFile(context.Background(), &noEtagClient{}, opts, nil); err == nil {
+		t.Fatal("an upload without an ETag succeeded")
+	}
+}
+
+// TestSinglePartOutputHasNoPartCount checks that a file uploaded with a
+// single PutObject, and the same file checksummed locally, print a plain
+// SHA256 and MD5 ETag without the -N suffix of multipart objects, the
+// values HeadObject returns for it.
+func TestSinglePartOutputHasNoPartCount(t *testing.T) {
+	path, data := writeTestFile(t, 1<<20)
+	opts := &UploadOptions{
+		Bucket:      "bucket",
+		Key:         "key",
+		LocalFile:   path,
+		PartSize:    MIN_PART_SIZE,
+		ContentType: "application/octet-stream",
+	}
+	uploaded, err := uploadFile(context.Background(), &fakeUploadClient{}, opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewMultipartFile(MultipartFileOpts{FilePath: path, PartSize: MIN_PART_SIZE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := m.CalculateChecksum(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(data)
+	etag := md5.Sum(data)
+	for name, mf := range map[string]*ManifestFile{"upload": uploaded, "local": local} {
+		if !bytes.Equal(mf.Etag, etag[:]) {
+			t.Errorf("%s Etag = %x, want the plain MD5 %x", name, []byte(mf.Etag), etag)
+		}
+		out := &bytes.Buffer{}
+		if err := renderSummary(out, []*ManifestFile{mf}, EncodingHex); err != nil {
+			t.Fatal(err)
+		}
+		want := fmt.Sprintf("Amazon S3 SHA256:\t%x\nAmazon S3 Etag:\t%x\n", sum, etag)
+		if out.String() != want {
+			t.Errorf("%s summary\n%q\nwant\n%q", name, out, want)
+		}
+		out.Reset()
+		if err := WriteSimpleManifestTo(out, []*ManifestFile{mf}); err != nil {
+			t.Fatal(err)
+		}
+		want = fmt.Sprintf("%s,%d,sha256,%s,%x\n", mf.Filename, MIN_PART_SIZE, ByteSlice(sum[:]).Encode(""), etag)
+		if out.String() != want {
+			t.Errorf("%s csv\n%q\nwant\n%q", name, out, want)
+		}
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	tenTags := map[string]string{}
+	for i := 0; i < 10; i++ {
+		tenTags[fmt.Sprintf("key%d", i)] = "value"
+	}
+	elevenTags := map[string]string{"key10": "value"}
+	for k, v := range tenTags {
+		elevenTags[k] = v
+	}
+
+	tests := []struct {
+		name    string
+		tags    map[string]string
+		wantErr bool
+	}{
+		{"no tags", nil, false},
+		{"ten tags", tenTags, false},
+		{"eleven tags", elevenTags, true},
+		{"128 character key", map[string]string{strings.Repeat("k", 128): "v"}, false},
+		{"129 character key", map[string]string{strings.Repeat("k", 129): "v"}, true},
+		{"128 multibyte character key", map[string]string{strings.Repeat("é", 128): "v"}, false},
+		{"empty key", map[string]string{"": "v"}, true},
+		{"256 character value", map[string]string{"k": strings.Repeat("v", 256)}, false},
+		{"257 character value", map[string]string{"k": strings.Repeat("v", 257)}, true},
+		{"256 multibyte character value", map[string]string{"k": strings.Repeat("é", 256)}, false},
+		{"empty value", map[string]string{"k": ""}, false},
+		{"reserved prefix", map[string]string{"AWS:created": "v"}, true},
+		{"allowed punctuation", map[string]string{"team/app_name": "a+b-c=d.e:f@g h"}, false},
+		{"disallowed character", map[string]string{"k": "a&b"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateTags(tt.tags); (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -5,14 +5,21 @@ package s3checksum
 
 import (
 	"encoding/hex"
+	"fmt"
+	"path"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
 )
 
 var (
-	extractS3 = regexp.MustCompile(`s3:\/\/(.[^\/]*)\/(.*)`)
+	extractS3 = regexp.MustCompile(`s3://([^/]+)(?:/(.*))?`)
 	hexExp    = regexp.MustCompile(`[0-9A-Fa-f]+`)
 )
 
+// ExtractBucketAndPath splits an s3://bucket/key URL. A URL without a key,
+// s3://bucket or s3://bucket/, returns an empty path.
 func ExtractBucketAndPath(s3url string) (bucket string, path string) {
 	parts := extractS3.FindAllStringSubmatch(s3url, -1)
 	if len(parts) > 0 && len(parts[0]) > 2 {
@@ -26,3 +33,77 @@ func convertS3EtagToBytes(s string) ([]byte, error) {
 	etagstr := hexExp.FindString(s)
 	return hex.DecodeString(etagstr)
 }
+
+// KeyForPath derives the S3 key of a local file below root by joining its
+// slash-separated relative path onto prefix.
+func KeyForPath(prefix, root, localPath string) (string, error) {
+	rel, err := filepath.Rel(root, localPath)
+	if err != nil {
+		return "", err
+	}
+	key := path.Clean(filepath.ToSlash(rel))
+	if key == "." || key == ".." || strings.HasPrefix(key, "../") {
+		return "", fmt.Errorf("%s is not below %s", localPath, root)
+	}
+	if prefix == "" {
+		return key, nil
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + key, nil
+}
+
+// KeyCollision is a key that more than one local file maps to.
+type KeyCollision struct {
+	Key   string
+	Paths []string
+}
+
+// KeyCollisionError is returned when a set of local files can't be uploaded
+// without some of them overwriting each other.
+type KeyCollisionError struct {
+	Collisions []KeyCollision
+}
+
+func (e *KeyCollisionError) Error() string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "%d keys are derived from more than one file:", len(e.Collisions))
+	for _, c := range e.Collisions {
+		fmt.Fprintf(&b, "\n  %s: %s", c.Key, strings.Join(c.Paths, ", "))
+	}
+	return b.String()
+}
+
+// FindKeyCollisions derives the key of every path with KeyForPath and
+// returns the keys shared by more than one path, sorted by key. When
+// foldCase is set keys differing only in case also collide, which matters
+// when objects are later synced back to a case-insensitive filesystem.
+func FindKeyCollisions(prefix, root string, paths []string, foldCase bool) ([]KeyCollision, error) {
+	byKey := map[string][]string{}
+	display := map[string]string{}
+	for _, p := range paths {
+		key, err := KeyForPath(prefix, root, p)
+		if err != nil {
+			return nil, err
+		}
+		k := key
+		if foldCase {
+			k = strings.ToLower(key)
+		}
+		if _, ok := display[k]; !ok {
+			display[k] = key
+		}
+		byKey[k] = append(byKey[k], p)
+	}
+
+	collisions := []KeyCollision{}
+	for k, ps := range byKey {
+		if len(ps) < 2 {
+			continue
+		}
+		sort.Strings(ps)
+		collisions = append(collisions, KeyCollision{Key: display[k], Paths: ps})
+	}
+	sort.Slice(collisions, func(i, j int) bool {
+		return collisions[i].Key < collisions[j].Key
+	})
+	return collisions, nil
+}
@@ -0,0 +1,28 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import "testing"
+
+func TestExtractBucketAndPath(t *testing.T) {
+	tests := []struct {
+		url, bucket, path string
+	}{
+		{"s3://my-bucket", "my-bucket", ""},
+		{"s3://my-bucket/", "my-bucket", ""},
+		{"s3://my-bucket/key", "my-bucket", "key"},
+		{"s3://my-bucket/a/b/c.txt", "my-bucket", "a/b/c.txt"},
+		{"s3://my-bucket/dir/", "my-bucket", "dir/"},
+		{"s3://my-bucket/my file+1 (copy).txt", "my-bucket", "my file+1 (copy).txt"},
+		{"not-a-url", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			bucket, path := ExtractBucketAndPath(tt.url)
+			if bucket != tt.bucket || path != tt.path {
+				t.Errorf("ExtractBucketAndPath(%q) = %q, %q, want %q, %q", tt.url, bucket, path, tt.bucket, tt.path)
+			}
+		})
+	}
+}
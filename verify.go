@@ -0,0 +1,398 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// combineChecksums hashes the concatenated part checksums, which is how S3
+// builds the checksum-of-checksums of a multipart object.
+func combineChecksums(parts []*PartInfo, hashFun func() hash.Hash, checksum func(*PartInfo) []byte) ByteSlice {
+	h := hashFun()
+	for _, part := range parts {
+		h.Write(checksum(part))
+	}
+	return h.Sum(nil)
+}
+
+// ComputeCompositeChecksum computes the checksum S3 reports for an object
+// from its part checksums, without reading the data: the hash of the
+// concatenated part checksums, in part number order. An object of a single
+// part has no composite, its checksum is the part checksum.
+func ComputeCompositeChecksum(parts []*PartInfo, hashFun func() hash.Hash) (ByteSlice, error) {
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("no parts to combine")
+	}
+	if hashFun == nil {
+		return nil, fmt.Errorf("hashFun is a required parameter")
+	}
+	if len(parts) == 1 {
+		return parts[0].Checksum, nil
+	}
+
+	sorted := make([]*PartInfo, len(parts))
+	copy(sorted, parts)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].PartNumber < sorted[j].PartNumber })
+	return combineChecksums(sorted, hashFun, func(p *PartInfo) []byte { return p.Checksum }), nil
+}
+
+// CheckManifestComposite verifies that the composite checksum and ETag
+// stored in a manifest are consistent with its stored part checksums,
+// without reading the file. Manifests without a part list have nothing to
+// recombine and are reported as an error.
+func CheckManifestComposite(mf *ManifestFile) error {
+	if len(mf.PartList) == 0 {
+		return fmt.Errorf("%s: manifest has no part checksums to recombine", mf.Filename)
+	}
+	if err := ValidatePartAlgorithms(mf); err != nil {
+		return err
+	}
+	if mf.isFullObject() {
+		return fmt.Errorf("%s: the %s checksum is a full object checksum and can't be recombined from parts", mf.Filename, mf.Algorithm)
+	}
+	a, err := ManifestAlgorithm(mf)
+	if err != nil {
+		return err
+	}
+
+	checksum, err := ComputeCompositeChecksum(mf.PartList, a.HashFun)
+	if err != nil {
+		return err
+	}
+	if len(mf.PartList) == 1 {
+		// A single part object has no composite, the part checksum is the
+		// object checksum
+		if !bytes.Equal(checksum, mf.Checksum) {
+			return withCategory(ErrChecksumMismatch, fmt.Errorf("%s: stored checksum %s doesn't match part checksum %s", mf.Filename, mf.Checksum, checksum))
+		}
+		return nil
+	}
+
+	if !bytes.Equal(checksum, mf.Checksum) {
+		return withCategory(ErrChecksumMismatch, fmt.Errorf("%s: stored composite %s doesn't match %s recombined from %d parts", mf.Filename, mf.Checksum, checksum, len(mf.PartList)))
+	}
+	if len(mf.Etag) > 0 && len(mf.PartList[0].MD5Checksum) > 0 {
+		etag := combineChecksums(mf.PartList, md5.New, func(p *PartInfo) []byte { return p.MD5Checksum })
+		if !bytes.Equal(etag, mf.Etag) {
+			return withCategory(ErrChecksumMismatch, fmt.Errorf("%s: stored etag %x doesn't match %x recombined from %d parts", mf.Filename, mf.Etag, []byte(etag), len(mf.PartList)))
+		}
+	}
+	return nil
+}
+
+// ManifestAlgorithm returns the algorithm a manifest was written with, so
+// it can be verified without the caller knowing which one was used.
+// Manifests without an algorithm are DefaultAlgorithm. An algorithm this
+// build doesn't know is an ErrInvalidManifest error naming it, rather than
+// a silent fallback that would report every part as a mismatch.
+func ManifestAlgorithm(mf *ManifestFile) (*Algorithm, error) {
+	if mf.Algorithm == "" {
+		return LookupAlgorithm(DefaultAlgorithm)
+	}
+	a, err := LookupAlgorithm(mf.Algorithm)
+	if err != nil {
+		return nil, withCategory(ErrInvalidManifest, fmt.Errorf("%s: manifest uses checksum algorithm %q, which this build doesn't support (supported: %s)", mf.Filename, mf.Algorithm, strings.Join(AlgorithmNames(), ", ")))
+	}
+	return a, nil
+}
+
+// ValidatePartAlgorithms checks that every part of a manifest was hashed
+// with the manifest's algorithm. A composite of parts hashed with different
+// algorithms is meaningless, so a mixed manifest has been edited or
+// corrupted. Parts with no algorithm recorded inherit the manifest's.
+func ValidatePartAlgorithms(mf *ManifestFile) error {
+	algorithm := mf.Algorithm
+	if algorithm == "" {
+		algorithm = DefaultAlgorithm
+	}
+	for _, part := range mf.PartList {
+		if part.Algorithm != "" && !strings.EqualFold(part.Algorithm, algorithm) {
+			return fmt.Errorf("%s: part %d has algorithm %s but the manifest has %s", mf.Filename, part.PartNumber, part.Algorithm, algorithm)
+		}
+	}
+	return nil
+}
+
+// PartMismatch is the first part whose checksum differs from the expected
+// one, found by VerifyPartsInOrder.
+type PartMismatch struct {
+	PartNumber int32
+	// Offset is the byte offset of the start of the part in the file.
+	Offset   int64
+	Expected ByteSlice
+	Actual   ByteSlice
+}
+
+// VerifyPartsInOrder hashes the parts of the file one at a time, in order,
+// and stops at the first one that differs from expected. It trades the
+// full list of mismatches CalculateChecksum gives for an early answer when
+// a file doesn't match. It returns nil when every part matches.
+func (m *MultipartFile) VerifyPartsInOrder(ctx context.Context, expected []*PartInfo) (*PartMismatch, error) {
+	if len(expected) != m.NumberOfParts {
+		return nil, fmt.Errorf("%s has %d parts of %d bytes, expected %d parts", m.FilePath, m.NumberOfParts, m.PartSize, len(expected))
+	}
+	sorted := make([]*PartInfo, len(expected))
+	copy(sorted, expected)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].PartNumber < sorted[j].PartNumber })
+
+	for i, want := range sorted {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		part, err := m.CalculateChecksumForPart(ctx, int32(i))
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(part.Checksum, want.Checksum) {
+			return &PartMismatch{
+				PartNumber: part.PartNumber,
+				Offset:     int64(i) * m.PartSize,
+				Expected:   want.Checksum,
+				Actual:     part.Checksum,
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+// ChangedParts returns the numbers of the parts of current that differ from
+// the same part of previous, for re-uploading only what changed. Rolling
+// checksums are compared first when both manifests have them, and parts
+// whose rolling checksums match are confirmed with the strong checksum.
+// Parts past the end of previous are always changed.
+func ChangedParts(previous, current *ManifestFile) ([]int32, error) {
+	if previous.PartSize != current.PartSize {
+		return nil, fmt.Errorf("part sizes differ (%d and %d), parts can't be compared", previous.PartSize, current.PartSize)
+	}
+	if !strings.EqualFold(previous.Algorithm, current.Algorithm) {
+		return nil, fmt.Errorf("algorithms differ (%s and %s), parts can't be compared", previous.Algorithm, current.Algorithm)
+	}
+
+	before := map[int32]*PartInfo{}
+	for _, p := range previous.PartList {
+		before[p.PartNumber] = p
+	}
+
+	changed := []int32{}
+	for _, p := range current.PartList {
+		old, ok := before[p.PartNumber]
+		switch {
+		case !ok || old.Size != p.Size:
+			changed = append(changed, p.PartNumber)
+		case old.RollingChecksum != 0 && p.RollingChecksum != 0 && old.RollingChecksum != p.RollingChecksum:
+			changed = append(changed, p.PartNumber)
+		case !bytes.Equal(old.Checksum, p.Checksum):
+			changed = append(changed, p.PartNumber)
+		}
+	}
+	return changed, nil
+}
+
+// VerifyResult is the outcome of re-checking a file against its manifest.
+type VerifyResult struct {
+	Filename string
+	// Parts is the number of parts that were checked.
+	Parts      int
+	Mismatches []*PartMismatch
+	// Err is why the file couldn't be checked, such as a missing file,
+	// when VerifyManifest went on to the next one.
+	Err error
+}
+
+// OK reports whether the file was checked and every checked part matched.
+func (r *VerifyResult) OK() bool {
+	return r.Err == nil && len(r.Mismatches) == 0
+}
+
+// VerifyManifestFile recomputes the checksums of the file a manifest
+// describes, with the part size and algorithm stored in the manifest, and
+// reports every part that differs. With failFast the parts are checked in
+// order and only the first mismatch is reported. Manifests of a full object
+// algorithm, or without a part list, are checked on the object checksum.
+func VerifyManifestFile(ctx context.Context, mf *ManifestFile, threads int, failFast bool) (*VerifyResult, error) {
+	if err := ValidatePartAlgorithms(mf); err != nil {
+		return nil, err
+	}
+	a, err := ManifestAlgorithm(mf)
+	if err != nil {
+		return nil, err
+	}
+	if threads <= 0 {
+		threads = 16
+	}
+	mpf, err := NewMultipartFile(MultipartFileOpts{
+		FilePath:     mf.Filename,
+		PartSize:     int64(mf.PartSize),
+		Algorithm:    a.Name,
+		HashFun:      a.HashFun,
+		ChecksumType: mf.ChecksumType,
+		Threads:      threads,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if mf.Size > 0 && mpf.FileSize != mf.Size {
+		return nil, withCategory(ErrChecksumMismatch, fmt.Errorf("%s is %d bytes, the manifest expects %d", mf.Filename, mpf.FileSize, mf.Size))
+	}
+
+	result := &VerifyResult{Filename: mf.Filename}
+	if mf.isFullObject() || len(mf.PartList) == 0 {
+		// Only the object checksum can be compared
+		manifest, err := mpf.CalculateChecksum(ctx)
+		if err != nil {
+			return nil, err
+		}
+		result.Parts = mpf.NumberOfParts
+		if !bytes.Equal(manifest.Checksum, mf.Checksum) {
+			result.Mismatches = append(result.Mismatches, &PartMismatch{Expected: mf.Checksum, Actual: manifest.Checksum})
+		}
+		return result, nil
+	}
+
+	if !mf.Partial && len(mf.PartList) != mpf.NumberOfParts {
+		return nil, withCategory(ErrChecksumMismatch, fmt.Errorf("%s has %d parts of %d bytes, the manifest lists %d", mf.Filename, mpf.NumberOfParts, mpf.PartSize, len(mf.PartList)))
+	}
+
+	if failFast && !mf.Partial {
+		mismatch, err := mpf.VerifyPartsInOrder(ctx, mf.PartList)
+		if err != nil {
+			return nil, err
+		}
+		result.Parts = len(mf.PartList)
+		if mismatch != nil {
+			// Parts after the mismatch weren't checked
+			result.Parts = int(mismatch.PartNumber)
+			result.Mismatches = append(result.Mismatches, mismatch)
+		}
+		return result, nil
+	}
+
+	for _, want := range mf.PartList {
+		if want.PartNumber < 1 || int(want.PartNumber) > mpf.NumberOfParts {
+			return nil, fmt.Errorf("%s: part %d is outside the file", mf.Filename, want.PartNumber)
+		}
+	}
+
+	// A fixed pool of threads workers takes the parts to check, as in
+	// CalculateChecksum, and stops taking them once a part fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	mismatches := make([]*PartMismatch, len(mf.PartList))
+	var (
+		errMu    sync.Mutex
+		firstErr error
+	)
+	indices := make(chan int)
+	go func() {
+		defer close(indices)
+		for i := range mf.PartList {
+			select {
+			case indices <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	workers := min(threads, len(mf.PartList))
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				want := mf.PartList[i]
+				part, err := mpf.CalculateChecksumForPart(ctx, want.PartNumber-1)
+				if err != nil {
+					errMu.Lock()
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+					errMu.Unlock()
+					continue
+				}
+				if !bytes.Equal(part.Checksum, want.Checksum) {
+					mismatches[i] = &PartMismatch{
+						PartNumber: want.PartNumber,
+						Offset:     int64(want.PartNumber-1) * mpf.PartSize,
+						Expected:   want.Checksum,
+						Actual:     part.Checksum,
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	result.Parts = len(mf.PartList)
+	for _, m := range mismatches {
+		if m != nil {
+			result.Mismatches = append(result.Mismatches, m)
+		}
+	}
+	sort.Slice(result.Mismatches, func(i, j int) bool {
+		return result.Mismatches[i].PartNumber < result.Mismatches[j].PartNumber
+	})
+	return result, nil
+}
+
+// VerifyManifest reads a manifest printed with --format json or jsonl and
+// verifies every file in it with VerifyManifestFile. The algorithm of every
+// file is checked with ManifestAlgorithm before any file is read. A file
+// that can't be checked gets a result with Err set and the others are
+// still verified, unless failFast stops at the first one; the errors of
+// every such file are returned together, so errors.Is finds each category.
+func VerifyManifest(ctx context.Context, manifestPath string, threads int, failFast bool) ([]*VerifyResult, error) {
+	return VerifyManifestIn(ctx, manifestPath, "", threads, failFast)
+}
+
+// VerifyManifestIn is VerifyManifest with the relative file paths of the
+// manifest read from under baseDir, such as the directory a --recursive
+// manifest was written for. The results keep the paths of the manifest.
+func VerifyManifestIn(ctx context.Context, manifestPath, baseDir string, threads int, failFast bool) ([]*VerifyResult, error) {
+	mf, err := ReadManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range mf {
+		if _, err := ManifestAlgorithm(m); err != nil {
+			return nil, err
+		}
+	}
+	results := []*VerifyResult{}
+	errs := []error{}
+	for _, m := range mf {
+		local := m
+		if baseDir != "" && !filepath.IsAbs(m.Filename) {
+			copied := *m
+			copied.Filename = filepath.Join(baseDir, m.Filename)
+			local = &copied
+		}
+		r, err := VerifyManifestFile(ctx, local, threads, failFast)
+		if err != nil {
+			r = &VerifyResult{Err: err}
+			errs = append(errs, err)
+		}
+		r.Filename = m.Filename
+		results = append(results, r)
+		if err != nil && (failFast || ctx.Err() != nil) {
+			break
+		}
+	}
+	return results, errors.Join(errs...)
+}
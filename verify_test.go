@@ -0,0 +1,193 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3checksum
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestManifest checksums paths with MIN_PART_SIZE parts into a
+// manifest file.
+func writeTestManifest(t *testing.T, paths ...string) string {
+	t.Helper()
+	manifests := []*ManifestFile{}
+	for _, path := range paths {
+		m, err := NewMultipartFile(MultipartFileOpts{FilePath: path, PartSize: MIN_PART_SIZE})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mf, err := m.CalculateChecksum(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		manifests = append(manifests, mf)
+	}
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	if err := WriteManifestFile(manifestPath, manifests); err != nil {
+		t.Fatal(err)
+	}
+	return manifestPath
+}
+
+func TestVerifyManifestContinuesPastErrors(t *testing.T) {
+	missing, _ := writeTestFile(t, 100)
+	changed, _ := writeTestFile(t, MIN_PART_SIZE+100)
+	good, _ := writeTestFile(t, 100)
+	manifestPath := writeTestManifest(t, missing, changed, good)
+
+	if err := os.Remove(missing); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(changed, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte("changed"), MIN_PART_SIZE); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	results, err := VerifyManifest(context.Background(), manifestPath, 4, false)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("err = %v, want the missing file's error", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("%d results, want one per file", len(results))
+	}
+	if results[0].Err == nil || results[0].OK() {
+		t.Errorf("missing file: %+v", results[0])
+	}
+	if results[1].Err != nil || len(results[1].Mismatches) != 1 || results[1].Mismatches[0].PartNumber != 2 {
+		t.Errorf("changed file: %+v", results[1])
+	}
+	if !results[2].OK() {
+		t.Errorf("unchanged file: %+v", results[2])
+	}
+
+	results, err = VerifyManifest(context.Background(), manifestPath, 4, true)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("fail fast err = %v, want the missing file's error", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("fail fast went on past the missing file, %d results", len(results))
+	}
+}
+
+// TestVerifyManifestAlgorithms checks that manifests are verified with the
+// algorithm they were written with, which the caller doesn't pass.
+func TestVerifyManifestAlgorithms(t *testing.T) {
+	path, _ := writeTestFile(t, 2*MIN_PART_SIZE+100)
+	for _, algorithm := range []string{"sha256", "sha1", "crc32c", "crc64nvme", "blake3"} {
+		t.Run(algorithm, func(t *testing.T) {
+			m, err := NewMultipartFile(MultipartFileOpts{FilePath: path, PartSize: MIN_PART_SIZE, Algorithm: algorithm})
+			if err != nil {
+				t.Fatal(err)
+			}
+			mf, err := m.CalculateChecksum(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+			if err := WriteManifestFile(manifestPath, []*ManifestFile{mf}); err != nil {
+				t.Fatal(err)
+			}
+
+			results, err := VerifyManifest(context.Background(), manifestPath, 4, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 1 || !results[0].OK() {
+				t.Errorf("results %+v, want the file to verify", results[0])
+			}
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		mf := &ManifestFile{Filename: path, PartSize: MIN_PART_SIZE, Algorithm: "md4", Checksum: ByteSlice{1}}
+		manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+		if err := WriteManifestFile(manifestPath, []*ManifestFile{mf}); err != nil {
+			t.Fatal(err)
+		}
+		_, err := VerifyManifest(context.Background(), manifestPath, 4, false)
+		if !errors.Is(err, ErrInvalidManifest) || !strings.Contains(err.Error(), `"md4"`) {
+			t.Errorf("err = %v, want ErrInvalidManifest naming md4", err)
+		}
+	})
+}
+
+// TestVerifyManifestFileWorkers checks the parts of a file with fewer and
+// more workers than parts. The changed parts are reported in order.
+func TestVerifyManifestFileWorkers(t *testing.T) {
+	path, _ := writeTestFile(t, 4*MIN_PART_SIZE+100)
+	m, err := NewMultipartFile(MultipartFileOpts{FilePath: path, PartSize: MIN_PART_SIZE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mf, err := m.CalculateChecksum(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, offset := range []int64{3*MIN_PART_SIZE + 1, MIN_PART_SIZE + 1} {
+		if _, err := f.WriteAt([]byte("changed"), offset); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Close()
+
+	for _, threads := range []int{1, 2, 16} {
+		result, err := VerifyManifestFile(context.Background(), mf, threads, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Parts != 5 || len(result.Mismatches) != 2 ||
+			result.Mismatches[0].PartNumber != 2 || result.Mismatches[1].PartNumber != 4 {
+			t.Errorf("%d threads: %d parts checked, mismatches %+v, want parts 2 and 4 of 5", threads, result.Parts, result.Mismatches)
+		}
+	}
+}
+
+// TestVerifyManifestInBaseDir verifies a --recursive manifest, whose paths
+// are relative to the directory, from another working directory.
+func TestVerifyManifestInBaseDir(t *testing.T) {
+	dir := t.TempDir()
+	_, data := writeTestFile(t, 100)
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "data.bin"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mf, err := ChecksumDirectory(context.Background(), dir, false, MultipartFileOpts{PartSize: MIN_PART_SIZE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	if err := WriteManifestFile(manifestPath, mf); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := VerifyManifestIn(context.Background(), manifestPath, dir, 4, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join("sub", "data.bin")
+	if len(results) != 1 || !results[0].OK() || results[0].Filename != want {
+		t.Errorf("results %+v, want %s to verify", results[0], want)
+	}
+
+	// The working directory of the test isn't the base directory
+	if _, err := VerifyManifest(context.Background(), manifestPath, 4, false); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("err = %v, want the relative path not to be found", err)
+	}
+}